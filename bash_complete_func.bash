@@ -2,16 +2,19 @@ _completion_devcontainer_vim(){
     local prev cur cword
     _get_comp_words_by_ref -n : cur prev cword
 
-    local commands="run templates start stop down config vimrc runargs tool clean index self-update help"
+    local commands="run templates start rebuild attach exec stop down list ps config settings vimrc runargs tool clean index doctor self-update help"
     local subcommands_run=""
     local subcommands_templates="apply"
-    local subcommands_tool="vim nvim tmux devcontainer clipboard-data-receiver"
-    local subcommands_tool_vim="download"
-    local subcommands_tool_nvim="download"
-    local subcommands_tool_tmux="download"
-    local subcommands_tool_devcontainer="download"
-    local subcommands_tool_clipboard_data_receiver="download"
-    local subcommands_tool_port_forwarder="download"
+    local subcommands_config="show validate"
+    local subcommands_settings="show"
+    local subcommands_runargs="list show edit generate"
+    local subcommands_tool="list update remove gc vim nvim tmux devcontainer clipboard-data-receiver port-forwarder"
+    local subcommands_tool_vim="download install"
+    local subcommands_tool_nvim="download install"
+    local subcommands_tool_tmux="download install"
+    local subcommands_tool_devcontainer="download install"
+    local subcommands_tool_clipboard_data_receiver="download install"
+    local subcommands_tool_port_forwarder="download install"
     local subcommands_index="update"
 
     if [[ ${cword} -eq 1 ]]; then
@@ -24,6 +27,15 @@ _completion_devcontainer_vim(){
             templates)
                 COMPREPLY=( $(compgen -W "${subcommands_templates}" -- "${cur}") )
                 ;;
+            config)
+                COMPREPLY=( $(compgen -W "${subcommands_config}" -- "${cur}") )
+                ;;
+            settings)
+                COMPREPLY=( $(compgen -W "${subcommands_settings}" -- "${cur}") )
+                ;;
+            runargs)
+                COMPREPLY=( $(compgen -W "${subcommands_runargs}" -- "${cur}") )
+                ;;
             tool)
                 COMPREPLY=( $(compgen -W "${subcommands_tool}" -- "${cur}") )
                 ;;
@@ -0,0 +1,128 @@
+package devcontainer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/docker"
+	"github.com/mikoto2000/devcontainer.vim/v3/output"
+	"github.com/mikoto2000/devcontainer.vim/v3/tools"
+)
+
+type ContainerNotRunningError struct {
+	msg string
+}
+
+func (e *ContainerNotRunningError) Error() string {
+	return e.msg
+}
+
+type ContainerNotSetUpError struct {
+	msg string
+}
+
+func (e *ContainerNotSetUpError) Error() string {
+	return e.msg
+}
+
+// 起動済みのワークスペースのコンテナへ再接続する。
+//
+// `devcontainer up` やツールの転送は行わず、
+// `start` 時に記録した状態を再利用して tmux セッション "devcontainer.vim" へ接続する。
+// 記録済みの clipboard-data-receiver が停止している場合は再起動し、
+// 新しいポートへ送信する SendToTcp をコンテナへ転送し直す。
+func Attach(
+	services DevcontainerStartUseService,
+	args []string,
+	devcontainerPath string,
+	cdrPath string,
+	noPf bool,
+	shell string,
+	configDirForDevcontainer string) error {
+
+	// コマンドライン引数の末尾は `--workspace-folder` の値として使う
+	workspaceFolder := args[len(args)-1]
+	configurationFile := configurationFileFromArgs(args)
+
+	// 1. 起動中のコンテナを探す
+	containerID, err := docker.GetContainerIDFromWorkspaceFolderAndConfiguration(workspaceFolder, configurationFile)
+	if err != nil {
+		var containerNotFoundError *docker.ContainerNotFoundError
+		if errors.As(err, &containerNotFoundError) {
+			return &ContainerNotRunningError{msg: fmt.Sprintf("no running container for workspace `%s`. run `devcontainer.vim start %s` first.", workspaceFolder, workspaceFolder)}
+		}
+		return err
+	}
+	fmt.Fprintf(output.Progress(), "Attach to container: %s\n", containerID)
+
+	// 2. `start` で Vim 起動スクリプトが転送済みかを確認
+	if shell == "" {
+		_, err = docker.Exec(containerID, "test", "-f", "/VimRun.sh")
+		if err != nil {
+			return &ContainerNotSetUpError{msg: fmt.Sprintf("container `%s` is not set up by devcontainer.vim. run `devcontainer.vim start %s` first.", containerID, workspaceFolder)}
+		}
+	}
+
+	// 3. 記録済みの clipboard-data-receiver を確認し、停止していれば再起動する
+	configDir, err := getConfigDirForConfiguration(configDirForDevcontainer, workspaceFolder, configurationFile)
+	if err != nil {
+		return err
+	}
+	pid, port := readCdrState(configDir)
+	if pid == 0 {
+		fmt.Fprintf(os.Stderr, "clipboard-data-receiver is not recorded for this workspace.\n")
+	} else if isCdrRunning(pid) {
+		fmt.Fprintf(output.Progress(), "Use clipboard-data-receiver with pid: %d, port: %d\n", pid, port)
+	} else {
+		fmt.Fprintf(os.Stderr, "clipboard-data-receiver with pid: %d is not running. Restart it.\n", pid)
+		err = restartClipboardReceiver(containerID, cdrPath, configDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 4. 記録済みのマーカーファイルから port-forwarding を復元
+	if !noPf {
+		containerIp, err := docker.Exec(containerID, "sh", "-c", "hostname -i")
+		if err != nil {
+			return errors.New("コンテナ上での hostname 実行に失敗しました。コンテナに hostname コマンドがインストールされている必要があります")
+		}
+		forwardConfigs, err := listPortForwarderMarkers(containerID)
+		if err != nil {
+			return err
+		}
+		restorePortForwarders(strings.TrimSpace(containerIp), forwardConfigs)
+	}
+
+	// 5. コンテナへ接続
+	// VimRun.sh は `new-session -A` で起動するため、既存の tmux セッションがあればそこへ接続する
+	return services.StartVim(containerID, devcontainerPath, workspaceFolder, shell)
+}
+
+// テスト時に差し替えられるよう、変数として保持する
+var isCdrRunning = tools.IsCdrRunning
+
+// clipboard-data-receiver を再起動し、新しいポートへ送信する SendToTcp をコンテナへ転送する。
+//
+// 起動済みの Vim は古いポートを使い続けるため、 SendToTcp を読み込み直すか Vim を再起動する必要がある。
+func restartClipboardReceiver(containerID, cdrPath, configDir string) error {
+	_, port, err := startClipboardReceiverForDevcontainer(cdrPath, configDir)
+	if err != nil {
+		return err
+	}
+
+	// `start` 時に転送した SendToTcp の種類で、 NeoVim を使用しているかを判定する
+	_, err = docker.Exec(containerID, "test", "-f", "/SendToTcp.lua")
+	isNvim := err == nil
+
+	sendToTCP, err := tools.CreateSendToTCP(configDir, port, false, isNvim)
+	if err != nil {
+		return err
+	}
+	payload := &containerPayload{}
+	payload.add(sendToTCP, filepath.Base(sendToTCP), 0644)
+	return payload.upload(containerID)
+}
@@ -0,0 +1,109 @@
+package devcontainer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type recordingStartUseService struct {
+	containerID *string
+}
+
+func (s recordingStartUseService) StartVim(containerID string, devcontainerPath string, workspaceFolder string, shell string) error {
+	*s.containerID = containerID
+	return nil
+}
+
+func useCdrRunning(t *testing.T, running bool) {
+	t.Helper()
+	previous := isCdrRunning
+	isCdrRunning = func(pid int) bool { return running }
+	t.Cleanup(func() {
+		isCdrRunning = previous
+	})
+}
+
+func TestAttachFailsWhenNoContainerRunning(t *testing.T) {
+	_, _, _, configDirForDevcontainer := createTempAppDirs(t)
+	useFakeEngine(t, fakeEngine{psResult: ""})
+
+	var startedContainerID string
+	err := Attach(recordingStartUseService{containerID: &startedContainerID}, []string{"/work"}, "devcontainer", "clipboard-data-receiver", true, "", configDirForDevcontainer)
+
+	var containerNotRunningError *ContainerNotRunningError
+	if !errors.As(err, &containerNotRunningError) {
+		t.Fatalf("want ContainerNotRunningError, but got %v", err)
+	}
+	if startedContainerID != "" {
+		t.Fatalf("must not start vim, but started on %s", startedContainerID)
+	}
+}
+
+func TestAttachFailsWhenContainerNotSetUp(t *testing.T) {
+	_, _, _, configDirForDevcontainer := createTempAppDirs(t)
+	useFakeEngine(t, fakeEngine{
+		psResult: `{"ID":"abc123","State":"running"}`,
+		execErr:  errors.New("exit status 1"),
+	})
+
+	var startedContainerID string
+	err := Attach(recordingStartUseService{containerID: &startedContainerID}, []string{"/work"}, "devcontainer", "clipboard-data-receiver", true, "", configDirForDevcontainer)
+
+	var containerNotSetUpError *ContainerNotSetUpError
+	if !errors.As(err, &containerNotSetUpError) {
+		t.Fatalf("want ContainerNotSetUpError, but got %v", err)
+	}
+}
+
+func TestAttachReusesRunningContainer(t *testing.T) {
+	_, _, _, configDirForDevcontainer := createTempAppDirs(t)
+	useFakeEngine(t, fakeEngine{psResult: `{"ID":"abc123","State":"running"}`})
+	createWorkspaceConfigDir(t, configDirForDevcontainer, "/work", "1234", "50000")
+	useCdrRunning(t, true)
+
+	var startedContainerID string
+	err := Attach(recordingStartUseService{containerID: &startedContainerID}, []string{"/work"}, "devcontainer", "clipboard-data-receiver", true, "", configDirForDevcontainer)
+	if err != nil {
+		t.Fatalf("Attach failed: %v", err)
+	}
+	if startedContainerID != "abc123" {
+		t.Fatalf("want abc123, but got %s", startedContainerID)
+	}
+}
+
+func TestAttachRestartsStoppedCdr(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake clipboard-data-receiver is a shell script")
+	}
+	_, _, _, configDirForDevcontainer := createTempAppDirs(t)
+	useFakeEngine(t, fakeEngine{psResult: `{"ID":"abc123","State":"running"}`})
+	configDir := createWorkspaceConfigDir(t, configDirForDevcontainer, "/work", "1234", "50000")
+	useCdrRunning(t, false)
+
+	// 起動すると新しい pid とポートを出力する clipboard-data-receiver
+	cdrPath := filepath.Join(t.TempDir(), "clipboard-data-receiver")
+	os.WriteFile(cdrPath, []byte("#!/bin/sh\necho '{\"pid\":4321,\"address\":\"127.0.0.1\",\"port\":51234}'\n"), 0755)
+
+	var startedContainerID string
+	err := Attach(recordingStartUseService{containerID: &startedContainerID}, []string{"/work"}, "devcontainer", cdrPath, true, "", configDirForDevcontainer)
+	if err != nil {
+		t.Fatalf("Attach failed: %v", err)
+	}
+
+	// 新しいポートへ送信する SendToTcp を作り直す
+	// (fakeEngine の exec は常に成功するため、 /SendToTcp.lua があるとみなされ NeoVim 用になる)
+	sendToTCP, err := os.ReadFile(filepath.Join(configDir, "SendToTcp.lua"))
+	if err != nil {
+		t.Fatalf("SendToTcp.lua is not created: %v", err)
+	}
+	if !strings.Contains(string(sendToTCP), "51234") {
+		t.Fatalf("SendToTcp.lua must use restarted port, got %s", sendToTCP)
+	}
+	if startedContainerID != "abc123" {
+		t.Fatalf("want abc123, but got %s", startedContainerID)
+	}
+}
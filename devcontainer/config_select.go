@@ -0,0 +1,232 @@
+package devcontainer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/util"
+)
+
+const configOptionConfig = "--config"
+const configOptionName = "--name"
+
+// devcontainer CLI が既定で探す devcontainer.json の場所(優先順)
+var defaultConfigurationFiles = []string{
+	filepath.Join(".devcontainer", "devcontainer.json"),
+	".devcontainer.json",
+}
+
+type ConfigurationNotFoundError struct {
+	msg string
+}
+
+func (e *ConfigurationNotFoundError) Error() string {
+	return e.msg
+}
+
+// errors.Is(err, os.ErrNotExist) で判定できるようにする
+func (e *ConfigurationNotFoundError) Is(target error) bool {
+	return target == os.ErrNotExist
+}
+
+// 複数の devcontainer.json が存在し、どれを使うか決められない場合のエラー
+type AmbiguousConfigurationError struct {
+	msg string
+
+	// 候補となる devcontainer.json の絶対パス
+	Candidates []string
+}
+
+func (e *AmbiguousConfigurationError) Error() string {
+	return e.msg
+}
+
+// 引数から `--config`, `--name` を取り除き、それぞれの値と残りの引数を返却する。
+// `--config=PATH`, `--name=NAME` の形式も受け付ける。
+func ParseConfigArgs(args []string) (string, string, []string) {
+	config := ""
+	name := ""
+	rest := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case (arg == configOptionConfig || arg == configOptionName) && i+1 < len(args)-1:
+			// 末尾はワークスペースフォルダのため、値として消費しない
+			if arg == configOptionConfig {
+				config = args[i+1]
+			} else {
+				name = args[i+1]
+			}
+			i++
+		case strings.HasPrefix(arg, configOptionConfig+"="):
+			config = strings.TrimPrefix(arg, configOptionConfig+"=")
+		case strings.HasPrefix(arg, configOptionName+"="):
+			name = strings.TrimPrefix(arg, configOptionName+"=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return config, name, rest
+}
+
+// ワークスペースフォルダ内の devcontainer.json を探し、絶対パスで返却する。
+//
+// 既定の場所(`.devcontainer/devcontainer.json`, `.devcontainer.json` のうち最初に見つかったもの)を先頭に、
+// `.devcontainer/<name>/devcontainer.json` を名前順に返却する。
+func FindConfigurationFiles(workspaceFolder string) ([]string, error) {
+	configurationFiles := []string{}
+	for _, defaultConfigurationFile := range defaultConfigurationFiles {
+		candidate := filepath.Join(workspaceFolder, defaultConfigurationFile)
+		if util.IsExists(candidate) {
+			configurationFiles = append(configurationFiles, candidate)
+			break
+		}
+	}
+
+	namedConfigurationFiles, err := filepath.Glob(filepath.Join(workspaceFolder, ".devcontainer", "*", "devcontainer.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(namedConfigurationFiles)
+	configurationFiles = append(configurationFiles, namedConfigurationFiles...)
+
+	for i, configurationFile := range configurationFiles {
+		configurationFiles[i], err = filepath.Abs(configurationFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return configurationFiles, nil
+}
+
+// 使用する devcontainer.json を決定し、絶対パスで返却する。
+//
+//   - config が指定されている場合、そのファイルを使用する
+//   - name が指定されている場合、 `.devcontainer/<name>/devcontainer.json` を使用する
+//   - どちらも指定されていない場合、 devcontainer.json が 1 つだけならそれを使用し、
+//     複数あれば AmbiguousConfigurationError を返却する
+func SelectConfigurationFile(workspaceFolder string, config string, name string) (string, error) {
+	if config != "" {
+		if !util.IsExists(config) {
+			return "", &ConfigurationNotFoundError{msg: fmt.Sprintf("configuration file not found: %s", config)}
+		}
+		return filepath.Abs(config)
+	}
+
+	if name != "" {
+		configurationFile := filepath.Join(workspaceFolder, ".devcontainer", name, "devcontainer.json")
+		if !util.IsExists(configurationFile) {
+			return "", &ConfigurationNotFoundError{msg: fmt.Sprintf("configuration `%s` not found. available: %s", name, strings.Join(configurationNames(workspaceFolder), ", "))}
+		}
+		return filepath.Abs(configurationFile)
+	}
+
+	configurationFiles, err := FindConfigurationFiles(workspaceFolder)
+	if err != nil {
+		return "", err
+	}
+	switch len(configurationFiles) {
+	case 0:
+		return "", &ConfigurationNotFoundError{msg: fmt.Sprintf("configuration file not found in %s", workspaceFolder)}
+	case 1:
+		return configurationFiles[0], nil
+	}
+	return "", &AmbiguousConfigurationError{
+		msg:        fmt.Sprintf("multiple configurations found. use --name to select one of: %s", strings.Join(configurationNames(workspaceFolder), ", ")),
+		Candidates: configurationFiles,
+	}
+}
+
+// `--config`, `--name` で指定された devcontainer.json を解決し、
+// devcontainer CLI へ渡せるよう、ワークスペースフォルダの直前に `--config <絶対パス>` を挿入した引数を返却する。
+// devcontainer.json が見つからない場合は、従来どおり devcontainer CLI に任せるため引数をそのまま返却する。
+func ResolveConfigArgs(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return args, nil
+	}
+
+	config, name, rest := ParseConfigArgs(args)
+	workspaceFolder := rest[len(rest)-1]
+	configurationFile, err := SelectConfigurationFile(workspaceFolder, config, name)
+	if err != nil {
+		var configurationNotFoundError *ConfigurationNotFoundError
+		if errors.As(err, &configurationNotFoundError) && config == "" && name == "" {
+			return rest, nil
+		}
+		return nil, err
+	}
+
+	resolvedArgs := append([]string{}, rest[:len(rest)-1]...)
+	resolvedArgs = append(resolvedArgs, configOptionConfig, configurationFile)
+	return append(resolvedArgs, workspaceFolder), nil
+}
+
+// 引数で指定された devcontainer.json の絶対パスを返却する。指定が無ければ空文字を返却する。
+func configurationFileFromArgs(args []string) string {
+	config, _, _ := ParseConfigArgs(args)
+	return config
+}
+
+// devcontainer.json を識別する名前を返却する。
+//
+// 既定の場所の devcontainer.json の場合は空文字、
+// `.devcontainer/<name>/devcontainer.json` の場合は `<name>` 、
+// それ以外はワークスペースフォルダからの相対パスを返却する。
+func ConfigurationName(workspaceFolder string, configurationFile string) string {
+	if configurationFile == "" {
+		return ""
+	}
+
+	workspaceFolderAbs, err := filepath.Abs(workspaceFolder)
+	if err != nil {
+		return configurationFile
+	}
+	relativePath, err := filepath.Rel(workspaceFolderAbs, configurationFile)
+	if err != nil {
+		return configurationFile
+	}
+	for _, defaultConfigurationFile := range defaultConfigurationFiles {
+		if relativePath == defaultConfigurationFile {
+			return ""
+		}
+	}
+
+	elements := strings.Split(filepath.ToSlash(relativePath), "/")
+	if len(elements) == 3 && elements[0] == ".devcontainer" && elements[2] == "devcontainer.json" {
+		return elements[1]
+	}
+	return filepath.ToSlash(relativePath)
+}
+
+// ワークスペースフォルダ内の devcontainer.json の名前一覧を返却する。
+// 既定の場所のものは `default` とする。
+func configurationNames(workspaceFolder string) []string {
+	configurationFiles, err := FindConfigurationFiles(workspaceFolder)
+	if err != nil {
+		return []string{}
+	}
+	return ConfigurationDisplayNames(workspaceFolder, configurationFiles)
+}
+
+// devcontainer.json の表示用の名前一覧を返却する。
+// 既定の場所のものは `default` とする。
+func ConfigurationDisplayNames(workspaceFolder string, configurationFiles []string) []string {
+	names := []string{}
+	for _, configurationFile := range configurationFiles {
+		name := ConfigurationName(workspaceFolder, configurationFile)
+		if name == "" {
+			name = "default"
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// devcontainer.json ごとのワークスペース別設定ディレクトリを返却する
+func getConfigDirForConfiguration(configDirForDevcontainer string, workspaceFolder string, configurationFile string) (string, error) {
+	return util.GetConfigDirForConfiguration(configDirForDevcontainer, workspaceFolder, ConfigurationName(workspaceFolder, configurationFile))
+}
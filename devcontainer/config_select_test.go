@@ -0,0 +1,189 @@
+package devcontainer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// テスト用のワークスペースに devcontainer.json を配置する
+func createConfigurationFiles(t *testing.T, workspaceFolder string, configurationFiles ...string) {
+	t.Helper()
+	for _, configurationFile := range configurationFiles {
+		path := filepath.Join(workspaceFolder, configurationFile)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatalf("error: %s", err)
+		}
+		err = os.WriteFile(path, []byte(`{"image": "alpine"}`), 0644)
+		if err != nil {
+			t.Fatalf("error: %s", err)
+		}
+	}
+}
+
+func TestParseConfigArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantConfig string
+		wantName   string
+		wantRest   []string
+	}{
+		{"none", []string{"--build-no-cache", "."}, "", "", []string{"--build-no-cache", "."}},
+		{"config", []string{"--config", "a/devcontainer.json", "."}, "a/devcontainer.json", "", []string{"."}},
+		{"name", []string{"--name", "python", "--build-no-cache", "."}, "", "python", []string{"--build-no-cache", "."}},
+		{"equal", []string{"--name=python", "."}, "", "python", []string{"."}},
+		{"workspace is not value", []string{"--name", "."}, "", "", []string{"--name", "."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, name, rest := ParseConfigArgs(tt.args)
+			if config != tt.wantConfig || name != tt.wantName || !reflect.DeepEqual(rest, tt.wantRest) {
+				t.Fatalf("error: want (%q, %q, %v), but got (%q, %q, %v)", tt.wantConfig, tt.wantName, tt.wantRest, config, name, rest)
+			}
+		})
+	}
+}
+
+func TestSelectConfigurationFile(t *testing.T) {
+	workspaceFolder := t.TempDir()
+	createConfigurationFiles(t, workspaceFolder,
+		filepath.Join(".devcontainer", "devcontainer.json"),
+		filepath.Join(".devcontainer", "python", "devcontainer.json"),
+		filepath.Join(".devcontainer", "go", "devcontainer.json"))
+
+	// 指定が無ければ決められない
+	_, err := SelectConfigurationFile(workspaceFolder, "", "")
+	var ambiguousConfigurationError *AmbiguousConfigurationError
+	if !errors.As(err, &ambiguousConfigurationError) {
+		t.Fatalf("error: want AmbiguousConfigurationError, but got %v", err)
+	}
+	wantCandidates := []string{
+		filepath.Join(workspaceFolder, ".devcontainer", "devcontainer.json"),
+		filepath.Join(workspaceFolder, ".devcontainer", "go", "devcontainer.json"),
+		filepath.Join(workspaceFolder, ".devcontainer", "python", "devcontainer.json"),
+	}
+	if !reflect.DeepEqual(ambiguousConfigurationError.Candidates, wantCandidates) {
+		t.Fatalf("error: want %v, but got %v", wantCandidates, ambiguousConfigurationError.Candidates)
+	}
+	wantNames := []string{"default", "go", "python"}
+	gotNames := ConfigurationDisplayNames(workspaceFolder, ambiguousConfigurationError.Candidates)
+	if !reflect.DeepEqual(gotNames, wantNames) {
+		t.Fatalf("error: want %v, but got %v", wantNames, gotNames)
+	}
+
+	// 名前で選択
+	got, err := SelectConfigurationFile(workspaceFolder, "", "python")
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if got != wantCandidates[2] {
+		t.Fatalf("error: want %s, but got %s", wantCandidates[2], got)
+	}
+
+	// 存在しない名前
+	_, err = SelectConfigurationFile(workspaceFolder, "", "rust")
+	var configurationNotFoundError *ConfigurationNotFoundError
+	if !errors.As(err, &configurationNotFoundError) {
+		t.Fatalf("error: want ConfigurationNotFoundError, but got %v", err)
+	}
+}
+
+func TestSelectConfigurationFileSingle(t *testing.T) {
+	workspaceFolder := t.TempDir()
+	createConfigurationFiles(t, workspaceFolder, filepath.Join(".devcontainer", "python", "devcontainer.json"))
+
+	got, err := SelectConfigurationFile(workspaceFolder, "", "")
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	want := filepath.Join(workspaceFolder, ".devcontainer", "python", "devcontainer.json")
+	if got != want {
+		t.Fatalf("error: want %s, but got %s", want, got)
+	}
+}
+
+func TestResolveConfigArgs(t *testing.T) {
+	workspaceFolder := t.TempDir()
+	createConfigurationFiles(t, workspaceFolder,
+		filepath.Join(".devcontainer", "python", "devcontainer.json"),
+		filepath.Join(".devcontainer", "go", "devcontainer.json"))
+
+	got, err := ResolveConfigArgs([]string{"--name", "go", "--build-no-cache", workspaceFolder})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	want := []string{"--build-no-cache", "--config", filepath.Join(workspaceFolder, ".devcontainer", "go", "devcontainer.json"), workspaceFolder}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("error: want %v, but got %v", want, got)
+	}
+
+	// devcontainer.json が無いワークスペースは、そのまま devcontainer CLI に任せる
+	emptyWorkspaceFolder := t.TempDir()
+	got, err = ResolveConfigArgs([]string{emptyWorkspaceFolder})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if !reflect.DeepEqual(got, []string{emptyWorkspaceFolder}) {
+		t.Fatalf("error: want %v, but got %v", []string{emptyWorkspaceFolder}, got)
+	}
+}
+
+func TestConfigurationName(t *testing.T) {
+	workspaceFolder := t.TempDir()
+	tests := []struct {
+		configurationFile string
+		want              string
+	}{
+		{"", ""},
+		{filepath.Join(workspaceFolder, ".devcontainer", "devcontainer.json"), ""},
+		{filepath.Join(workspaceFolder, ".devcontainer.json"), ""},
+		{filepath.Join(workspaceFolder, ".devcontainer", "python", "devcontainer.json"), "python"},
+		{filepath.Join(workspaceFolder, "configs", "devcontainer.json"), "configs/devcontainer.json"},
+	}
+	for _, tt := range tests {
+		got := ConfigurationName(workspaceFolder, tt.configurationFile)
+		if got != tt.want {
+			t.Errorf("error: %s: want %q, but got %q", tt.configurationFile, tt.want, got)
+		}
+	}
+}
+
+func TestFindJSONInfoWithConfigurationFile(t *testing.T) {
+	workspaceFolder := t.TempDir()
+	configurationFile := filepath.Join(workspaceFolder, ".devcontainer", "python", "devcontainer.json")
+	createConfigurationFiles(t, workspaceFolder, filepath.Join(".devcontainer", "python", "devcontainer.json"))
+
+	path, dir, err := findJSONInfo(workspaceFolder, configurationFile)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if path != configurationFile || dir != filepath.Dir(configurationFile) {
+		t.Fatalf("error: want (%s, %s), but got (%s, %s)", configurationFile, filepath.Dir(configurationFile), path, dir)
+	}
+}
+
+func TestFindDockerComposeFileDirWithConfigurationFile(t *testing.T) {
+	workspaceFolder := t.TempDir()
+	configurationFile := filepath.Join(workspaceFolder, ".devcontainer", "app", "devcontainer.json")
+	err := os.MkdirAll(filepath.Join(workspaceFolder, ".devcontainer", "app", "compose"), 0755)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	err = os.WriteFile(configurationFile, []byte(`{"dockerComposeFile": "compose/docker-compose.yaml", "service": "app"}`), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	got, err := findDockerComposeFileDir(workspaceFolder, configurationFile)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	want := filepath.Join(workspaceFolder, ".devcontainer", "app", "compose")
+	if got != want {
+		t.Fatalf("error: want %s, but got %s", want, got)
+	}
+}
@@ -2,13 +2,12 @@ package devcontainer
 
 import (
 	"fmt"
-	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
 
 	"github.com/mikoto2000/devcontainer.vim/v3/docker"
+	"github.com/mikoto2000/devcontainer.vim/v3/output"
 	"github.com/mikoto2000/devcontainer.vim/v3/tools"
 	"github.com/mikoto2000/devcontainer.vim/v3/util"
 )
@@ -24,35 +23,32 @@ func getContainerArch(containerID string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	fmt.Printf("Container Arch: '%s'.\n", containerArch)
+	fmt.Fprintf(output.Progress(), "Container Arch: '%s'.\n", containerArch)
 	return containerArch, nil
 }
 
-// port-forwarderをコンテナにインストールする
-func installPortForwarder(containerID, vimInstallDir, containerArch string) error {
+// コンテナ用の port-forwarder を取得し、転送対象に追加する
+func installPortForwarder(vimInstallDir, containerArch string, payload *containerPayload) error {
 	portForwarderContainerPath, err := tools.PortForwarderContainer(tools.DefaultInstallerUseServices{}).Install(vimInstallDir, containerArch, false)
 	if err != nil {
 		return err
 	}
-	err = docker.Cp("port-forwarder-container", portForwarderContainerPath, containerID, "/port-forwarder")
-	if err != nil {
-		return err
-	}
+	payload.add(portForwarderContainerPath, "port-forwarder", 0755)
 	return nil
 }
 
-// tmuxの検出とインストールを行う
-func setupTmux(containerID, vimInstallDir string, containerArch string) (string, bool, error) {
+// tmuxの検出を行い、コンテナに存在しなければ転送対象に追加する
+func setupTmux(containerID, vimInstallDir string, containerArch string, payload *containerPayload) (string, bool, error) {
 	useSystemTmux := false
-	fmt.Printf("Check system installed tmux ... ")
+	fmt.Fprintf(output.Progress(), "Check system installed tmux ... ")
 	out, _ := docker.Exec(containerID, "which", "tmux")
 	if out != "" {
-		fmt.Printf("found.\n")
+		fmt.Fprintf(output.Progress(), "found.\n")
 		useSystemTmux = true
 	} else {
-		fmt.Printf("not found.\n")
+		fmt.Fprintf(output.Progress(), "not found.\n")
 	}
-	fmt.Printf("docker exec output: \"%s\".\n", strings.TrimSpace(out))
+	fmt.Fprintf(output.Progress(), "docker exec output: \"%s\".\n", strings.TrimSpace(out))
 
 	if useSystemTmux {
 		return "tmux", true, nil
@@ -63,43 +59,30 @@ func setupTmux(containerID, vimInstallDir string, containerArch string) (string,
 		return "", false, err
 	}
 
-	err = docker.Cp("tmux", tmuxFilePath, containerID, "/tmux")
-	if err != nil {
-		return "", false, err
-	}
-
-	dockerChownArgs := []string{"exec", "--user", "root", containerID, "sh", "-c", "chmod +x /tmux"}
-	fmt.Printf("Chown tmux: `%s \"%s\"` ...", containerCommand, strings.Join(dockerChownArgs, "\" \""))
-	chmodResult, err := exec.Command(containerCommand, dockerChownArgs...).CombinedOutput()
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "chmod error.")
-		fmt.Fprintln(os.Stderr, string(chmodResult))
-		return "", false, &ChmodError{msg: "chmod error."}
-	}
-	fmt.Printf(" done.\n")
+	payload.add(tmuxFilePath, "tmux", 0755)
 
 	return "tmux", false, nil
 }
 
-// Vimの検出とインストールを行う
-func setupVim(containerID, vimInstallDir string, nvim bool, containerArch string) (string, bool, error) {
+// Vimの検出を行い、コンテナに存在しなければ転送対象に追加する
+func setupVim(containerID, vimInstallDir string, nvim bool, containerArch string, payload *containerPayload) (string, bool, error) {
 	vimFileName := "vim"
 	if nvim {
 		vimFileName = "nvim"
 	}
 
 	useSystemVim := false
-	fmt.Printf("Check system installed %s ... ", vimFileName)
+	fmt.Fprintf(output.Progress(), "Check system installed %s ... ", vimFileName)
 	out, _ := docker.Exec(containerID, "which", vimFileName)
 	if out != "" {
-		fmt.Printf("found.\n")
+		fmt.Fprintf(output.Progress(), "found.\n")
 		useSystemVim = true
 
 		if nvim {
 			vimFileName = "nvim"
 		}
 	} else {
-		fmt.Printf("not found.\n")
+		fmt.Fprintf(output.Progress(), "not found.\n")
 
 		if runtime.GOARCH == "arm64" {
 			// arm の場合スタティックリンクの nvim を作れないため、 vim にフォールバック
@@ -111,10 +94,10 @@ func setupVim(containerID, vimInstallDir string, nvim bool, containerArch string
 			nvim = false
 		}
 	}
-	fmt.Printf("docker exec output: \"%s\".\n", strings.TrimSpace(out))
+	fmt.Fprintf(output.Progress(), "docker exec output: \"%s\".\n", strings.TrimSpace(out))
 
 	if !useSystemVim {
-		// コンテナへ Vim/Neovim を転送して実行権限を追加
+		// コンテナへ転送する Vim/Neovim を取得
 		vimFilePath, err := tools.InstallVim(vimInstallDir, nvim, containerArch)
 		if err != nil {
 			return "", false, err
@@ -127,21 +110,7 @@ func setupVim(containerID, vimInstallDir string, nvim bool, containerArch string
 			actualVimFileName = strings.Split(filepath.Base(vimFilePath), "_")[0]
 		}
 
-		err = docker.Cp("vim", vimFilePath, containerID, "/"+actualVimFileName)
-		if err != nil {
-			return actualVimFileName, useSystemVim, err
-		}
-
-		// `docker exec <dockerrun 時に標準出力に表示される CONTAINER ID> chmod +x /Vim-AppImage`
-		dockerChownArgs := []string{"exec", "--user", "root", containerID, "sh", "-c", "chmod +x /" + actualVimFileName}
-		fmt.Printf("Chown AppImage: `%s \"%s\"` ...", containerCommand, strings.Join(dockerChownArgs, "\" \""))
-		chmodResult, err := exec.Command(containerCommand, dockerChownArgs...).CombinedOutput()
-		if err != nil {
-			fmt.Fprintln(os.Stderr, "chmod error.")
-			fmt.Fprintln(os.Stderr, string(chmodResult))
-			return actualVimFileName, useSystemVim, &ChmodError{msg: "chmod error."}
-		}
-		fmt.Printf(" done.\n")
+		payload.add(vimFilePath, actualVimFileName, 0755)
 
 		return actualVimFileName, useSystemVim, nil
 	}
@@ -149,25 +118,22 @@ func setupVim(containerID, vimInstallDir string, nvim bool, containerArch string
 	return vimFileName, useSystemVim, nil
 }
 
-// Vimファイル（SendToTcp.vimとvimrc）をコンテナに転送する
-func transferVimFiles(containerID, configDir, vimrc string, noCdr bool, port int, isNvim bool) (string, error) {
-	// Vim 関連ファイルの転送(`SendToTcp.vim` と、追加の `vimrc`)
+// Vimファイル（SendToTcp.vimとvimrc）を転送対象に追加する
+func stageVimFiles(configDir, vimrc string, vimrcSnippets []string, noCdr bool, port int, isNvim bool, payload *containerPayload) (string, error) {
+	// Vim 関連ファイルの作成(`SendToTcp.vim` と、追加の `vimrc`)
 	sendToTCP, err := tools.CreateSendToTCP(configDir, port, noCdr, isNvim)
 	if err != nil {
 		return "", err
 	}
 
-	// コンテナへ SendToTcp.vim を転送
-	err = docker.Cp("SendToTcp.vim", sendToTCP, containerID, "/")
+	// devcontainer.json で指定された Vim script を追加の `vimrc` へ追記
+	vimrc, err = createVimrcWithSnippets(configDir, vimrc, vimrcSnippets)
 	if err != nil {
-		return sendToTCP, err
+		return "", err
 	}
 
-	// コンテナへ vimrc を転送
-	err = docker.Cp("vimrc", vimrc, containerID, "/")
-	if err != nil {
-		return sendToTCP, err
-	}
+	payload.add(sendToTCP, filepath.Base(sendToTCP), 0644)
+	payload.add(vimrc, "vimrc", 0644)
 
 	return sendToTCP, nil
 }
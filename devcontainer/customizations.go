@@ -0,0 +1,158 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
+	"github.com/mikoto2000/devcontainer.vim/v3/settings"
+)
+
+// devcontainer.json の `customizations` のうち、 devcontainer.vim 用の設定のキー
+const CustomizationsKey = "devcontainer-vim"
+
+type InvalidCustomizationsError struct {
+	msg string
+}
+
+func (e *InvalidCustomizationsError) Error() string {
+	return e.msg
+}
+
+// devcontainer.json の `customizations.devcontainer-vim` の内容
+//
+//	Example: "customizations": {
+//	           "devcontainer-vim": {
+//	             "editor": "nvim",
+//	             "tmux": false,
+//	             "clipboard": true,
+//	             "portForwarding": true,
+//	             "shell": "bash",
+//	             "vimrc": ["set number", "set expandtab"]
+//	           }
+//	         }
+//
+// 指定されていない項目は nil(vimrc は空)となる。
+type Customizations struct {
+	// 起動するエディター(`vim` または `nvim`)
+	Editor *string `json:"editor"`
+
+	// tmux を使用するか
+	Tmux *bool `json:"tmux"`
+
+	// clipboard-data-receiver を使用するか
+	Clipboard *bool `json:"clipboard"`
+
+	// port-forwarder を使用するか
+	PortForwarding *bool `json:"portForwarding"`
+
+	// Vim の代わりに起動するシェル
+	Shell *string `json:"shell"`
+
+	// 追加の vimrc に追記する Vim script
+	Vimrc VimrcSnippets `json:"vimrc"`
+}
+
+// 追加の vimrc に追記する Vim script。文字列、または文字列の配列を受け付ける。
+type VimrcSnippets []string
+
+func (v *VimrcSnippets) UnmarshalJSON(data []byte) error {
+	var snippet string
+	if json.Unmarshal(data, &snippet) == nil {
+		*v = VimrcSnippets{snippet}
+		return nil
+	}
+
+	var snippets []string
+	err := json.Unmarshal(data, &snippets)
+	if err != nil {
+		return &InvalidCustomizationsError{msg: fmt.Sprintf("customizations.%s.vimrc must be string or array of string.", CustomizationsKey)}
+	}
+	*v = snippets
+	return nil
+}
+
+// プロジェクト設定として、 devcontainer.vim の設定に変換する
+func (c Customizations) Settings() settings.Settings {
+	return settings.Settings{
+		Editor:         c.Editor,
+		Tmux:           c.Tmux,
+		Clipboard:      c.Clipboard,
+		PortForwarding: c.PortForwarding,
+		Shell:          c.Shell,
+	}
+}
+
+// `devcontainer read-configuration` で devcontainer.json を読み込み、
+// `customizations.devcontainer-vim` の内容を返却する。
+func ReadCustomizations(devcontainerPath string, workspaceFolder string, configurationFile string) (Customizations, error) {
+	stdout, err := ReadConfiguration(devcontainerPath, readConfigurationArgs(workspaceFolder, configurationFile)...)
+	if err != nil {
+		return Customizations{}, err
+	}
+	return GetCustomizations(stdout)
+}
+
+// readConfigurationCommandResult から `customizations.devcontainer-vim` の内容を取得する。
+func GetCustomizations(readConfigurationCommandResult string) (Customizations, error) {
+	result, err := UnmarshalReadConfigurationCommandResult([]byte(readConfigurationCommandResult))
+	if err != nil {
+		return Customizations{}, &ReadConfigurationError{msg: "`devcontainer read-configuration` の出力パースに失敗しました。`.devcontainer.json が存在することと、 docker エンジンが起動していることを確認してください。"}
+	}
+
+	customizationsJSON, ok := result.Configuration.Customizations[CustomizationsKey]
+	if !ok || string(customizationsJSON) == "null" {
+		return Customizations{}, nil
+	}
+
+	var customizations Customizations
+	err = json.Unmarshal(customizationsJSON, &customizations)
+	if err != nil {
+		var invalidCustomizationsError *InvalidCustomizationsError
+		if errors.As(err, &invalidCustomizationsError) {
+			return Customizations{}, err
+		}
+		return Customizations{}, &InvalidCustomizationsError{msg: fmt.Sprintf("customizations.%s のパースに失敗しました: %v", CustomizationsKey, err)}
+	}
+	if customizations.Editor != nil && *customizations.Editor != "vim" && *customizations.Editor != "nvim" {
+		return Customizations{}, &InvalidCustomizationsError{msg: fmt.Sprintf("customizations.%s.editor must be one of \"vim\", \"nvim\", got %q.", CustomizationsKey, *customizations.Editor)}
+	}
+	return customizations, nil
+}
+
+// vimrc の内容に vimrcSnippets を追記したファイルを configDir に作成し、そのパスを返却する。
+// vimrcSnippets が空の場合は vimrc をそのまま返却する。
+func createVimrcWithSnippets(configDir string, vimrc string, vimrcSnippets []string) (string, error) {
+	if len(vimrcSnippets) == 0 {
+		return vimrc, nil
+	}
+
+	content, err := os.ReadFile(vimrc)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	builder := &strings.Builder{}
+	builder.Write(content)
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		builder.WriteString("\n")
+	}
+	builder.WriteString(fmt.Sprintf("\n\" customizations.%s.vimrc\n", CustomizationsKey))
+	for _, snippet := range vimrcSnippets {
+		builder.WriteString(snippet)
+		if !strings.HasSuffix(snippet, "\n") {
+			builder.WriteString("\n")
+		}
+	}
+
+	customizedVimrc := filepath.Join(configDir, "vimrc")
+	err = runner.WriteFile(customizedVimrc, []byte(builder.String()), 0644)
+	if err != nil {
+		return "", err
+	}
+	return customizedVimrc, nil
+}
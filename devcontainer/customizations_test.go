@@ -0,0 +1,106 @@
+package devcontainer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGetCustomizations(t *testing.T) {
+	readConfigurationResult := `{
+  "configuration": {
+    "image": "alpine",
+    "customizations": {
+      "vscode": { "extensions": ["golang.go"] },
+      "devcontainer-vim": {
+        "editor": "nvim",
+        "tmux": false,
+        "portForwarding": true,
+        "shell": "bash",
+        "vimrc": "set number"
+      }
+    }
+  }
+}`
+	got, err := GetCustomizations(readConfigurationResult)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	if got.Editor == nil || *got.Editor != "nvim" {
+		t.Fatalf("error: want nvim, but got %v", got.Editor)
+	}
+	if got.Tmux == nil || *got.Tmux {
+		t.Fatalf("error: want tmux false, but got %v", got.Tmux)
+	}
+	if got.Clipboard != nil {
+		t.Fatalf("error: want clipboard nil, but got %v", *got.Clipboard)
+	}
+	if got.PortForwarding == nil || !*got.PortForwarding {
+		t.Fatalf("error: want portForwarding true, but got %v", got.PortForwarding)
+	}
+	if got.Shell == nil || *got.Shell != "bash" {
+		t.Fatalf("error: want shell bash, but got %v", got.Shell)
+	}
+	if !reflect.DeepEqual([]string(got.Vimrc), []string{"set number"}) {
+		t.Fatalf("error: want [set number], but got %v", got.Vimrc)
+	}
+}
+
+func TestGetCustomizationsNotSpecified(t *testing.T) {
+	got, err := GetCustomizations(`{"configuration": {"image": "alpine"}}`)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if !reflect.DeepEqual(got, Customizations{}) {
+		t.Fatalf("error: want zero value, but got %+v", got)
+	}
+}
+
+func TestGetCustomizationsInvalid(t *testing.T) {
+	tests := []string{
+		`{"configuration": {"customizations": {"devcontainer-vim": {"editor": "emacs"}}}}`,
+		`{"configuration": {"customizations": {"devcontainer-vim": {"tmux": "yes"}}}}`,
+		`{"configuration": {"customizations": {"devcontainer-vim": {"vimrc": [1]}}}}`,
+	}
+	for _, tt := range tests {
+		_, err := GetCustomizations(tt)
+		var invalidCustomizationsError *InvalidCustomizationsError
+		if !errors.As(err, &invalidCustomizationsError) {
+			t.Errorf("error: %s: want InvalidCustomizationsError, but got %v", tt, err)
+		}
+	}
+}
+
+func TestCreateVimrcWithSnippets(t *testing.T) {
+	configDir := t.TempDir()
+	vimrc := filepath.Join(t.TempDir(), "vimrc")
+	err := os.WriteFile(vimrc, []byte("set nocompatible"), 0644)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	// 追記が無ければ元の vimrc を使う
+	got, err := createVimrcWithSnippets(configDir, vimrc, nil)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if got != vimrc {
+		t.Fatalf("error: want %s, but got %s", vimrc, got)
+	}
+
+	got, err = createVimrcWithSnippets(configDir, vimrc, []string{"set number", "set expandtab\n"})
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	content, err := os.ReadFile(got)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	want := "set nocompatible\n\n\" customizations.devcontainer-vim.vimrc\nset number\nset expandtab\n"
+	if string(content) != want {
+		t.Fatalf("error: want %q, but got %q", want, string(content))
+	}
+}
@@ -3,16 +3,18 @@ package devcontainer
 import (
 	_ "embed"
 
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/mikoto2000/devcontainer.vim/v3/docker"
 	"github.com/mikoto2000/devcontainer.vim/v3/dockercompose"
+	"github.com/mikoto2000/devcontainer.vim/v3/output"
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
 	"github.com/mikoto2000/devcontainer.vim/v3/tools"
 	"github.com/mikoto2000/devcontainer.vim/v3/util"
 )
@@ -29,8 +31,6 @@ var vimRunX8664Static string
 //go:embed VimRun_aarch64.template.sh
 var vimRunAarch64 string
 
-const containerCommand = "docker"
-
 type UnknownTypeError struct {
 	msg string
 }
@@ -48,60 +48,56 @@ func hasNoCdrOption(args []string) bool {
 	return false
 }
 
-func Stop(args []string, devcontainerPath string, configDirForDevcontainer string) error {
+func Stop(args []string, devcontainerPath string, configDirForDevcontainer string) (StopResult, error) {
 
 	// `devcontainer read-configuration` で docker compose の利用判定
 
 	// コマンドライン引数の末尾は `--workspace-folder` の値として使う
 	workspaceFolder := args[len(args)-1]
-	stdout, _ := ReadConfiguration(devcontainerPath, "--workspace-folder", workspaceFolder)
+	configurationFile := configurationFileFromArgs(args)
+	result := StopResult{WorkspaceFolder: workspaceFolder}
+	stdout, _ := ReadConfiguration(devcontainerPath, readConfigurationArgs(workspaceFolder, configurationFile)...)
 	if stdout == "" {
-		fmt.Printf("This directory is not a workspace for devcontainer: %s\n", workspaceFolder)
-		return nil
+		result.Message = fmt.Sprintf("This directory is not a workspace for devcontainer: %s", workspaceFolder)
+		fmt.Fprintln(output.Progress(), result.Message)
+		return result, nil
 	}
 
 	// `dockerComposeFile` が含まれているかを確認する
 	// 含まれているなら docker compose によるコンテナ構築がされている
 	if strings.Contains(stdout, "dockerComposeFile") {
 
-		// docker compose ps コマンドで compose の情報取得
-		dockerComposePsResultString, err := dockercompose.Ps(workspaceFolder)
+		// ワークスペースと devcontainer.json に対応するコンテナからプロジェクト名を取得
+		projectName, err := findComposeProjectName(workspaceFolder, configurationFile, false)
 		if err != nil {
-			return err
-		}
-		if dockerComposePsResultString == "" {
-			fmt.Println("devcontainer already downed.")
-			return nil
+			return result, err
 		}
-
-		// 必要なのは最初の 1 行だけなので、最初の 1 行のみを取得
-		dockerComposePsResultFirstItemString := strings.Split(dockerComposePsResultString, "\n")[0]
-
-		// docker compose ps コマンドの結果からプロジェクト名を取得
-		projectName, err := dockercompose.GetProjectName(dockerComposePsResultFirstItemString)
-		if err != nil {
-			return err
+		if projectName == "" {
+			result.Message = "devcontainer already downed."
+			fmt.Fprintln(output.Progress(), result.Message)
+			return result, nil
 		}
+		result.ComposeProject = projectName
 
 		// プロジェクト名を使って docker compose stop を実行
-		fmt.Printf("Run `docker compose -p %s stop`(Async)\n", projectName)
+		fmt.Fprintf(output.Progress(), "Run `%s compose -p %s stop`(Async)\n", docker.CurrentEngine().Command(), projectName)
 
 		// docker-compose.yaml の格納ディレクトリを探す
-		dockerComposeFileDir, err := findDockerComposeFileDir(workspaceFolder)
+		dockerComposeFileDir, err := findDockerComposeFileDir(workspaceFolder, configurationFile)
 		if err != nil {
-			return err
+			return result, err
 		}
 
 		// カレントディレクトリを記録して dockerComposeFileDir へ移動
 		currentDir, err := os.Getwd()
 		if err != nil {
-			return err
+			return result, err
 		}
 		os.Chdir(dockerComposeFileDir)
 
 		err = dockercompose.Stop(projectName)
 		if err != nil {
-			return err
+			return result, err
 		}
 
 		// 元のカレントディレクトリへ戻る
@@ -109,31 +105,36 @@ func Stop(args []string, devcontainerPath string, configDirForDevcontainer strin
 
 	} else {
 		// ワークスペースに対応するコンテナを探して ID を取得する
-		containerID, err := docker.GetContainerIDFromWorkspaceFolder(workspaceFolder)
+		containerID, err := docker.GetContainerIDFromWorkspaceFolderAndConfiguration(workspaceFolder, configurationFile)
 		if err != nil {
-			return err
+			return result, err
 		}
+		result.ContainerID = containerID
 
 		// 取得したコンテナに対して stop を行う
-		fmt.Printf("Run `docker stop -f %s stop`(Async)\n", containerID)
+		fmt.Fprintf(output.Progress(), "Run `%s stop %s`(Async)\n", docker.CurrentEngine().Command(), containerID)
 		err = docker.Stop(containerID)
 		if err != nil {
-			return err
+			return result, err
 		}
 	}
-	return nil
+	result.Stopped = true
+	return result, nil
 }
 
-func Down(args []string, devcontainerPath string, configDirForDevcontainer string) error {
+func Down(args []string, devcontainerPath string, configDirForDevcontainer string) (DownResult, error) {
 
 	// `devcontainer read-configuration` で docker compose の利用判定
 
 	// コマンドライン引数の末尾は `--workspace-folder` の値として使う
 	workspaceFolder := args[len(args)-1]
-	stdout, _ := ReadConfiguration(devcontainerPath, "--workspace-folder", workspaceFolder)
+	configurationFile := configurationFileFromArgs(args)
+	result := DownResult{WorkspaceFolder: workspaceFolder}
+	stdout, _ := ReadConfiguration(devcontainerPath, readConfigurationArgs(workspaceFolder, configurationFile)...)
 	if stdout == "" {
-		fmt.Printf("This directory is not a workspace for devcontainer: %s\n", workspaceFolder)
-		return nil
+		result.Message = fmt.Sprintf("This directory is not a workspace for devcontainer: %s", workspaceFolder)
+		fmt.Fprintln(output.Progress(), result.Message)
+		return result, nil
 	}
 
 	// `dockerComposeFile` が含まれているかを確認する
@@ -142,111 +143,112 @@ func Down(args []string, devcontainerPath string, configDirForDevcontainer strin
 	if strings.Contains(stdout, "dockerComposeFile") {
 
 		// docker-compose.yaml の格納ディレクトリを探す
-		dockerComposeFileDir, err := findDockerComposeFileDir(workspaceFolder)
+		dockerComposeFileDir, err := findDockerComposeFileDir(workspaceFolder, configurationFile)
 		if err != nil {
-			return err
+			return result, err
 		}
 
-		// カレントディレクトリを記録して dockerComposeFileDir へ移動
-		currentDir, err := os.Getwd()
+		// ワークスペースと devcontainer.json に対応するコンテナからプロジェクト名を取得
+		projectName, err := findComposeProjectName(workspaceFolder, configurationFile, true)
 		if err != nil {
-			return err
+			return result, err
 		}
-		_, devcontainerJSONDir, err := findJSONInfo(workspaceFolder)
-		if err != nil {
-			return err
+		if projectName == "" {
+			result.Message = "devcontainer already downed."
+			fmt.Fprintln(output.Progress(), result.Message)
+			return result, nil
 		}
+		result.ComposeProject = projectName
 
-		err = os.Chdir(filepath.Join(devcontainerJSONDir, dockerComposeFileDir))
-		if err != nil {
-			return err
-		}
-
-		// docker compose ps コマンドで compose の情報取得
-		dockerComposePsResultString, err := dockercompose.Ps(workspaceFolder)
+		// カレントディレクトリを記録して dockerComposeFileDir へ移動
+		currentDir, err := os.Getwd()
 		if err != nil {
-			return err
+			return result, err
 		}
-		if dockerComposePsResultString == "" {
-			fmt.Println("devcontainer already downed.")
-			return nil
-		}
-
-		// 必要なのは最初の 1 行だけなので、最初の 1 行のみを取得
-		dockerComposePsResultFirstItemString := strings.Split(dockerComposePsResultString, "\n")[0]
-
-		// docker compose ps コマンドの結果からプロジェクト名を取得
-		projectName, err := dockercompose.GetProjectName(dockerComposePsResultFirstItemString)
+		err = os.Chdir(dockerComposeFileDir)
 		if err != nil {
-			return err
+			return result, err
 		}
 
 		// プロジェクト名を使って docker compose down を実行
-		fmt.Printf("Run `docker compose -p %s down`(Async)\n", projectName)
+		fmt.Fprintf(output.Progress(), "Run `%s compose -p %s down`(Async)\n", docker.CurrentEngine().Command(), projectName)
 		err = dockercompose.Down(projectName)
 		if err != nil {
-			return err
+			return result, err
 		}
 
 		// 元のカレントディレクトリへ戻る
 		err = os.Chdir(currentDir)
 		if err != nil {
-			return err
+			return result, err
 		}
 
 		// pid ファイル参照のために、
 		// コンテナ別の設定ファイル格納ディレクトリの名前(コンテナIDを記録)を記録
-		configDir, err = util.GetConfigDir(configDirForDevcontainer, workspaceFolder)
+		configDir, err = getConfigDirForConfiguration(configDirForDevcontainer, workspaceFolder, configurationFile)
 		if err != nil {
-			return err
+			return result, err
 		}
 	} else {
 		// ワークスペースに対応するコンテナを探して ID を取得する
-		containerID, err := docker.GetContainerIDFromWorkspaceFolder(workspaceFolder)
+		containerID, err := docker.GetContainerIDFromWorkspaceFolderAndConfiguration(workspaceFolder, configurationFile)
 		if err != nil {
-			return err
+			return result, err
 		}
+		result.ContainerID = containerID
 
 		// 取得したコンテナに対して rm を行う
-		fmt.Printf("Run `docker rm -f %s down`(Async)\n", containerID)
+		fmt.Fprintf(output.Progress(), "Run `%s rm -f %s`(Async)\n", docker.CurrentEngine().Command(), containerID)
 		err = docker.Rm(containerID)
 		if err != nil {
-			return err
+			return result, err
 		}
 
 		// pid ファイル参照のために、
 		// コンテナ別の設定ファイル格納ディレクトリの名前(コンテナIDを記録)を記録
-		configDir, err = util.GetConfigDir(configDirForDevcontainer, workspaceFolder)
+		configDir, err = getConfigDirForConfiguration(configDirForDevcontainer, workspaceFolder, configurationFile)
 		if err != nil {
-			return err
+			return result, err
 		}
 	}
 
 	if !hasNoCdrOption(args) {
 		// clipboard-data-receiver を停止
 		pidFile := filepath.Join(configDir, "pid")
-		fmt.Printf("Read PID file: %s\n", pidFile)
+		fmt.Fprintf(output.Progress(), "Read PID file: %s\n", pidFile)
 		pidStringBytes, err := os.ReadFile(pidFile)
 		if err != nil {
-			return err
+			return result, err
 		}
 		pid, err := strconv.Atoi(string(pidStringBytes))
 		if err != nil {
-			return err
+			return result, err
 		}
-		fmt.Printf("clipboard-data-receiver PID: %d\n", pid)
+		fmt.Fprintf(output.Progress(), "clipboard-data-receiver PID: %d\n", pid)
 		tools.KillCdr(pid)
+		result.CdrPid = pid
 	}
 
-	err := os.RemoveAll(configDir)
+	err := runner.RemoveAll(configDir)
 	if err != nil {
-		return err
+		return result, err
 	}
-	return nil
+	result.Removed = true
+	result.ConfigDir = configDir
+	return result, nil
 }
 
 // devcontainer.json の場所・ディレクトリを差がして返却する
-func findJSONInfo(workspaceFolder string) (string, string, error) {
+// configurationFile が指定されている場合は、そのファイルの場所・ディレクトリを返却する
+func findJSONInfo(workspaceFolder string, configurationFile string) (string, string, error) {
+	if configurationFile != "" {
+		devcontainerJSONPath, err := filepath.Abs(configurationFile)
+		if err != nil {
+			return "", "", err
+		}
+		return devcontainerJSONPath, filepath.Dir(devcontainerJSONPath), nil
+	}
+
 	// カレントディレクトリを記録して workspaceFolder へ移動
 	currentDir, err := os.Getwd()
 	if err != nil {
@@ -281,16 +283,16 @@ func findJSONInfo(workspaceFolder string) (string, string, error) {
 	return devcontainerJSONPath, devcontainerJSONDir, nil
 }
 
-// docker-compose.yaml の格納ディレクトリを返却する
-func findDockerComposeFileDir(workspaceFolder string) (string, error) {
+// docker-compose.yaml の格納ディレクトリを絶対パスで返却する
+func findDockerComposeFileDir(workspaceFolder string, configurationFile string) (string, error) {
 	// devcontainer.json を取得
-	devcontainerJSONPath, devcontainerJSONDir, err := findJSONInfo(workspaceFolder)
+	devcontainerJSONPath, devcontainerJSONDir, err := findJSONInfo(workspaceFolder, configurationFile)
 	if err != nil {
 		return "", err
 	}
 
 	// devcontainer.json 読み込み
-	// fmt.Printf("devcontainerJSONPath directory: %s\n", devcontainerJSONPath)
+	// fmt.Fprintf(output.Progress(), "devcontainerJSONPath directory: %s\n", devcontainerJSONPath)
 	devcontainerJSONBytes, err := util.ParseJwcc(devcontainerJSONPath)
 	if err != nil {
 		return "", err
@@ -304,23 +306,49 @@ func findDockerComposeFileDir(workspaceFolder string) (string, error) {
 
 	// string, []string を判別しながら docker-compose.yaml の場所を取得
 	iDockerComposeFile := devcontainerJSON.DockerComposeFile
-	fmt.Println(iDockerComposeFile)
+	fmt.Fprintln(output.Progress(), iDockerComposeFile)
 	var dockerComposeFilePath string
 	switch v := iDockerComposeFile.(type) {
 	case string:
-		dockerComposeFilePath = v
+		dockerComposeFilePath = filepath.Join(devcontainerJSONDir, v)
 	case []interface{}:
-		vv := v[0].(string)
+		vv, ok := v[0].(string)
+		if !ok {
+			return "", &UnknownTypeError{msg: "docker compose file path の型が不正です。 `devcontainer.vim config validate` で設定ファイルを確認してください。"}
+		}
 		dockerComposeFilePath = filepath.Join(devcontainerJSONDir, vv)
 	default:
 		return "", &UnknownTypeError{msg: "docker compose file path の型が不正です。 GitHub に issue を立てていただけるとありがたいです。"}
 	}
 	dockerComposeFileDir := filepath.Dir(dockerComposeFilePath)
 
-	fmt.Printf("dockerComposeFileDir directory: %s\n", dockerComposeFileDir)
+	fmt.Fprintf(output.Progress(), "dockerComposeFileDir directory: %s\n", dockerComposeFileDir)
 	return dockerComposeFileDir, nil
 }
 
+// `devcontainer read-configuration` の、ワークスペースフォルダと devcontainer.json を指定する引数を返却する
+func readConfigurationArgs(workspaceFolder string, configurationFile string) []string {
+	args := []string{"--workspace-folder", workspaceFolder}
+	if configurationFile != "" {
+		args = append(args, "--config", configurationFile)
+	}
+	return args
+}
+
+// ワークスペースと devcontainer.json に対応するコンテナの、 docker compose のプロジェクト名を返却する。
+// コンテナが存在しない場合は空文字を返却する。
+func findComposeProjectName(workspaceFolder string, configurationFile string, all bool) (string, error) {
+	container, err := docker.FindContainer(workspaceFolder, configurationFile, all)
+	if err != nil {
+		var containerNotFoundError *docker.ContainerNotFoundError
+		if errors.As(err, &containerNotFoundError) {
+			return "", nil
+		}
+		return "", err
+	}
+	return container.Label(labelComposeProject), nil
+}
+
 func GetConfigurationFilePath(devcontainerFilePath string, workspaceFolder string) (string, error) {
 	stdout, _ := ReadConfiguration(devcontainerFilePath, "--workspace-folder", workspaceFolder)
 	return GetConfigFilePath(stdout)
@@ -328,7 +356,8 @@ func GetConfigurationFilePath(devcontainerFilePath string, workspaceFolder strin
 
 func ReadConfiguration(devcontainerFilePath string, readConfiguration ...string) (string, error) {
 	args := append([]string{"read-configuration"}, readConfiguration...)
-	result, err := Execute(devcontainerFilePath, args...)
+	args = append(args, dockerPathArgs()...)
+	result, err := executeReadOnly(devcontainerFilePath, args...)
 	if err != nil {
 		return "", errors.New("`devcontainer read-configuration` に失敗しました。`.devcontainer.json が存在することと、 docker エンジンが起動していることを確認してください。")
 	}
@@ -345,39 +374,80 @@ func Templates(
 	return ExecuteCombineOutput(devcontainerFilePath, args...)
 }
 
+// devcontainer CLI に、使用するコンテナエンジンを伝えるための引数を返却する
+func dockerPathArgs() []string {
+	return []string{"--docker-path", docker.CurrentEngine().Command()}
+}
+
 func Execute(devcontainerFilePath string, args ...string) (string, error) {
-	fmt.Printf("run devcontainer: `%s %s`\n", devcontainerFilePath, strings.Join(args, " "))
-	cmd := exec.Command(devcontainerFilePath, args...)
+	fmt.Fprintf(output.Progress(), "run devcontainer: `%s %s`\n", devcontainerFilePath, strings.Join(args, " "))
+	cmd := runner.Command(devcontainerFilePath, args...)
+	stdout, err := cmd.Output()
+	return string(stdout), err
+}
+
+// `read-configuration` など、副作用の無い devcontainer CLI のサブコマンドを実行する。
+// dry-run モードでも実行する。
+func executeReadOnly(devcontainerFilePath string, args ...string) (string, error) {
+	fmt.Fprintf(output.Progress(), "run devcontainer: `%s %s`\n", devcontainerFilePath, strings.Join(args, " "))
+	cmd := runner.ReadOnlyCommand(devcontainerFilePath, args...)
 	stdout, err := cmd.Output()
 	return string(stdout), err
 }
 
 func ExecuteCombineOutput(devcontainerFilePath string, args ...string) (string, error) {
-	fmt.Printf("run devcontainer: `%s %s`\n", devcontainerFilePath, strings.Join(args, " "))
-	cmd := exec.Command(devcontainerFilePath, args...)
+	fmt.Fprintf(output.Progress(), "run devcontainer: `%s %s`\n", devcontainerFilePath, strings.Join(args, " "))
+	cmd := runner.Command(devcontainerFilePath, args...)
 	stdout, err := cmd.CombinedOutput()
 	return string(stdout), err
 }
 
+// ユーザー共通の追加設定ファイルのファイル名。 appConfigDir に配置する
+const UserAdditionalConfigFileName = "devcontainer.vim.json"
+
 // devcontainer.vim 起動時に使用する設定ファイルを作成する
 // 設定ファイルは、 devcontainer.vim のキャッシュ内の `config` ディレクトリに、
 // ワークスペースフォルダのパスを md5 ハッシュ化した名前のディレクトリに格納する.
-func CreateConfigFile(devcontainerPath string, workspaceFolder string, configDirForDevcontainer string) (string, error) {
+// devcontainer.json に、ユーザー共通の追加設定ファイル、プロジェクトの追加設定ファイルの順でマージする。
+// userAdditionalConfigFilePath が空文字、または存在しない場合はユーザー共通の追加設定ファイルをマージしない。
+//
+// configurationFile が指定されている場合はその devcontainer.json を、
+// 空文字の場合は devcontainer CLI が選択する devcontainer.json を使用する。
+func CreateConfigFile(devcontainerPath string, workspaceFolder string, configurationFile string, configDirForDevcontainer string, userAdditionalConfigFilePath string) (string, error) {
 	// devcontainer の設定ファイルパス取得
-	configFilePath, err := GetConfigurationFilePath(devcontainerPath, workspaceFolder)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return "", fmt.Errorf("configuration file not found: %w", err)
+	configFilePath := configurationFile
+	if configFilePath == "" {
+		var err error
+		configFilePath, err = GetConfigurationFilePath(devcontainerPath, workspaceFolder)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return "", fmt.Errorf("configuration file not found: %w", err)
+			}
+			return "", err
 		}
-		return "", err
 	}
 
 	// devcontainer.vim 用の追加設定ファイルを探す
-	configurationFileName := configFilePath[:len(configFilePath)-len(filepath.Ext(configFilePath))]
-	additionalConfigurationFilePath := configurationFileName + ".vim.json"
+	additionalConfigurationFilePath := getAdditionalConfigurationFilePath(configFilePath)
+
+	if userAdditionalConfigFilePath != "" && util.IsExists(userAdditionalConfigFilePath) {
+		fmt.Fprintf(output.Progress(), "Use user configuration file: `%s`\n", userAdditionalConfigFilePath)
+	}
+
+	// マージ順: devcontainer.json -> ユーザー共通の追加設定 -> プロジェクトの追加設定
+	configFileContent, err := util.MergeConfigFiles(configFilePath, userAdditionalConfigFilePath, additionalConfigurationFilePath)
+	if err != nil {
+		return "", err
+	}
+
+	// コンテナ上のホームディレクトリのプレースホルダーを置換
+	configFileContent, err = resolveRemoteHome(devcontainerPath, workspaceFolder, configurationFile, configFileContent)
+	if err != nil {
+		return "", err
+	}
 
 	// 設定管理フォルダに JSON を配置
-	mergedConfigFilePath, err := util.CreateConfigFileForDevcontainer(configDirForDevcontainer, workspaceFolder, configFilePath, additionalConfigurationFilePath)
+	mergedConfigFilePath, err := util.WriteConfigFileForDevcontainer(configDirForDevcontainer, workspaceFolder, ConfigurationName(workspaceFolder, configFilePath), configFileContent)
 	if err != nil {
 		if errors.Is(err, os.ErrPermission) {
 			return "", fmt.Errorf("permission error: %w", err)
@@ -385,7 +455,76 @@ func CreateConfigFile(devcontainerPath string, workspaceFolder string, configDir
 		return "", err
 	}
 
-	fmt.Printf("Use configuration file: `%s`", mergedConfigFilePath)
+	fmt.Fprintf(output.Progress(), "Use configuration file: `%s`", mergedConfigFilePath)
 
 	return mergedConfigFilePath, err
 }
+
+// devcontainer.json に対応する、プロジェクトの追加設定ファイルのパスを返却する
+func getAdditionalConfigurationFilePath(configFilePath string) string {
+	configurationFileName := configFilePath[:len(configFilePath)-len(filepath.Ext(configFilePath))]
+	return configurationFileName + ".vim.json"
+}
+
+// 設定ファイルのレイヤー名
+const ConfigLayerBase = "base"
+const ConfigLayerUser = "user"
+const ConfigLayerProject = "project"
+
+// devcontainer.vim 起動時にマージする設定ファイルを、マージ順に返却する。
+// 存在しない追加設定ファイルは含めない。
+//
+// configurationFile が空文字の場合は、 SelectConfigurationFile と同じ規則で devcontainer.json を選択する。
+func ConfigLayers(workspaceFolder string, configurationFile string, userAdditionalConfigFilePath string) ([]util.ConfigLayer, error) {
+	configFilePath := configurationFile
+	if configFilePath == "" {
+		var err error
+		configFilePath, err = SelectConfigurationFile(workspaceFolder, "", "")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	layers := []util.ConfigLayer{{Name: ConfigLayerBase, Path: configFilePath}}
+	if userAdditionalConfigFilePath != "" && util.IsExists(userAdditionalConfigFilePath) {
+		layers = append(layers, util.ConfigLayer{Name: ConfigLayerUser, Path: userAdditionalConfigFilePath})
+	}
+	additionalConfigurationFilePath := getAdditionalConfigurationFilePath(configFilePath)
+	if util.IsExists(additionalConfigurationFilePath) {
+		layers = append(layers, util.ConfigLayer{Name: ConfigLayerProject, Path: additionalConfigurationFilePath})
+	}
+	return layers, nil
+}
+
+// devcontainer.vim 起動時に `--override-config` へ渡す設定を、
+// 各値の由来とともに返却する。
+// 起動時と同様に、コンテナ上のホームディレクトリのプレースホルダーも置換する。
+// ただし、コンテナを起動しないよう、イメージからのホームディレクトリの解決は行わない。
+func MergeConfig(devcontainerPath string, workspaceFolder string, configurationFile string, userAdditionalConfigFilePath string) ([]util.ConfigLayer, *util.MergedConfig, error) {
+	layers, err := ConfigLayers(workspaceFolder, configurationFile, userAdditionalConfigFilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	mergedConfig, err := util.MergeConfigLayers(layers)
+	if err != nil {
+		return layers, nil, err
+	}
+
+	if containsRemoteHome(mergedConfig.Value) {
+		mergedJSON, err := json.Marshal(mergedConfig.Value)
+		if err != nil {
+			return layers, nil, err
+		}
+		// 問い合わせのみのため、イメージのコンテナは起動しない
+		home, err := remoteHome(devcontainerPath, workspaceFolder, layers[0].Path, mergedJSON, false)
+		if err != nil {
+			return layers, nil, err
+		}
+		mergedConfig.Value = replaceRemoteHome(mergedConfig.Value, home)
+		for i, change := range mergedConfig.Changes {
+			mergedConfig.Changes[i].Old = replaceRemoteHome(change.Old, home)
+			mergedConfig.Changes[i].New = replaceRemoteHome(change.New, home)
+		}
+	}
+	return layers, mergedConfig, nil
+}
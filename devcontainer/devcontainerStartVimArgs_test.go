@@ -5,10 +5,10 @@ import "testing"
 func TestBuildDevcontainerStartVimExecArgsUsesDetachKeysForVimRunScript(t *testing.T) {
 	args := buildDevcontainerStartVimExecArgs("test-container", "/workspace", "")
 
-	if len(args) != 6 {
+	if len(args) != 8 {
 		t.Fatalf("unexpected args length: %#v", args)
 	}
-	if args[0] != "exec" || args[1] != "--container-id" || args[2] != "test-container" || args[3] != "--workspace-folder" || args[4] != "/workspace" || args[5] != "/VimRun.sh" {
+	if args[0] != "exec" || args[1] != "--container-id" || args[2] != "test-container" || args[3] != "--workspace-folder" || args[4] != "/workspace" || args[5] != "--docker-path" || args[6] != "docker" || args[7] != "/VimRun.sh" {
 		t.Fatalf("unexpected devcontainer exec args: %#v", args)
 	}
 }
@@ -16,10 +16,10 @@ func TestBuildDevcontainerStartVimExecArgsUsesDetachKeysForVimRunScript(t *testi
 func TestBuildDevcontainerStartVimExecArgsUsesDetachKeysForShell(t *testing.T) {
 	args := buildDevcontainerStartVimExecArgs("test-container", "/workspace", "bash")
 
-	if len(args) != 6 {
+	if len(args) != 8 {
 		t.Fatalf("unexpected args length: %#v", args)
 	}
-	if args[0] != "exec" || args[1] != "--container-id" || args[2] != "test-container" || args[3] != "--workspace-folder" || args[4] != "/workspace" || args[5] != "bash" {
+	if args[0] != "exec" || args[1] != "--container-id" || args[2] != "test-container" || args[3] != "--workspace-folder" || args[4] != "/workspace" || args[5] != "--docker-path" || args[6] != "docker" || args[7] != "bash" {
 		t.Fatalf("unexpected devcontainer exec args: %#v", args)
 	}
 }
@@ -1,6 +1,11 @@
 package devcontainer
 
-import "testing"
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func TestHasNoCdrOption(t *testing.T) {
 	tests := []struct {
@@ -29,3 +34,119 @@ func TestHasNoCdrOption(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigLayersInMergeOrder(t *testing.T) {
+	workspaceFolder := t.TempDir()
+	devcontainerDir := filepath.Join(workspaceFolder, ".devcontainer")
+	err := os.MkdirAll(devcontainerDir, 0777)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	userConfigFilePath := filepath.Join(t.TempDir(), UserAdditionalConfigFileName)
+	files := map[string]string{
+		filepath.Join(devcontainerDir, "devcontainer.json"):     `{"remoteUser": "root"}`,
+		filepath.Join(devcontainerDir, "devcontainer.vim.json"): `{"remoteUser": "project"}`,
+		userConfigFilePath: `{"remoteUser": "user"}`,
+	}
+	for path, content := range files {
+		err := os.WriteFile(path, []byte(content), 0666)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+
+	layers, mergedConfig, err := MergeConfig("", workspaceFolder, "", userConfigFilePath)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	wantNames := []string{ConfigLayerBase, ConfigLayerUser, ConfigLayerProject}
+	if len(layers) != len(wantNames) {
+		t.Fatalf("want %d layers, got %v", len(wantNames), layers)
+	}
+	for i, name := range wantNames {
+		if layers[i].Name != name {
+			t.Fatalf("layer %d: want %s, got %s", i, name, layers[i].Name)
+		}
+	}
+	if got := mergedConfig.Provenance()["remoteUser"]; got != ConfigLayerProject {
+		t.Fatalf("want remoteUser from %s, got %s", ConfigLayerProject, got)
+	}
+}
+
+func TestConfigLayersWithoutConfigurationFile(t *testing.T) {
+	_, err := ConfigLayers(t.TempDir(), "", "")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("want os.ErrNotExist, got %v", err)
+	}
+}
+
+func TestConfigLayersWithNamedConfiguration(t *testing.T) {
+	workspaceFolder := t.TempDir()
+	files := map[string]string{
+		filepath.Join(workspaceFolder, ".devcontainer", "devcontainer.json"):               `{"remoteUser": "root"}`,
+		filepath.Join(workspaceFolder, ".devcontainer", "python", "devcontainer.json"):     `{"remoteUser": "vscode"}`,
+		filepath.Join(workspaceFolder, ".devcontainer", "python", "devcontainer.vim.json"): `{"remoteUser": "python"}`,
+	}
+	for path, content := range files {
+		os.MkdirAll(filepath.Dir(path), 0777)
+		err := os.WriteFile(path, []byte(content), 0666)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+
+	// 複数ある場合は選択が必要
+	_, err := ConfigLayers(workspaceFolder, "", "")
+	var ambiguousConfigurationError *AmbiguousConfigurationError
+	if !errors.As(err, &ambiguousConfigurationError) {
+		t.Fatalf("want AmbiguousConfigurationError, got %v", err)
+	}
+
+	configurationFile, err := SelectConfigurationFile(workspaceFolder, "", "python")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	layers, mergedConfig, err := MergeConfig("", workspaceFolder, configurationFile, "")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(layers) != 2 || layers[0].Path != configurationFile || layers[1].Path != filepath.Join(filepath.Dir(configurationFile), "devcontainer.vim.json") {
+		t.Fatalf("unexpected layers: %v", layers)
+	}
+	if got := mergedConfig.Value.(map[string]any)["remoteUser"]; got != "python" {
+		t.Fatalf("want remoteUser python, got %v", got)
+	}
+}
+
+func TestValidateConfigAcceptsConfigTemplate(t *testing.T) {
+	workspaceFolder := t.TempDir()
+	devcontainerDir := filepath.Join(workspaceFolder, ".devcontainer")
+	err := os.MkdirAll(devcontainerDir, 0777)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(devcontainerDir, "devcontainer.json"), []byte(`{"image": "debian"}`), 0666)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	template, err := os.ReadFile(filepath.Join("..", "devcontainer.vim.template.json"))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(devcontainerDir, "devcontainer.vim.json"), template, 0666)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	layers, problems, err := ValidateConfig(workspaceFolder, "", "")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(layers) != 2 {
+		t.Fatalf("want 2 layers, got %v", layers)
+	}
+	if len(problems) != 0 {
+		t.Fatalf("want no problems, got %v", problems)
+	}
+}
@@ -0,0 +1,239 @@
+package devcontainer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/docker"
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
+	"github.com/mikoto2000/devcontainer.vim/v3/util"
+)
+
+const execOptionTTY = "--tty"
+const execOptionNoTTY = "--no-tty"
+
+type InvalidExecArgsError struct {
+	msg string
+}
+
+func (e *InvalidExecArgsError) Error() string {
+	return e.msg
+}
+
+// `devcontainer.vim exec` のオプション
+type execOptions struct {
+	workspaceFolder string
+	command         []string
+
+	// `--config`, `--name` で指定された devcontainer.json
+	config string
+	name   string
+
+	// TTY を使用するか。 nil の場合は標準入力が端末かどうかで判定する
+	tty *bool
+}
+
+// `[--tty|--no-tty] [--config PATH|--name NAME] WORKSPACE_FOLDER -- COMMAND [ARGS...]` 形式の引数をパースする。
+// `--` を省略した場合、最初の引数をワークスペースフォルダ、残りをコマンドとみなす。
+func parseExecArgs(args []string) (execOptions, error) {
+	options := execOptions{}
+
+	// `--` より前をワークスペース指定、後ろをコマンドとして分割
+	workspaceArgs := args
+	var command []string
+	for i, arg := range args {
+		if arg == "--" {
+			workspaceArgs = args[:i]
+			command = args[i+1:]
+			break
+		}
+	}
+
+	positional := []string{}
+	for i := 0; i < len(workspaceArgs); i++ {
+		arg := workspaceArgs[i]
+		switch {
+		case arg == execOptionTTY:
+			tty := true
+			options.tty = &tty
+		case arg == execOptionNoTTY:
+			tty := false
+			options.tty = &tty
+		case (arg == configOptionConfig || arg == configOptionName) && i+1 < len(workspaceArgs):
+			if arg == configOptionConfig {
+				options.config = workspaceArgs[i+1]
+			} else {
+				options.name = workspaceArgs[i+1]
+			}
+			i++
+		case strings.HasPrefix(arg, configOptionConfig+"="):
+			options.config = strings.TrimPrefix(arg, configOptionConfig+"=")
+		case strings.HasPrefix(arg, configOptionName+"="):
+			options.name = strings.TrimPrefix(arg, configOptionName+"=")
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if command == nil && len(positional) > 1 {
+		command = positional[1:]
+		positional = positional[:1]
+	}
+
+	if len(positional) != 1 {
+		return options, &InvalidExecArgsError{msg: "workspace folder must be specified exactly once."}
+	}
+	if len(command) == 0 {
+		return options, &InvalidExecArgsError{msg: "command must be specified after `--`."}
+	}
+
+	options.workspaceFolder = positional[0]
+	options.command = command
+	return options, nil
+}
+
+// `devcontainer exec` の引数を組み立てる。
+// マージ済み設定ファイルが存在する場合、 remoteUser, remoteEnv を反映するため `--override-config` で渡す。
+func buildDevcontainerExecArgs(containerID string, workspaceFolder string, configurationFile string, mergedConfigFilePath string, command []string) []string {
+	args := []string{
+		"exec",
+		"--container-id",
+		containerID,
+		"--workspace-folder",
+		workspaceFolder,
+	}
+	if configurationFile != "" {
+		args = append(args, configOptionConfig, configurationFile)
+	}
+	if mergedConfigFilePath != "" {
+		args = append(args, "--override-config", mergedConfigFilePath)
+	}
+	args = append(args, dockerPathArgs()...)
+	return append(args, command...)
+}
+
+// ワークスペースのコンテナ上で任意のコマンドを実行し、その終了コードを返却する。
+// 標準入出力はそのままコマンドへ接続する。
+func Exec(args []string, devcontainerPath string, configDirForDevcontainer string) (int, error) {
+	options, err := parseExecArgs(args)
+	if err != nil {
+		return 1, err
+	}
+
+	// 使用する devcontainer.json を決定する
+	// exec はスクリプトから使われるため、複数の候補があっても選択プロンプトは表示せずエラーにする
+	configurationFile, err := SelectConfigurationFile(options.workspaceFolder, options.config, options.name)
+	if err != nil {
+		var configurationNotFoundError *ConfigurationNotFoundError
+		if !errors.As(err, &configurationNotFoundError) || options.config != "" || options.name != "" {
+			return 1, err
+		}
+		configurationFile = ""
+	}
+
+	// ワークスペースと devcontainer.json に対応するコンテナを探して ID を取得する
+	containerID, err := docker.GetContainerIDFromWorkspaceFolderAndConfiguration(options.workspaceFolder, configurationFile)
+	if err != nil {
+		var containerNotFoundError *docker.ContainerNotFoundError
+		if errors.As(err, &containerNotFoundError) {
+			return 1, &ContainerNotRunningError{msg: fmt.Sprintf("no running container for workspace `%s`. run `devcontainer.vim start %s` first.", options.workspaceFolder, options.workspaceFolder)}
+		}
+		return 1, err
+	}
+
+	// `start` 時に作成したマージ済み設定ファイルを探す
+	mergedConfigFilePath := ""
+	configDir, err := getConfigDirForConfiguration(configDirForDevcontainer, options.workspaceFolder, configurationFile)
+	if err != nil {
+		return 1, err
+	}
+	if util.IsExists(filepath.Join(configDir, "devcontainer.json")) {
+		mergedConfigFilePath = filepath.Join(configDir, "devcontainer.json")
+	}
+
+	tty := util.IsTerminal(os.Stdin)
+	if options.tty != nil {
+		tty = *options.tty
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	// コマンドの出力を汚さないよう、実行ログは標準エラー出力へ出す
+	devcontainerExecArgs := buildDevcontainerExecArgs(containerID, options.workspaceFolder, configurationFile, mergedConfigFilePath, options.command)
+	fmt.Fprintf(os.Stderr, "run devcontainer: `%s \"%s\"`\n", devcontainerPath, strings.Join(devcontainerExecArgs, "\" \""))
+
+	cmd := createExecCommand(ctx, devcontainerPath, devcontainerExecArgs, tty, os.Stdin)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+
+	err = cmd.Run()
+	if err != nil {
+		var exitError *exec.ExitError
+		if errors.As(err, &exitError) {
+			return exitError.ExitCode(), nil
+		}
+		// TTY を使用しない場合、コマンドの終了後も読み込み待ちの標準入力は待たない
+		if errors.Is(err, exec.ErrWaitDelay) {
+			return 0, nil
+		}
+		return 1, err
+	}
+	return 0, nil
+}
+
+// `devcontainer exec` を実行するコマンドを作成する。
+//
+// devcontainer CLI は、標準入力が端末の場合にコンテナ上で TTY を割り当てる。
+// tty が true の場合は `script` コマンドで疑似端末を割り当てて実行し、
+// false の場合は標準入力を端末ではなくパイプ経由で渡して、 TTY を割り当てさせない。
+func createExecCommand(ctx context.Context, devcontainerPath string, devcontainerExecArgs []string, tty bool, stdin *os.File) *runner.Cmd {
+	if !tty {
+		cmd := runner.CommandContext(ctx, devcontainerPath, devcontainerExecArgs...)
+		cmd.Stdin = stdin
+		if util.IsTerminal(stdin) {
+			cmd.Stdin = stdinPipeReader{stdin}
+			cmd.WaitDelay = execStdinWaitDelay
+		}
+		return cmd
+	}
+
+	var cmd *runner.Cmd
+	switch {
+	case util.IsWsl():
+		cmd = createStartVimCommand(ctx, devcontainerPath, devcontainerExecArgs)
+	case runtime.GOOS == "windows" || !util.IsExistsCommand("script"):
+		cmd = runner.CommandContext(ctx, devcontainerPath, devcontainerExecArgs...)
+	case runtime.GOOS == "linux":
+		scriptArgs := []string{"-qefc", shellQuote(devcontainerPath)}
+		for _, arg := range devcontainerExecArgs {
+			scriptArgs[1] += " " + shellQuote(arg)
+		}
+		cmd = runner.CommandContext(ctx, "script", append(scriptArgs, "/dev/null")...)
+	default:
+		// BSD 系の script は、記録ファイルの後ろに実行するコマンドを指定する
+		cmd = runner.CommandContext(ctx, "script", append([]string{"-q", "/dev/null", devcontainerPath}, devcontainerExecArgs...)...)
+	}
+	cmd.Stdin = stdin
+	return cmd
+}
+
+// TTY を使用しない場合に、コマンドの終了後に標準入力の読み込みを待つ最大時間
+const execStdinWaitDelay = 100 * time.Millisecond
+
+// *os.File であることを隠し、 exec.Cmd にパイプ経由で標準入力を渡させるための io.Reader
+type stdinPipeReader struct {
+	io.Reader
+}
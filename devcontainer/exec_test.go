@@ -0,0 +1,133 @@
+package devcontainer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/util"
+)
+
+func TestParseExecArgs(t *testing.T) {
+	options, err := parseExecArgs([]string{"--no-tty", ".", "--", "go", "test", "--", "./..."})
+	if err != nil {
+		t.Fatalf("parseExecArgs failed: %v", err)
+	}
+	if options.workspaceFolder != "." {
+		t.Fatalf("want ., but got %s", options.workspaceFolder)
+	}
+	if !reflect.DeepEqual(options.command, []string{"go", "test", "--", "./..."}) {
+		t.Fatalf("unexpected command: %v", options.command)
+	}
+	if options.tty == nil || *options.tty {
+		t.Fatalf("tty must be disabled")
+	}
+}
+
+func TestParseExecArgsWithoutSeparator(t *testing.T) {
+	options, err := parseExecArgs([]string{".", "ls", "-la"})
+	if err != nil {
+		t.Fatalf("parseExecArgs failed: %v", err)
+	}
+	if options.workspaceFolder != "." || !reflect.DeepEqual(options.command, []string{"ls", "-la"}) {
+		t.Fatalf("unexpected options: %#v", options)
+	}
+	if options.tty != nil {
+		t.Fatalf("tty must be auto detected")
+	}
+}
+
+func TestParseExecArgsWithoutCommand(t *testing.T) {
+	_, err := parseExecArgs([]string{".", "--"})
+	var invalidExecArgsError *InvalidExecArgsError
+	if !errors.As(err, &invalidExecArgsError) {
+		t.Fatalf("want InvalidExecArgsError, but got %v", err)
+	}
+}
+
+func TestBuildDevcontainerExecArgs(t *testing.T) {
+	got := buildDevcontainerExecArgs("abc123", "/work", "", "/cache/devcontainer.json", []string{"make", "test"})
+	want := []string{"exec", "--container-id", "abc123", "--workspace-folder", "/work", "--override-config", "/cache/devcontainer.json", "--docker-path", "docker", "make", "test"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, but got %v", want, got)
+	}
+
+	got = buildDevcontainerExecArgs("abc123", "/work", "/work/.devcontainer/node/devcontainer.json", "", []string{"make"})
+	want = []string{"exec", "--container-id", "abc123", "--workspace-folder", "/work", "--config", "/work/.devcontainer/node/devcontainer.json", "--docker-path", "docker", "make"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, but got %v", want, got)
+	}
+}
+
+func TestParseExecArgsWithConfigSelection(t *testing.T) {
+	options, err := parseExecArgs([]string{"--name", "node", "--tty", ".", "--", "ls"})
+	if err != nil {
+		t.Fatalf("parseExecArgs failed: %v", err)
+	}
+	if options.name != "node" || options.workspaceFolder != "." || !reflect.DeepEqual(options.command, []string{"ls"}) {
+		t.Fatalf("unexpected options: %#v", options)
+	}
+
+	options, err = parseExecArgs([]string{"--config=/work/a.json", ".", "--", "ls"})
+	if err != nil || options.config != "/work/a.json" {
+		t.Fatalf("unexpected options: %#v, %v", options, err)
+	}
+}
+
+func TestExecTargetsSelectedConfiguration(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake devcontainer is a shell script")
+	}
+	_, _, _, configDirForDevcontainer := createTempAppDirs(t)
+	workspaceFolder := t.TempDir()
+	for _, name := range []string{"python", "node"} {
+		os.MkdirAll(filepath.Join(workspaceFolder, ".devcontainer", name), 0777)
+		os.WriteFile(filepath.Join(workspaceFolder, ".devcontainer", name, "devcontainer.json"), []byte(`{"image": "alpine"}`), 0666)
+	}
+	nodeConfig := filepath.Join(workspaceFolder, ".devcontainer", "node", "devcontainer.json")
+	useFakeEngine(t, fakeEngine{psResult: `{"ID":"python123","State":"running","Labels":{"devcontainer.config_file":"` + filepath.Join(workspaceFolder, ".devcontainer", "python", "devcontainer.json") + `"}}
+{"ID":"node123","State":"running","Labels":{"devcontainer.config_file":"` + nodeConfig + `"}}`})
+
+	// 受け取った引数を記録する devcontainer
+	argsFile := filepath.Join(t.TempDir(), "args")
+	devcontainerPath := filepath.Join(t.TempDir(), "devcontainer")
+	os.WriteFile(devcontainerPath, []byte("#!/bin/sh\necho \"$@\" > "+argsFile+"\n"), 0755)
+
+	exitCode, err := Exec([]string{"--no-tty", "--name", "node", workspaceFolder, "--", "ls"}, devcontainerPath, configDirForDevcontainer)
+	if err != nil || exitCode != 0 {
+		t.Fatalf("Exec failed: %d, %v", exitCode, err)
+	}
+	args, _ := os.ReadFile(argsFile)
+	if !strings.Contains(string(args), "--container-id node123") || !strings.Contains(string(args), "--config "+nodeConfig) {
+		t.Fatalf("want node configuration's container, got %s", args)
+	}
+
+	// 複数の devcontainer.json があり、指定が無い場合はエラー
+	_, err = Exec([]string{"--no-tty", workspaceFolder, "--", "ls"}, devcontainerPath, configDirForDevcontainer)
+	var ambiguousConfigurationError *AmbiguousConfigurationError
+	if !errors.As(err, &ambiguousConfigurationError) {
+		t.Fatalf("want AmbiguousConfigurationError, but got %v", err)
+	}
+}
+
+func TestCreateExecCommandControlsTTY(t *testing.T) {
+	if runtime.GOOS == "windows" || util.IsWsl() || !util.IsExistsCommand("script") {
+		t.Skip("TTY allocation requires script")
+	}
+
+	args := []string{"exec", "--container-id", "abc123", "make", "test"}
+	noTTY := createExecCommand(context.Background(), "/bin/devcontainer", args, false, nil)
+	if want := append([]string{"/bin/devcontainer"}, args...); !reflect.DeepEqual(noTTY.Args, want) {
+		t.Fatalf("want %v, but got %v", want, noTTY.Args)
+	}
+
+	tty := createExecCommand(context.Background(), "/bin/devcontainer", args, true, nil)
+	if tty.Args[0] != "script" || reflect.DeepEqual(tty.Args, noTTY.Args) {
+		t.Fatalf("want script wrapped command, but got %v", tty.Args)
+	}
+}
@@ -0,0 +1,189 @@
+package devcontainer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/docker"
+	"github.com/mikoto2000/devcontainer.vim/v3/util"
+)
+
+const labelLocalFolder = "devcontainer.local_folder"
+const labelConfigFile = "devcontainer.config_file"
+const labelComposeProject = "com.docker.compose.project"
+
+// devcontainer.vim が管理しているワークスペースの状態
+type WorkspaceStatus struct {
+	WorkspaceFolder string   `json:"workspaceFolder"`
+	Configuration   string   `json:"configuration,omitempty"`
+	ContainerID     string   `json:"containerId"`
+	State           string   `json:"state"`
+	Image           string   `json:"image"`
+	ComposeProject  string   `json:"composeProject"`
+	CdrPid          int      `json:"cdrPid"`
+	CdrPort         int      `json:"cdrPort"`
+	PortForwards    []string `json:"portForwards"`
+	ConfigDir       string   `json:"configDir"`
+}
+
+// `devcontainer.local_folder` ラベルを持つコンテナと、
+// キャッシュディレクトリ内のワークスペース別設定ディレクトリを突き合わせ、
+// ワークスペースごとの状態を返却する。
+func List(configDirForDevcontainer string) ([]WorkspaceStatus, error) {
+	psResult, err := docker.PsAll("label=" + labelLocalFolder)
+	if err != nil {
+		return nil, err
+	}
+	containers, err := docker.UnmarshalPsCommandResults(psResult)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []WorkspaceStatus{}
+	listedConfigDirs := map[string]bool{}
+
+	// コンテナが存在するワークスペース
+	for _, container := range containers {
+		workspaceFolder := container.Label(labelLocalFolder)
+		configurationName := ConfigurationName(workspaceFolder, container.Label(labelConfigFile))
+		configDir, err := util.GetConfigDirForConfiguration(configDirForDevcontainer, workspaceFolder, configurationName)
+		if err != nil {
+			return nil, err
+		}
+
+		status := WorkspaceStatus{
+			WorkspaceFolder: workspaceFolder,
+			Configuration:   configurationName,
+			ContainerID:     container.ID,
+			State:           container.State,
+			Image:           container.Image,
+			ComposeProject:  container.Label(labelComposeProject),
+			PortForwards:    []string{},
+		}
+		if util.IsExists(configDir) {
+			status.ConfigDir = configDir
+			status.CdrPid, status.CdrPort = readCdrState(configDir)
+			listedConfigDirs[configDir] = true
+		}
+		if container.State == "running" {
+			status.PortForwards = listPortForwards(container.ID)
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	// 設定ディレクトリのみ残っているワークスペース
+	entries, err := os.ReadDir(configDirForDevcontainer)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return statuses, nil
+		}
+		return nil, err
+	}
+	for _, entry := range entries {
+		configDir := filepath.Join(configDirForDevcontainer, entry.Name())
+		if !entry.IsDir() || listedConfigDirs[configDir] {
+			continue
+		}
+
+		// ワークスペースフォルダを記録していない古い設定ディレクトリは逆引きできないのでスキップ
+		workspaceFolder, err := os.ReadFile(filepath.Join(configDir, util.WorkspaceFileName))
+		if err != nil {
+			continue
+		}
+
+		status := WorkspaceStatus{
+			WorkspaceFolder: string(workspaceFolder),
+			PortForwards:    []string{},
+			ConfigDir:       configDir,
+		}
+		status.CdrPid, status.CdrPort = readCdrState(configDir)
+		statuses = append(statuses, status)
+	}
+
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].WorkspaceFolder < statuses[j].WorkspaceFolder
+	})
+
+	return statuses, nil
+}
+
+// clipboard-data-receiver の pid ファイル、 port ファイルを読み込む。
+// 読み込めなかった場合は 0 を返却する。
+func readCdrState(configDir string) (int, int) {
+	return readIntFile(filepath.Join(configDir, "pid")), readIntFile(filepath.Join(configDir, "port"))
+}
+
+func readIntFile(path string) int {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// コンテナ内のマーカーファイルから、転送中のポートを `<転送先ホスト>:<ポート>` の形式で返却する。
+func listPortForwards(containerID string) []string {
+	portForwards := []string{}
+	markers, err := listPortForwarderMarkers(containerID)
+	if err != nil {
+		return portForwards
+	}
+	for _, marker := range markers {
+		forward, _, found := strings.Cut(marker, "_")
+		if found {
+			portForwards = append(portForwards, forward)
+		}
+	}
+	return portForwards
+}
+
+// ワークスペースの状態を表形式で w へ出力する。
+func WriteWorkspaceStatusTable(w io.Writer, statuses []WorkspaceStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKSPACE\tCONTAINER ID\tSTATE\tIMAGE\tCOMPOSE PROJECT\tCDR PID\tCDR PORT\tPORT FORWARDS")
+	for _, status := range statuses {
+		containerID := status.ContainerID
+		if len(containerID) > 12 {
+			containerID = containerID[:12]
+		}
+		workspaceFolder := status.WorkspaceFolder
+		if status.Configuration != "" {
+			workspaceFolder = fmt.Sprintf("%s (%s)", workspaceFolder, status.Configuration)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			workspaceFolder,
+			orDash(containerID),
+			orDash(status.State),
+			orDash(status.Image),
+			orDash(status.ComposeProject),
+			orDash(intToString(status.CdrPid)),
+			orDash(intToString(status.CdrPort)),
+			orDash(strings.Join(status.PortForwards, ",")))
+	}
+	return tw.Flush()
+}
+
+func intToString(value int) string {
+	if value == 0 {
+		return ""
+	}
+	return strconv.Itoa(value)
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,91 @@
+package devcontainer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/util"
+)
+
+func createWorkspaceConfigDir(t *testing.T, configDirForDevcontainer string, workspaceFolder string, pid string, port string) string {
+	t.Helper()
+	configDir, err := util.GetConfigDir(configDirForDevcontainer, workspaceFolder)
+	if err != nil {
+		t.Fatalf("failed to get config dir: %v", err)
+	}
+	if err := os.MkdirAll(configDir, 0777); err != nil {
+		t.Fatalf("failed to create config dir: %v", err)
+	}
+	files := map[string]string{util.WorkspaceFileName: workspaceFolder, "pid": pid, "port": port}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(configDir, name), []byte(content), 0666); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return configDir
+}
+
+func TestListJoinsContainersAndConfigDirs(t *testing.T) {
+	_, _, _, configDirForDevcontainer := createTempAppDirs(t)
+	useFakeEngine(t, fakeEngine{
+		psResult: `{"ID":"0123456789abcdef","Image":"vsc-work","State":"running","Labels":{"com.docker.compose.project":"work_devcontainer","devcontainer.local_folder":"/work"}}
+`,
+		execResult: "localhost:8080_172.17.0.2:40000\x00",
+	})
+
+	runningConfigDir := createWorkspaceConfigDir(t, configDirForDevcontainer, "/work", "1234", "50000")
+	createWorkspaceConfigDir(t, configDirForDevcontainer, "/stale", "", "")
+
+	statuses, err := List(configDirForDevcontainer)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("want 2 workspaces, but got %d: %#v", len(statuses), statuses)
+	}
+
+	stale := statuses[0]
+	if stale.WorkspaceFolder != "/stale" || stale.ContainerID != "" || stale.CdrPid != 0 {
+		t.Fatalf("unexpected stale workspace: %#v", stale)
+	}
+
+	running := statuses[1]
+	if running.WorkspaceFolder != "/work" ||
+		running.ContainerID != "0123456789abcdef" ||
+		running.State != "running" ||
+		running.Image != "vsc-work" ||
+		running.ComposeProject != "work_devcontainer" ||
+		running.CdrPid != 1234 ||
+		running.CdrPort != 50000 ||
+		running.ConfigDir != runningConfigDir {
+		t.Fatalf("unexpected running workspace: %#v", running)
+	}
+	if len(running.PortForwards) != 1 || running.PortForwards[0] != "localhost:8080" {
+		t.Fatalf("unexpected port forwards: %#v", running.PortForwards)
+	}
+}
+
+func TestWriteWorkspaceStatusTable(t *testing.T) {
+	var out bytes.Buffer
+	err := WriteWorkspaceStatusTable(&out, []WorkspaceStatus{
+		{WorkspaceFolder: "/work", ContainerID: "0123456789abcdef", State: "running", Image: "vsc-work", PortForwards: []string{"localhost:8080"}},
+		{WorkspaceFolder: "/stale", PortForwards: []string{}},
+	})
+	if err != nil {
+		t.Fatalf("WriteWorkspaceStatusTable failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("want header and 2 rows, but got: %s", out.String())
+	}
+	if !strings.Contains(lines[1], "0123456789ab ") || !strings.Contains(lines[1], "localhost:8080") {
+		t.Fatalf("unexpected row: %s", lines[1])
+	}
+	if strings.Count(lines[2], "-") != 7 {
+		t.Fatalf("empty columns must be shown as '-': %s", lines[2])
+	}
+}
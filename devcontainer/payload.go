@@ -0,0 +1,97 @@
+package devcontainer
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/docker"
+)
+
+// コンテナへ転送するファイル
+type payloadFile struct {
+	// ホスト上の転送元ファイルパス
+	source string
+	// コンテナのルートディレクトリからの配置先ファイル名
+	name string
+	mode int64
+}
+
+// コンテナへ転送するファイル群。
+// 1 つの tar アーカイブにまとめ、 1 回のアップロードでコンテナのルートディレクトリへ展開する。
+type containerPayload struct {
+	files []payloadFile
+}
+
+// 転送するファイルを追加する
+func (p *containerPayload) add(source string, name string, mode int64) {
+	p.files = append(p.files, payloadFile{source: source, name: name, mode: mode})
+}
+
+// 追加されたファイルのコンテナ上での配置先一覧を返却する
+func (p *containerPayload) names() []string {
+	names := []string{}
+	for _, file := range p.files {
+		names = append(names, "/"+file.name)
+	}
+	return names
+}
+
+// 追加されたファイルを root 所有の tar アーカイブとして w へ書き込む
+func (p *containerPayload) writeArchive(w io.Writer) error {
+	tw := tar.NewWriter(w)
+	for _, file := range p.files {
+		err := writeArchiveEntry(tw, file)
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func writeArchiveEntry(tw *tar.Writer, file payloadFile) error {
+	f, err := os.Open(file.source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fileInfo, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     file.name,
+		Mode:     file.mode,
+		Size:     fileInfo.Size(),
+		ModTime:  fileInfo.ModTime(),
+		Uid:      0,
+		Gid:      0,
+		Uname:    "root",
+		Gname:    "root",
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// 追加されたファイルをまとめてコンテナへ転送する
+func (p *containerPayload) upload(containerID string) error {
+	if len(p.files) == 0 {
+		return nil
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(p.writeArchive(pw))
+	}()
+	defer pr.Close()
+
+	return docker.Upload(strings.Join(p.names(), ", "), containerID, "/", pr)
+}
@@ -0,0 +1,67 @@
+package devcontainer
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestContainerPayloadWriteArchive(t *testing.T) {
+	tempDir := t.TempDir()
+	binary := filepath.Join(tempDir, "vim_amd64")
+	vimrc := filepath.Join(tempDir, "vimrc")
+	if err := os.WriteFile(binary, []byte("binary"), 0600); err != nil {
+		t.Fatalf("Failed to create binary: %v", err)
+	}
+	if err := os.WriteFile(vimrc, []byte("set number"), 0600); err != nil {
+		t.Fatalf("Failed to create vimrc: %v", err)
+	}
+
+	payload := &containerPayload{}
+	payload.add(binary, "vim", 0755)
+	payload.add(vimrc, "vimrc", 0644)
+
+	var archive bytes.Buffer
+	err := payload.writeArchive(&archive)
+	if err != nil {
+		t.Fatalf("writeArchive failed: %v", err)
+	}
+
+	want := []struct {
+		name    string
+		mode    int64
+		content string
+	}{
+		{"vim", 0755, "binary"},
+		{"vimrc", 0644, "set number"},
+	}
+
+	tr := tar.NewReader(&archive)
+	for _, w := range want {
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Fatalf("Failed to read archive entry: %v", err)
+		}
+		content, _ := io.ReadAll(tr)
+		if hdr.Name != w.name || hdr.Mode != w.mode || string(content) != w.content {
+			t.Fatalf("Unexpected archive entry: %s %o %q", hdr.Name, hdr.Mode, content)
+		}
+		if hdr.Uid != 0 || hdr.Gid != 0 {
+			t.Fatalf("Archive entry must be owned by root: %d:%d", hdr.Uid, hdr.Gid)
+		}
+	}
+	if _, err := tr.Next(); err != io.EOF {
+		t.Fatalf("Unexpected extra archive entry: %v", err)
+	}
+}
+
+func TestContainerPayloadUploadWithoutFiles(t *testing.T) {
+	payload := &containerPayload{}
+	err := payload.upload("not-exist-container")
+	if err != nil {
+		t.Fatalf("upload without files must be no-op: %v", err)
+	}
+}
@@ -38,11 +38,21 @@ func (e *ReadConfigurationError) Error() string {
 //						}
 type ReadConfigurationCommandResult struct {
 	Configuration Configuration `json:"configuration"`
+
+	// `--include-merged-configuration` 指定時のみ出力される、
+	// イメージのメタデータをマージした設定
+	MergedConfiguration MergedConfiguration `json:"mergedConfiguration"`
 }
 
 type Configuration struct {
-	ForwardPorts   []any          `json:"forwardPorts"`
-	ConfigFilePath ConfigFilePath `json:"configFilePath"`
+	ForwardPorts   []any                      `json:"forwardPorts"`
+	ConfigFilePath ConfigFilePath             `json:"configFilePath"`
+	Customizations map[string]json.RawMessage `json:"customizations"`
+}
+
+type MergedConfiguration struct {
+	RemoteUser    string `json:"remoteUser"`
+	ContainerUser string `json:"containerUser"`
 }
 
 type ConfigFilePath struct {
@@ -0,0 +1,115 @@
+package devcontainer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/docker"
+	"github.com/mikoto2000/devcontainer.vim/v3/output"
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
+	"github.com/mikoto2000/devcontainer.vim/v3/tools"
+)
+
+const rebuildOptionNoCache = "--no-cache"
+
+// `rebuild` の引数から `--no-cache` を取り除き、指定有無と残りの引数を返却する
+func ParseRebuildArgs(args []string) (bool, []string) {
+	noCache := false
+	rest := []string{}
+	for _, arg := range args {
+		if arg == rebuildOptionNoCache {
+			noCache = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return noCache, rest
+}
+
+// コンテナを作り直すための `devcontainer up` の引数を組み立てる。
+// 末尾は `--workspace-folder` の値として使うため、最後の要素のまま維持する。
+func buildRebuildUpArgs(args []string, noCache bool) []string {
+	workspaceFolder := args[len(args)-1]
+	upArgs := append([]string{}, args[:len(args)-1]...)
+	upArgs = append(upArgs, "--remove-existing-container")
+	if noCache {
+		upArgs = append(upArgs, "--build-no-cache")
+	}
+	return append(upArgs, workspaceFolder)
+}
+
+// clipboard-data-receiver を停止し、ワークスペース別の設定ディレクトリを削除する。
+// pid ファイルや設定ディレクトリが存在しない場合は何もしない。
+func cleanupWorkspaceState(configDir string) error {
+	pidStringBytes, err := os.ReadFile(filepath.Join(configDir, "pid"))
+	if err == nil {
+		pid, err := strconv.Atoi(strings.TrimSpace(string(pidStringBytes)))
+		if err == nil {
+			fmt.Fprintf(output.Progress(), "clipboard-data-receiver PID: %d\n", pid)
+			tools.KillCdr(pid)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return runner.RemoveAll(configDir)
+}
+
+// 既存のコンテナ(または docker compose プロジェクト)を削除し、
+// マージ済み設定ファイルを再生成してからコンテナを作り直し、 Vim を起動する。
+// Vim の終了後、作り直したコンテナの情報を返却する。
+func Rebuild(
+	services DevcontainerStartUseService,
+	args []string,
+	devcontainerPath string,
+	noCache bool,
+	noCdr bool,
+	noPf bool,
+	noTmux bool,
+	cdrPath string,
+	vimInstallDir string,
+	nvim bool,
+	shell string,
+	configDirForDevcontainer string,
+	userAdditionalConfigFilePath string,
+	vimrc string,
+	vimrcSnippets []string) (StartResult, error) {
+
+	// コマンドライン引数の末尾は `--workspace-folder` の値として使う
+	workspaceFolder := args[len(args)-1]
+	configurationFile := configurationFileFromArgs(args)
+
+	// 1. 既存のコンテナを削除
+	// コンテナが存在しない、 pid ファイルが無いといった場合も作り直しは続行する
+	_, err := Down(args, devcontainerPath, configDirForDevcontainer)
+	if err != nil {
+		var containerNotFoundError *docker.ContainerNotFoundError
+		if !errors.As(err, &containerNotFoundError) && !errors.Is(err, os.ErrNotExist) {
+			return StartResult{}, err
+		}
+		fmt.Fprintf(output.Progress(), "Skip removing container: %v\n", err)
+	}
+
+	// 2. Down で後始末できなかった clipboard-data-receiver と設定ディレクトリを掃除
+	configDir, err := getConfigDirForConfiguration(configDirForDevcontainer, workspaceFolder, configurationFile)
+	if err != nil {
+		return StartResult{}, err
+	}
+	err = cleanupWorkspaceState(configDir)
+	if err != nil {
+		return StartResult{}, err
+	}
+
+	// 3. マージ済み設定ファイルを再生成
+	configFilePath, err := CreateConfigFile(devcontainerPath, workspaceFolder, configurationFile, configDirForDevcontainer, userAdditionalConfigFilePath)
+	if err != nil {
+		return StartResult{}, err
+	}
+
+	// 4. コンテナを作り直して Vim を起動
+	return Start(services, buildRebuildUpArgs(args, noCache), devcontainerPath, noCdr, noPf, noTmux, cdrPath, vimInstallDir, nvim, shell, configFilePath, vimrc, vimrcSnippets)
+}
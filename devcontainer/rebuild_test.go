@@ -0,0 +1,56 @@
+package devcontainer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/util"
+)
+
+func TestParseRebuildArgs(t *testing.T) {
+	noCache, rest := ParseRebuildArgs([]string{"--no-cache", "--log-level", "debug", "."})
+	if !noCache {
+		t.Fatalf("noCache must be true")
+	}
+	if !reflect.DeepEqual(rest, []string{"--log-level", "debug", "."}) {
+		t.Fatalf("unexpected args: %v", rest)
+	}
+}
+
+func TestBuildRebuildUpArgs(t *testing.T) {
+	got := buildRebuildUpArgs([]string{"--log-level", "debug", "."}, true)
+	want := []string{"--log-level", "debug", "--remove-existing-container", "--build-no-cache", "."}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, but got %v", want, got)
+	}
+
+	got = buildRebuildUpArgs([]string{"."}, false)
+	want = []string{"--remove-existing-container", "."}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, but got %v", want, got)
+	}
+}
+
+func TestCleanupWorkspaceStateWithoutPidFile(t *testing.T) {
+	_, _, _, configDirForDevcontainer := createTempAppDirs(t)
+	configDir := createWorkspaceConfigDir(t, configDirForDevcontainer, "/work", "", "")
+	if err := os.Remove(filepath.Join(configDir, "pid")); err != nil {
+		t.Fatalf("failed to remove pid file: %v", err)
+	}
+
+	err := cleanupWorkspaceState(configDir)
+	if err != nil {
+		t.Fatalf("cleanupWorkspaceState failed: %v", err)
+	}
+	if util.IsExists(configDir) {
+		t.Fatalf("config dir must be removed: %s", configDir)
+	}
+
+	// 既に削除済みでもエラーにならない
+	err = cleanupWorkspaceState(configDir)
+	if err != nil {
+		t.Fatalf("cleanupWorkspaceState failed: %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tailscale/hujson"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/docker"
+	"github.com/mikoto2000/devcontainer.vim/v3/output"
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
+)
+
+// 設定ファイル中で、コンテナ上のユーザーのホームディレクトリに置換されるプレースホルダー
+const RemoteHomePlaceholder = "{{ remoteEnv:HOME }}"
+
+var remoteHomePlaceholderPattern = regexp.MustCompile(`\{\{\s*remoteEnv:HOME\s*\}\}`)
+
+// ホームディレクトリの解決に使用する設定項目
+type remoteUserConfig struct {
+	Image         string            `json:"image"`
+	RemoteUser    string            `json:"remoteUser"`
+	ContainerUser string            `json:"containerUser"`
+	RemoteEnv     map[string]string `json:"remoteEnv"`
+}
+
+// configFileContent 中のプレースホルダーを、コンテナ上のユーザーのホームディレクトリに置換する。
+// コメント中のプレースホルダーは対象にしないよう、標準 JSON としてパースした値の文字列を置換し、
+// 置換した場合は標準 JSON を返却する。
+// プレースホルダーが無い場合は何もしない。
+func resolveRemoteHome(devcontainerPath string, workspaceFolder string, configurationFile string, configFileContent []byte) ([]byte, error) {
+	// Standardize は引数を書き換えるため、コピーを渡す
+	standardized, err := hujson.Standardize(append([]byte{}, configFileContent...))
+	if err != nil {
+		return nil, err
+	}
+	var config any
+	err = json.Unmarshal(standardized, &config)
+	if err != nil {
+		return nil, err
+	}
+	if !containsRemoteHome(config) {
+		return configFileContent, nil
+	}
+
+	home, err := remoteHome(devcontainerPath, workspaceFolder, configurationFile, standardized, true)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(output.Progress(), "Resolve `%s` to `%s`\n", RemoteHomePlaceholder, home)
+	return json.Marshal(replaceRemoteHome(config, home))
+}
+
+// コンテナ上のユーザーのホームディレクトリを返却する。
+//
+// ホームディレクトリは以下の順に解決する。
+//
+//  1. マージ後の設定の `remoteEnv.HOME`
+//  2. `image` が指定されている場合、イメージ上のユーザーのホームディレクトリ
+//  3. ユーザー名からの推測(root の場合は `/root` 、それ以外は `/home/<ユーザー名>`)
+//
+// ユーザーは、マージ後の設定の `remoteUser`, `containerUser` 、
+// `devcontainer read-configuration --include-merged-configuration` が返す
+// イメージのメタデータの `remoteUser`, `containerUser` の順に決める。
+// ユーザーが決まらない場合、イメージの USER を使用し、推測時は root とみなす。
+//
+// イメージからの解決はコンテナを起動し、イメージが無ければ pull するため、
+// queryImage が false の場合と dry-run モードの場合は行わない。
+func remoteHome(devcontainerPath string, workspaceFolder string, configurationFile string, configFileContent []byte, queryImage bool) (string, error) {
+	config, err := remoteUserConfigOf(configFileContent)
+	if err != nil {
+		return "", err
+	}
+
+	home := config.RemoteEnv["HOME"]
+	if home != "" && !remoteHomePlaceholderPattern.MatchString(home) {
+		return home, nil
+	}
+
+	user := config.RemoteUser
+	if user == "" {
+		user = config.ContainerUser
+	}
+	if user == "" {
+		user = remoteUserFromReadConfiguration(devcontainerPath, workspaceFolder, configurationFile)
+	}
+
+	if config.Image != "" && queryImage && !runner.Skip(fmt.Sprintf("resolve home directory by running image `%s`", config.Image)) {
+		home, err := docker.CurrentEngine().ImageHomeDirectory(config.Image, user)
+		if err == nil {
+			return home, nil
+		}
+		fmt.Fprintf(output.Progress(), "Warning: failed to resolve home directory from image `%s`, guess from user: %v\n", config.Image, err)
+	}
+	return homeDirectoryOf(user), nil
+}
+
+// 設定からホームディレクトリの解決に使用する項目を取得する
+func remoteUserConfigOf(configFileContent []byte) (remoteUserConfig, error) {
+	var config remoteUserConfig
+
+	// Standardize は引数を書き換えるため、コピーを渡す
+	standardized, err := hujson.Standardize(append([]byte{}, configFileContent...))
+	if err != nil {
+		return config, err
+	}
+
+	err = json.Unmarshal(standardized, &config)
+	return config, err
+}
+
+// `devcontainer read-configuration` で、イメージのメタデータからユーザー名を取得する。
+// 取得できない場合は空文字を返却する。
+func remoteUserFromReadConfiguration(devcontainerPath string, workspaceFolder string, configurationFile string) string {
+	if devcontainerPath == "" {
+		return ""
+	}
+	readConfigurationArgs := append(readConfigurationArgs(workspaceFolder, configurationFile), "--include-merged-configuration")
+	stdout, err := ReadConfiguration(devcontainerPath, readConfigurationArgs...)
+	if err != nil {
+		return ""
+	}
+	result, err := UnmarshalReadConfigurationCommandResult([]byte(stdout))
+	if err != nil {
+		return ""
+	}
+	if result.MergedConfiguration.RemoteUser != "" {
+		return result.MergedConfiguration.RemoteUser
+	}
+	return result.MergedConfiguration.ContainerUser
+}
+
+// ユーザー名からホームディレクトリを推測して返却する。
+// `user:group` 形式の場合はユーザー部分を使用する。
+func homeDirectoryOf(user string) string {
+	user, _, _ = strings.Cut(user, ":")
+	if user == "" || user == "root" || user == "0" {
+		return "/root"
+	}
+	return "/home/" + user
+}
+
+// value 中の文字列にプレースホルダーが含まれるかを返却する
+func containsRemoteHome(value any) bool {
+	switch v := value.(type) {
+	case string:
+		return remoteHomePlaceholderPattern.MatchString(v)
+	case map[string]any:
+		for _, child := range v {
+			if containsRemoteHome(child) {
+				return true
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if containsRemoteHome(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// value 中の文字列のプレースホルダーを home に置換した値を返却する
+func replaceRemoteHome(value any, home string) any {
+	switch v := value.(type) {
+	case string:
+		return remoteHomePlaceholderPattern.ReplaceAllLiteralString(v, home)
+	case map[string]any:
+		replaced := map[string]any{}
+		for key, child := range v {
+			replaced[key] = replaceRemoteHome(child, home)
+		}
+		return replaced
+	case []any:
+		replaced := make([]any, len(v))
+		for i, child := range v {
+			replaced[i] = replaceRemoteHome(child, home)
+		}
+		return replaced
+	}
+	return value
+}
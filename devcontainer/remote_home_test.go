@@ -0,0 +1,176 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tailscale/hujson"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
+)
+
+// want と got が、 JWCC として同じ値を表すかを検証する
+func assertJSONEqual(t *testing.T, want string, got []byte) {
+	t.Helper()
+	var wantValue, gotValue any
+	wantJSON, err := hujson.Standardize([]byte(want))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	json.Unmarshal(wantJSON, &wantValue)
+	gotJSON, err := hujson.Standardize(append([]byte{}, got...))
+	if err != nil {
+		t.Fatalf("error: %v: %s", err, got)
+	}
+	json.Unmarshal(gotJSON, &gotValue)
+	if !reflect.DeepEqual(wantValue, gotValue) {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+}
+
+func TestResolveRemoteHome(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "remoteUser",
+			content: `{"remoteUser": "node", "mounts": ["target={{ remoteEnv:HOME }}/.vim"]}`,
+			want:    `{"remoteUser": "node", "mounts": ["target=/home/node/.vim"]}`,
+		},
+		{
+			name:    "root user",
+			content: `{"remoteUser": "root", "mounts": ["target={{remoteEnv:HOME}}/.vim"]}`,
+			want:    `{"remoteUser": "root", "mounts": ["target=/root/.vim"]}`,
+		},
+		{
+			name:    "containerUser",
+			content: `{"containerUser": "dev", /* JWCC */ "mounts": ["target={{ remoteEnv:HOME }}/.vim"],}`,
+			want:    `{"containerUser": "dev", "mounts": ["target=/home/dev/.vim"]}`,
+		},
+		{
+			name:    "remoteEnv.HOME",
+			content: `{"remoteUser": "node", "remoteEnv": {"HOME": "/workspace/home"}, "mounts": ["target={{ remoteEnv:HOME }}/.vim"]}`,
+			want:    `{"remoteUser": "node", "remoteEnv": {"HOME": "/workspace/home"}, "mounts": ["target=/workspace/home/.vim"]}`,
+		},
+		{
+			name:    "no placeholder",
+			content: `{"remoteUser": "node"}`,
+			want:    `{"remoteUser": "node"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveRemoteHome("devcontainer-not-found", t.TempDir(), "", []byte(tt.content))
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+			assertJSONEqual(t, tt.want, got)
+		})
+	}
+}
+
+func TestResolveRemoteHomeFallsBackToRoot(t *testing.T) {
+	// read-configuration に失敗した場合は root とみなす
+	got, err := resolveRemoteHome("devcontainer-not-found", t.TempDir(), "", []byte(`{"mounts": ["target={{ remoteEnv:HOME }}/.ssh"]}`))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	assertJSONEqual(t, `{"mounts": ["target=/root/.ssh"]}`, got)
+}
+
+func TestResolveRemoteHomeFromImage(t *testing.T) {
+	tests := []struct {
+		name    string
+		engine  fakeEngine
+		content string
+		want    string
+	}{
+		{
+			name:    "numeric uid",
+			engine:  fakeEngine{homeResult: "/home/app"},
+			content: `{"image": "example", "remoteUser": "1000", "mounts": ["target={{ remoteEnv:HOME }}/.vim"]}`,
+			want:    `{"image": "example", "remoteUser": "1000", "mounts": ["target=/home/app/.vim"]}`,
+		},
+		{
+			// remoteUser が無い場合は、イメージの USER のホームディレクトリ
+			name:    "image USER",
+			engine:  fakeEngine{homeResult: "/home/node"},
+			content: `{"image": "example", "mounts": ["target={{ remoteEnv:HOME }}/.vim"]}`,
+			want:    `{"image": "example", "mounts": ["target=/home/node/.vim"]}`,
+		},
+		{
+			name:    "fall back to guess",
+			engine:  fakeEngine{homeErr: errors.New("image not found")},
+			content: `{"image": "example", "remoteUser": "dev:dev", "mounts": ["target={{ remoteEnv:HOME }}/.vim"]}`,
+			want:    `{"image": "example", "remoteUser": "dev:dev", "mounts": ["target=/home/dev/.vim"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeEngine(t, tt.engine)
+			got, err := resolveRemoteHome("", t.TempDir(), "", []byte(tt.content))
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+			assertJSONEqual(t, tt.want, got)
+		})
+	}
+}
+
+func TestResolveRemoteHomeIgnoresPlaceholderInComment(t *testing.T) {
+	// コメント中のプレースホルダーでは、ホームディレクトリを解決しない
+	useFakeEngine(t, fakeEngine{homeErr: errors.New("must not query image")})
+	content := `{
+		// "mounts": ["target={{ remoteEnv:HOME }}/.vim"]
+		"image": "example"
+	}`
+	got, err := resolveRemoteHome("", t.TempDir(), "", []byte(content))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if string(got) != content {
+		t.Fatalf("want unchanged content, got %s", got)
+	}
+}
+
+func TestResolveRemoteHomeDoesNotRunImageInDryRun(t *testing.T) {
+	useFakeEngine(t, fakeEngine{homeResult: "/home/app"})
+	runner.SetDryRun(true)
+	defer runner.SetDryRun(false)
+
+	got, err := resolveRemoteHome("", t.TempDir(), "", []byte(`{"image": "example", "remoteUser": "dev", "mounts": ["target={{ remoteEnv:HOME }}/.vim"]}`))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	assertJSONEqual(t, `{"image": "example", "remoteUser": "dev", "mounts": ["target=/home/dev/.vim"]}`, got)
+}
+
+func TestMergeConfigResolvesRemoteHome(t *testing.T) {
+	// config show ではイメージのコンテナを起動せず、ユーザー名から推測する
+	useFakeEngine(t, fakeEngine{homeResult: "/home/other"})
+	workspaceFolder := t.TempDir()
+	devcontainerDir := filepath.Join(workspaceFolder, ".devcontainer")
+	os.MkdirAll(devcontainerDir, 0777)
+	os.WriteFile(filepath.Join(devcontainerDir, "devcontainer.json"), []byte(`{"image": "example", "remoteUser": "vscode"}`), 0666)
+	os.WriteFile(filepath.Join(devcontainerDir, "devcontainer.vim.json"), []byte(`{"mounts": ["target={{ remoteEnv:HOME }}/.vim"]}`), 0666)
+
+	_, mergedConfig, err := MergeConfig("", workspaceFolder, "", "")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	mounts := mergedConfig.Value.(map[string]any)["mounts"].([]any)
+	if mounts[0] != "target=/home/vscode/.vim" {
+		t.Fatalf("want resolved mount, got %v", mounts[0])
+	}
+	if got := mergedConfig.Changes[len(mergedConfig.Changes)-1].New.([]any); got[0] != "target=/home/vscode/.vim" {
+		t.Fatalf("want resolved change, got %v", got)
+	}
+}
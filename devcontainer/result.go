@@ -0,0 +1,36 @@
+package devcontainer
+
+// `start`, `run`, `rebuild` の実行結果
+//
+// Example: {"containerId":"7278c789a975","remoteUser":"vscode","workspaceFolder":"/workspaces/project","cdrPort":5678,"forwardedPorts":[8080]}
+type StartResult struct {
+	ContainerID     string `json:"containerId"`
+	RemoteUser      string `json:"remoteUser,omitempty"`
+	WorkspaceFolder string `json:"workspaceFolder,omitempty"`
+	CdrPort         int    `json:"cdrPort"`
+	ForwardedPorts  []int  `json:"forwardedPorts"`
+}
+
+// `stop` の実行結果
+//
+// 対象のコンテナが存在せず何もしなかった場合、 Stopped は false となり、 Message に理由を設定する。
+type StopResult struct {
+	WorkspaceFolder string `json:"workspaceFolder"`
+	ContainerID     string `json:"containerId,omitempty"`
+	ComposeProject  string `json:"composeProject,omitempty"`
+	Stopped         bool   `json:"stopped"`
+	Message         string `json:"message,omitempty"`
+}
+
+// `down` の実行結果
+//
+// 対象のコンテナが存在せず何もしなかった場合、 Removed は false となり、 Message に理由を設定する。
+type DownResult struct {
+	WorkspaceFolder string `json:"workspaceFolder"`
+	ContainerID     string `json:"containerId,omitempty"`
+	ComposeProject  string `json:"composeProject,omitempty"`
+	Removed         bool   `json:"removed"`
+	CdrPid          int    `json:"cdrPid,omitempty"`
+	ConfigDir       string `json:"configDir,omitempty"`
+	Message         string `json:"message,omitempty"`
+}
@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strings"
 
+	"github.com/mikoto2000/devcontainer.vim/v3/docker"
+	"github.com/mikoto2000/devcontainer.vim/v3/output"
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
 	"github.com/mikoto2000/devcontainer.vim/v3/tools"
 )
 
@@ -32,7 +34,8 @@ func (e *ChmodError) Error() string {
 	return e.msg
 }
 
-// docker run で、ワンショットでコンテナを立ち上げる
+// docker run で、ワンショットでコンテナを立ち上げる。
+// Vim の終了後、起動したコンテナの情報を返却する。
 func Run(
 	args []string,
 	noCdr bool,
@@ -44,10 +47,10 @@ func Run(
 	shell string,
 	configDirForDocker string,
 	vimrc string,
-	defaultRunargs []string) error {
+	defaultRunargs []string) (StartResult, error) {
 
 	// コンテナのセットアップ
-	containerID, vimFileName, tmuxFileName, sendToTCP, containerArch, useSystemVim, useSystemTmux, cdrPid, cdrConfigDir, err := setupContainer(
+	containerID, cdrPid, cdrConfigDir, err := setupContainer(
 		args,
 		noCdr,
 		noPf,
@@ -59,6 +62,13 @@ func Run(
 		vimrc,
 		defaultRunargs)
 
+	result := StartResult{ContainerID: containerID, ForwardedPorts: []int{}}
+
+	// dry-run の場合、コンテナも clipboard-data-receiver も起動していないため後片付けは不要
+	if runner.IsDryRun() {
+		return result, err
+	}
+
 	// 後片付け
 	// clipboard-data-receiver を停止
 	defer func() {
@@ -67,7 +77,7 @@ func Run(
 			fmt.Fprintf(os.Stderr, "Container stop error: %s\n", err)
 		}
 
-		err = os.RemoveAll(cdrConfigDir)
+		err = runner.RemoveAll(cdrConfigDir)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Cache remove error: %s\n", err)
 		}
@@ -76,28 +86,33 @@ func Run(
 	// コンテナ停止
 	defer func() {
 		// `docker stop <dockerrun 時に標準出力に表示される CONTAINER ID>`
-		fmt.Printf("Stop container(Async) %s.\n", containerID)
-		err = exec.Command(containerCommand, "stop", containerID).Start()
+		fmt.Fprintf(output.Progress(), "Stop container(Async) %s.\n", containerID)
+		err = docker.Stop(containerID)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Container stop error: %s\n", err)
 		}
 	}()
 
+	if err != nil {
+		return result, err
+	}
+	if cdrConfigDir != "" {
+		_, result.CdrPort = readCdrState(cdrConfigDir)
+	}
+
 	// コンテナへ接続
 	// `docker exec <dockerrun 時に標準出力に表示される CONTAINER ID> /Vim-AppImage`
 
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	sendToTCPName := filepath.Base(sendToTCP)
-	dockerRunVimArgs, err := dockerRunVimArgs(containerID, vimFileName, tmuxFileName, sendToTCPName, containerArch, useSystemVim, useSystemTmux, noTmux, shell, configDirForDocker)
-	if err != nil {
-		return err
-	}
-	fmt.Printf("Start vim: `%s \"%s\"`\n", containerCommand, strings.Join(dockerRunVimArgs, "\" \""))
-	dockerExec := exec.CommandContext(ctx, containerCommand, dockerRunVimArgs...)
+	dockerRunVimArgs := buildDockerRunVimExecArgs(containerID, shell)
+	containerCommand := docker.CurrentEngine().Command()
+	fmt.Fprintf(output.Progress(), "Start vim: `%s \"%s\"`\n", containerCommand, strings.Join(dockerRunVimArgs, "\" \""))
+	dockerExec := runner.CommandContext(ctx, containerCommand, dockerRunVimArgs...)
 	dockerExec.Stdin = os.Stdin
-	dockerExec.Stdout = os.Stdout
+	// JSON 形式の場合、 Vim の画面で実行結果の JSON を汚さないよう標準エラー出力へ描画する
+	dockerExec.Stdout = output.Progress()
 	dockerExec.Stderr = os.Stderr
 	dockerExec.Cancel = func() error {
 		fmt.Fprintf(os.Stderr, "Receive SIGINT.\n")
@@ -106,10 +121,10 @@ func Run(
 
 	err = dockerExec.Run()
 	if err != nil {
-		return err
+		return result, err
 	}
 
-	return nil
+	return result, nil
 }
 
 // コンテナを起動し、コンテナIDを返す
@@ -121,9 +136,10 @@ func startContainer(args []string, defaultRunargs []string) (string, error) {
 	}
 	devcontainerRunArgs = append(devcontainerRunArgs, args...)
 	devcontainerRunArgs = append(devcontainerRunArgs, devcontainerRunArgsSuffix...)
-	fmt.Printf("run container: `%s \"%s\"`\n", containerCommand, strings.Join(devcontainerRunArgs, "\" \""))
+	containerCommand := docker.CurrentEngine().Command()
+	fmt.Fprintf(output.Progress(), "run container: `%s \"%s\"`\n", containerCommand, strings.Join(devcontainerRunArgs, "\" \""))
 
-	dockerRunCommand := exec.Command(containerCommand, devcontainerRunArgs...)
+	dockerRunCommand := runner.Command(containerCommand, devcontainerRunArgs...)
 	containerIDRaw, err := dockerRunCommand.Output()
 	containerID := string(containerIDRaw)
 	if err != nil {
@@ -131,10 +147,13 @@ func startContainer(args []string, defaultRunargs []string) (string, error) {
 		fmt.Fprintln(os.Stderr, string(containerID))
 		return "", &ContainerStartError{msg: "Container start error."}
 	}
+	if runner.IsDryRun() {
+		return "", nil
+	}
 
 	containerID = strings.ReplaceAll(containerID, "\n", "")
 	containerID = strings.ReplaceAll(containerID, "\r", "")
-	fmt.Printf("Container started. id: %s\n", containerID)
+	fmt.Fprintf(output.Progress(), "Container started. id: %s\n", containerID)
 
 	return containerID, nil
 }
@@ -142,7 +161,7 @@ func startContainer(args []string, defaultRunargs []string) (string, error) {
 // clipboard-data-receiverを起動する
 func startClipboardReceiver(cdrPath, configDirForDocker, containerID string) (int, int, string, error) {
 	configDirForCdr := filepath.Join(configDirForDocker, containerID)
-	err := os.MkdirAll(configDirForCdr, 0744)
+	err := runner.MkdirAll(configDirForCdr, 0744)
 	if err != nil {
 		return 0, 0, configDirForCdr, err
 	}
@@ -150,7 +169,7 @@ func startClipboardReceiver(cdrPath, configDirForDocker, containerID string) (in
 	if err != nil {
 		return 0, 0, configDirForCdr, err
 	}
-	fmt.Printf("Started clipboard-data-receiver with pid: %d, port: %d\n", pid, port)
+	fmt.Fprintf(output.Progress(), "Started clipboard-data-receiver with pid: %d, port: %d\n", pid, port)
 	return pid, port, configDirForCdr, nil
 }
 
@@ -164,25 +183,31 @@ func setupContainer(
 	nvim bool,
 	configDirForDocker string,
 	vimrc string,
-	defaultRunargs []string) (string, string, string, string, string, bool, bool, int, string, error) {
+	defaultRunargs []string) (string, int, string, error) {
 
 	// 1. コンテナを起動
 	containerID, err := startContainer(args, defaultRunargs)
 	if err != nil {
-		return "", "", "", "", "", false, false, 0, "", err
+		return "", 0, "", err
+	}
+
+	// dry-run の場合、コンテナが存在しないため以降の処理は行えない
+	if runner.IsDryRun() {
+		return containerID, 0, "", nil
 	}
 
 	// 2. コンテナアーキテクチャを取得
 	containerArch, err := getContainerArch(containerID)
 	if err != nil {
-		return containerID, "", "", "", "", false, false, 0, "", err
+		return containerID, 0, "", err
 	}
 
-	// 3. port-forwarderをインストール
+	// 3. port-forwarderを転送対象に追加
+	payload := &containerPayload{}
 	if !noPf {
-		err = installPortForwarder(containerID, vimInstallDir, containerArch)
+		err = installPortForwarder(vimInstallDir, containerArch, payload)
 		if err != nil {
-			return containerID, "", "", "", containerArch, false, false, 0, "", err
+			return containerID, 0, "", err
 		}
 	}
 
@@ -193,30 +218,41 @@ func setupContainer(
 	if !noCdr {
 		pid, port, configDirForCdr, err = startClipboardReceiver(cdrPath, configDirForDocker, containerID)
 		if err != nil {
-			return containerID, "", "", "", containerArch, false, false, pid, configDirForCdr, err
+			return containerID, pid, configDirForCdr, err
 		}
 	}
 
-	// 5. Vimの検出とインストール
-	vimFileName, useSystemVim, err := setupVim(containerID, vimInstallDir, nvim, containerArch)
+	// 5. Vimの検出
+	vimFileName, useSystemVim, err := setupVim(containerID, vimInstallDir, nvim, containerArch, payload)
 	if err != nil {
-		return containerID, vimFileName, "", "", containerArch, useSystemVim, false, pid, configDirForCdr, err
+		return containerID, pid, configDirForCdr, err
 	}
 
 	tmuxFileName := ""
 	useSystemTmux := false
 	if !noTmux {
-		tmuxFileName, useSystemTmux, err = setupTmux(containerID, vimInstallDir, containerArch)
+		tmuxFileName, useSystemTmux, err = setupTmux(containerID, vimInstallDir, containerArch, payload)
 		if err != nil {
-			return containerID, vimFileName, "", "", containerArch, useSystemVim, false, pid, configDirForCdr, err
+			return containerID, pid, configDirForCdr, err
 		}
 	}
 
-	// 6. Vimファイルを転送
-	sendToTCP, err := transferVimFiles(containerID, configDirForDocker, vimrc, noCdr, port, vimFileName == "nvim")
+	// 6. Vimファイルと Vim 起動スクリプトの作成
+	sendToTCP, err := stageVimFiles(configDirForDocker, vimrc, nil, noCdr, port, vimFileName == "nvim", payload)
+	if err != nil {
+		return containerID, pid, configDirForCdr, err
+	}
+	vimLaunchScript, err := createVimRunScript(configDirForDocker, vimFileName, tmuxFileName, filepath.Base(sendToTCP), containerArch, useSystemVim, useSystemTmux, noTmux)
+	if err != nil {
+		return containerID, pid, configDirForCdr, err
+	}
+	payload.add(vimLaunchScript, "VimRun.sh", 0755)
+
+	// 7. 転送対象のファイルをまとめてコンテナへ転送
+	err = payload.upload(containerID)
 	if err != nil {
-		return containerID, vimFileName, tmuxFileName, sendToTCP, containerArch, useSystemVim, useSystemTmux, pid, configDirForCdr, err
+		return containerID, pid, configDirForCdr, err
 	}
 
-	return containerID, vimFileName, tmuxFileName, sendToTCP, containerArch, useSystemVim, useSystemTmux, pid, configDirForCdr, nil
+	return containerID, pid, configDirForCdr, nil
 }
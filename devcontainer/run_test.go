@@ -24,7 +24,7 @@ func TestSetupContainer(t *testing.T) {
 	noCdr := false
 	noPf := false
 
-	containerID, _, _, _, _, _, _, _, _, err := setupContainer(
+	containerID, _, _, err := setupContainer(
 		[]string{"alpine:latest"},
 		noCdr,
 		noPf,
@@ -49,7 +49,7 @@ func TestSetupContainer(t *testing.T) {
 	defer func() {
 		// `docker stop <dockerrun 時に標準出力に表示される CONTAINER ID>`
 		fmt.Printf("Stop container(Async) %s.\n", containerID)
-		err = exec.Command(containerCommand, "stop", containerID).Start()
+		err = docker.Stop(containerID)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Container stop error: %s\n", err)
 		}
@@ -102,7 +102,7 @@ func TestStartContainer(t *testing.T) {
 
 	// 後片付け
 	defer func() {
-		exec.Command(containerCommand, "stop", containerID).Start()
+		docker.Stop(containerID)
 	}()
 
 	// コンテナが実際に起動していることを確認
@@ -135,7 +135,7 @@ func TestGetContainerArch(t *testing.T) {
 	}
 
 	defer func() {
-		exec.Command(containerCommand, "stop", containerID).Start()
+		docker.Stop(containerID)
 	}()
 
 	// アーキテクチャを取得
@@ -178,11 +178,11 @@ func TestSetupVimWithSystemVim(t *testing.T) {
 	}
 
 	defer func() {
-		exec.Command(containerCommand, "stop", containerID).Start()
+		docker.Stop(containerID)
 	}()
 
 	// Vimがシステムにインストールされているかどうかをテスト
-	vimFileName, useSystemVim, err := setupVim(containerID, "", false, "amd64")
+	vimFileName, useSystemVim, err := setupVim(containerID, "", false, "amd64", &containerPayload{})
 	if err != nil {
 		t.Fatalf("setupVim failed: %v", err)
 	}
@@ -263,7 +263,7 @@ func TestRunIntegration(t *testing.T) {
 		// 実際のテストでは、コンテナが起動してVimが実行可能な状態になることを確認
 
 		// setupContainer部分のみをテスト
-		containerID, _, _, _, _, _, _, cdrPid, cdrConfigDir, err := setupContainer(
+		containerID, cdrPid, cdrConfigDir, err := setupContainer(
 			args,
 			noCdr,
 			noPf,
@@ -283,7 +283,7 @@ func TestRunIntegration(t *testing.T) {
 		// クリーンアップ
 		tools.KillCdr(cdrPid)
 		os.RemoveAll(cdrConfigDir)
-		exec.Command(containerCommand, "stop", containerID).Start()
+		docker.Stop(containerID)
 
 		done <- nil
 	}()
@@ -6,13 +6,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/mikoto2000/devcontainer.vim/v3/docker"
+	"github.com/mikoto2000/devcontainer.vim/v3/output"
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
 	"github.com/mikoto2000/devcontainer.vim/v3/tools"
 	"github.com/mikoto2000/devcontainer.vim/v3/util"
 )
@@ -20,43 +23,46 @@ import (
 const portForwarderMarkerDir = "~/.config/devcontainer.vim/pf"
 
 type DevcontainerStartUseService interface {
-	StartVim(containerID string, devcontainerPath string, workspaceFolder string, vimFileName string, tmuxFileName string, sendToTCP string, containerArch string, useSystemVim bool, useSystemTmux bool, noTmux bool, shell string, configFilePathForDevcontainer string) error
+	StartVim(containerID string, devcontainerPath string, workspaceFolder string, shell string) error
 }
 
 type DefaultDevcontainerStartUseService struct{}
 
-func (s DefaultDevcontainerStartUseService) StartVim(containerID string, devcontainerPath string, workspaceFolder string, vimFileName string, tmuxFileName string, sendToTCP string, containerArch string, useSystemVim bool, useSystemTmux bool, noTmux bool, shell string, configDirForDevcontainer string) error {
-	return startVim(containerID, devcontainerPath, workspaceFolder, vimFileName, tmuxFileName, sendToTCP, containerArch, useSystemVim, useSystemTmux, noTmux, shell, configDirForDevcontainer)
+func (s DefaultDevcontainerStartUseService) StartVim(containerID string, devcontainerPath string, workspaceFolder string, shell string) error {
+	return startVim(containerID, devcontainerPath, workspaceFolder, shell)
 }
 
 var devcontainreArgsPrefix = []string{"up"}
 
-// devcontainer up でコンテナを起動し、コンテナIDを返す
-func startDevcontainer(devcontainerPath string, args []string, configFilePath string, workspaceFolder string) (string, error) {
+// devcontainer up でコンテナを起動し、その実行結果を返す
+func startDevcontainer(devcontainerPath string, args []string, configFilePath string, workspaceFolder string) (UpCommandResult, error) {
 	// 末尾以外のものはそのまま `devcontainer up` への引数として渡す
 	userArgs := args[0 : len(args)-1]
 	userArgs = append(userArgs, "--override-config", configFilePath, "--workspace-folder", workspaceFolder)
+	userArgs = append(userArgs, dockerPathArgs()...)
 	devcontainerArgs := append(devcontainreArgsPrefix, userArgs...)
-	fmt.Printf("run container: `%s \"%s\"`\n", devcontainerPath, strings.Join(devcontainerArgs, "\" \""))
+	fmt.Fprintf(output.Progress(), "run container: `%s \"%s\"`\n", devcontainerPath, strings.Join(devcontainerArgs, "\" \""))
 
-	dockerRunCommand := exec.Command(devcontainerPath, devcontainerArgs...)
+	dockerRunCommand := runner.Command(devcontainerPath, devcontainerArgs...)
 	dockerRunCommand.Stderr = os.Stderr
 
 	stdout, err := dockerRunCommand.Output()
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Container start error.")
-		return "", err
+		return UpCommandResult{}, err
+	}
+	if runner.IsDryRun() {
+		return UpCommandResult{}, nil
 	}
 
 	upCommandResult, err := UnmarshalUpCommandResult(stdout)
 	if err != nil {
-		return "", err
+		return UpCommandResult{}, err
 	}
 
-	containerID := upCommandResult.ContainerID
-	fmt.Printf("finished devcontainer up: %s\n", upCommandResult)
+	fmt.Fprintf(output.Progress(), "finished devcontainer up: %s\n", upCommandResult)
 
-	return containerID, nil
+	return upCommandResult, nil
 }
 
 // devcontainer用のclipboard-data-receiverを起動する
@@ -65,7 +71,7 @@ func startClipboardReceiverForDevcontainer(cdrPath, configDirForDevcontainer str
 	if err != nil {
 		return 0, 0, err
 	}
-	fmt.Printf("Started clipboard-data-receiver with pid: %d, port: %d\n", pid, port)
+	fmt.Fprintf(output.Progress(), "Started clipboard-data-receiver with pid: %d, port: %d\n", pid, port)
 	return pid, port, nil
 }
 
@@ -78,7 +84,7 @@ func listRunningPortForwarders(containerID string) ([]string, error) {
 	portForwarders := strings.Split(strings.TrimSpace(psOut), "\n")
 	portForwarders = util.RemoveEmptyString(portForwarders)
 
-	fmt.Printf("Running port-forwarders: %s\n", portForwarders)
+	fmt.Fprintf(output.Progress(), "Running port-forwarders: %s\n", portForwarders)
 	return portForwarders, nil
 }
 
@@ -93,28 +99,33 @@ func listPortForwarderMarkers(containerID string) ([]string, error) {
 	return forwardConfigs, nil
 }
 
-func startPortForwarders(ctx context.Context, containerID, containerIp, devcontainerPath, workspaceFolder string) error {
-	fmt.Println("Start port-forwarder in container.")
+// forwardPorts ごとに port-forwarder を起動し、ホスト側で待ち受けるポートの一覧を返却する
+func startPortForwarders(ctx context.Context, containerID, containerIp, devcontainerPath, workspaceFolder, configurationFile string) ([]int, error) {
+	fmt.Fprintln(output.Progress(), "Start port-forwarder in container.")
 
 	// forwardPorts を解釈
-	configurationString, err := ReadConfiguration(devcontainerPath, "--workspace-folder", workspaceFolder)
+	configurationString, err := ReadConfiguration(devcontainerPath, readConfigurationArgs(workspaceFolder, configurationFile)...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	forwardConfigs, err := GetForwardPorts(configurationString)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// 解釈した forwardPort ごとに port-forwarder を起動する
+	forwardedPorts := []int{}
 	for _, fc := range forwardConfigs {
 
 		// コンテナ側の port-forwarder の起動
-		fmt.Printf("%s %s %s %s %s %s %s.\n", devcontainerPath, "exec", "--workspace-folder", ".", "sh", "-c", "/port-forwarder -l 0.0.0.0:0 -f "+fc.Host+":"+fc.Port)
-		dockerExecPortForwarder := exec.CommandContext(ctx, devcontainerPath, "exec", "--workspace-folder", ".", "sh", "-c", "/port-forwarder -l 0.0.0.0:0 -f "+fc.Host+":"+fc.Port)
+		// 同じワークスペースに複数のコンテナがあり得るため、コンテナ ID で対象を指定する
+		portForwarderArgs := append([]string{"exec", "--container-id", containerID}, dockerPathArgs()...)
+		portForwarderArgs = append(portForwarderArgs, "sh", "-c", "/port-forwarder -l 0.0.0.0:0 -f "+fc.Host+":"+fc.Port)
+		fmt.Fprintf(output.Progress(), "%s %s.\n", devcontainerPath, strings.Join(portForwarderArgs, " "))
+		dockerExecPortForwarder := runner.CommandContext(ctx, devcontainerPath, portForwarderArgs...)
 		portOut, err := dockerExecPortForwarder.StdoutPipe()
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		dockerExecPortForwarder.Cancel = func() error {
@@ -124,8 +135,9 @@ func startPortForwarders(ctx context.Context, containerID, containerIp, devconta
 
 		err = dockerExecPortForwarder.Start()
 		if err != nil {
-			return err
+			return nil, err
 		}
+		forwardedPorts = appendPort(forwardedPorts, fc.Port)
 
 		go func(host string, containerPort string) {
 			reader := bufio.NewReader(portOut)
@@ -133,12 +145,12 @@ func startPortForwarders(ctx context.Context, containerID, containerIp, devconta
 				port, err := reader.ReadString('\n')
 				if err != nil {
 					if err != io.EOF {
-						fmt.Println("Error reading from stdout:", err)
+						fmt.Fprintln(output.Progress(), "Error reading from stdout:", err)
 					}
 					break
 				}
 				port = strings.TrimSpace(port)
-				fmt.Printf("port-forwarder started: %s:%s %s\n", containerIp, port, host+":"+containerPort)
+				fmt.Fprintf(output.Progress(), "port-forwarder started: %s:%s %s\n", containerIp, port, host+":"+containerPort)
 
 				// forwardPorts の内容を `~/.config/devcontainer.vim/pf` ディテク取りに「<転送先>_<リッスンアドレス＆ポート>」の形式で配置する
 				_, err = docker.Exec(containerID, "sh", "-c", "mkdir -p "+portForwarderMarkerDir+" && touch "+portForwarderMarkerDir+"/"+host+":"+containerPort+"_"+containerIp+":"+port)
@@ -152,10 +164,13 @@ func startPortForwarders(ctx context.Context, containerID, containerIp, devconta
 		}(fc.Host, fc.Port)
 	}
 
-	return nil
+	return forwardedPorts, nil
 }
 
-func restorePortForwarders(containerIp string, forwardConfigs []string) {
+// マーカーファイルの内容をもとにホスト側のポートフォワーディングを復元し、
+// ホスト側で待ち受けるポートの一覧を返却する
+func restorePortForwarders(containerIp string, forwardConfigs []string) []int {
+	forwardedPorts := []int{}
 	for _, forwardConfig := range forwardConfigs {
 		containerSrcPort, containerDestPort, err := parsePortForwarderMarker(forwardConfig)
 		if err != nil {
@@ -163,11 +178,28 @@ func restorePortForwarders(containerIp string, forwardConfigs []string) {
 			continue
 		}
 
-		go func() {
-			fmt.Printf("listen: %s, forward: %s.\n", "0.0.0.0:"+containerSrcPort, containerIp+":"+containerDestPort)
-			util.StartForwarding("0.0.0.0:"+containerSrcPort, containerIp+":"+containerDestPort)
-		}()
+		// 別の devcontainer.vim などがホスト側のポートを使用中の場合はスキップする
+		listenAddr := "0.0.0.0:" + containerSrcPort
+		listener, err := net.Listen("tcp", listenAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skip port-forwarding %s: host port is already in use: %v\n", listenAddr, err)
+			continue
+		}
+
+		forwardedPorts = appendPort(forwardedPorts, containerSrcPort)
+		fmt.Fprintf(output.Progress(), "listen: %s, forward: %s.\n", listenAddr, containerIp+":"+containerDestPort)
+		go util.ServeForwarding(listener, containerIp+":"+containerDestPort)
+	}
+	return forwardedPorts
+}
+
+// 数値として解釈できるポートのみを追加する
+func appendPort(ports []int, port string) []int {
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		return ports
 	}
+	return append(ports, portNumber)
 }
 
 func parsePortForwarderMarker(forwardConfig string) (string, string, error) {
@@ -191,41 +223,41 @@ func parsePortForwarderMarker(forwardConfig string) (string, string, error) {
 	return scs[1], scd[1], nil
 }
 
-// port-forwardingの設定を行う
-func setupPortForwarding(ctx context.Context, containerID, devcontainerPath, workspaceFolder string) error {
+// port-forwardingの設定を行い、ホスト側で待ち受けるポートの一覧を返却する
+func setupPortForwarding(ctx context.Context, containerID, devcontainerPath, workspaceFolder, configurationFile string) ([]int, error) {
 	// コンテナの IP アドレスを取得
 	containerIp, err := docker.Exec(containerID, "sh", "-c", "hostname -i")
 	if err != nil {
-		return errors.New("コンテナ上での hostname 実行に失敗しました。コンテナに hostname コマンドがインストールされている必要があります")
+		return nil, errors.New("コンテナ上での hostname 実行に失敗しました。コンテナに hostname コマンドがインストールされている必要があります")
 	}
 	containerIp = strings.TrimSpace(containerIp)
 
 	portForwarders, err := listRunningPortForwarders(containerID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	forwardConfigs, err := listPortForwarderMarkers(containerID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(portForwarders) == 0 {
-		return startPortForwarders(ctx, containerID, containerIp, devcontainerPath, workspaceFolder)
+		return startPortForwarders(ctx, containerID, containerIp, devcontainerPath, workspaceFolder, configurationFile)
 	}
 
 	if len(forwardConfigs) == 0 {
 		fmt.Fprintf(os.Stderr, "port-forwarder process exists but marker files are missing. Restart port-forwarder setup.\n")
-		return startPortForwarders(ctx, containerID, containerIp, devcontainerPath, workspaceFolder)
+		return startPortForwarders(ctx, containerID, containerIp, devcontainerPath, workspaceFolder, configurationFile)
 	}
 
-	fmt.Println("port-forwarder already running.")
-	restorePortForwarders(containerIp, forwardConfigs)
-
-	return nil
+	fmt.Fprintln(output.Progress(), "port-forwarder already running.")
+	return restorePortForwarders(containerIp, forwardConfigs), nil
 }
 
 // devcontainer でコンテナを立ち上げ、 Vim を転送し、実行する。
+// Vim の終了後、起動したコンテナの情報を返却する。
 // 既存実装の都合上、configFilePath から configDirForDevcontainer を抽出している
+// vimrcSnippets は vimrc に追記してからコンテナへ転送する。
 func Start(
 	services DevcontainerStartUseService,
 	args []string,
@@ -238,27 +270,41 @@ func Start(
 	nvim bool,
 	shell string,
 	configFilePath string,
-	vimrc string) error {
+	vimrc string,
+	vimrcSnippets []string) (StartResult, error) {
 
 	// コマンドライン引数の末尾は `--workspace-folder` の値として使う
 	workspaceFolder := args[len(args)-1]
 
 	// 1. devcontainer up でコンテナを起動
-	containerID, err := startDevcontainer(devcontainerPath, args, configFilePath, workspaceFolder)
+	upCommandResult, err := startDevcontainer(devcontainerPath, args, configFilePath, workspaceFolder)
 	if err != nil {
-		return err
+		return StartResult{}, err
+	}
+	containerID := upCommandResult.ContainerID
+	result := StartResult{
+		ContainerID:     containerID,
+		RemoteUser:      upCommandResult.RemoteUser,
+		WorkspaceFolder: upCommandResult.RemoteWorkspaceFolder,
+		ForwardedPorts:  []int{},
+	}
+
+	// dry-run の場合、コンテナが存在しないため以降の処理は行えない
+	if runner.IsDryRun() {
+		return result, nil
 	}
 
 	// 2. コンテナアーキテクチャを取得
 	containerArch, err := getContainerArch(containerID)
 	if err != nil {
-		return err
+		return result, err
 	}
 
-	// 3. port-forwarderをインストール
-	err = installPortForwarder(containerID, vimInstallDir, containerArch)
+	// 3. port-forwarderを転送対象に追加
+	payload := &containerPayload{}
+	err = installPortForwarder(vimInstallDir, containerArch, payload)
 	if err != nil {
-		return err
+		return result, err
 	}
 
 	// 4. clipboard-data-receiverを起動
@@ -267,77 +313,87 @@ func Start(
 	if !noCdr {
 		_, port, err = startClipboardReceiverForDevcontainer(cdrPath, configDirForDevcontainer)
 		if err != nil {
-			return err
+			return result, err
 		}
+		result.CdrPort = port
 	}
 
-	// 5. port-forwardingの設定
-	var pfCancel context.CancelFunc
-	if !noPf {
-		var pfCtx context.Context
-		pfCtx, pfCancel = context.WithCancel(context.Background())
-		err = setupPortForwarding(pfCtx, containerID, devcontainerPath, workspaceFolder)
-		if err != nil {
-			return err
-		}
-	}
-
-	// 6. Vimの検出とインストール
-	vimFileName, useSystemVim, err := setupVim(containerID, vimInstallDir, nvim, containerArch)
+	// 5. Vimの検出
+	vimFileName, useSystemVim, err := setupVim(containerID, vimInstallDir, nvim, containerArch, payload)
 	if err != nil {
-		return err
+		return result, err
 	}
 
 	tmuxFileName := ""
 	useSystemTmux := false
 	if !noTmux {
-		tmuxFileName, useSystemTmux, err = setupTmux(containerID, vimInstallDir, containerArch)
+		tmuxFileName, useSystemTmux, err = setupTmux(containerID, vimInstallDir, containerArch, payload)
 		if err != nil {
-			return err
+			return result, err
 		}
 	}
 
-	// 7. Vimファイルの転送
-	sendToTCP, err := transferVimFiles(containerID, configDirForDevcontainer, vimrc, noCdr, port, vimFileName == "nvim")
+	// 6. Vimファイルと Vim 起動スクリプトの作成
+	sendToTCP, err := stageVimFiles(configDirForDevcontainer, vimrc, vimrcSnippets, noCdr, port, vimFileName == "nvim", payload)
 	if err != nil {
-		return err
+		return result, err
+	}
+	vimLaunchScript, err := createVimRunScript(configDirForDevcontainer, vimFileName, tmuxFileName, filepath.Base(sendToTCP), containerArch, useSystemVim, useSystemTmux, noTmux)
+	if err != nil {
+		return result, err
+	}
+	payload.add(vimLaunchScript, "VimRun.sh", 0755)
+
+	// 7. 転送対象のファイルをまとめてコンテナへ転送
+	err = payload.upload(containerID)
+	if err != nil {
+		return result, err
 	}
 
-	// 8. コンテナへ接続
-	err = services.StartVim(containerID, devcontainerPath, workspaceFolder, vimFileName, tmuxFileName, sendToTCP, containerArch, useSystemVim, useSystemTmux, noTmux, shell, configDirForDevcontainer)
+	// 8. port-forwardingの設定
+	var pfCancel context.CancelFunc
+	if !noPf {
+		var pfCtx context.Context
+		pfCtx, pfCancel = context.WithCancel(context.Background())
+		defer pfCancel()
+		result.ForwardedPorts, err = setupPortForwarding(pfCtx, containerID, devcontainerPath, workspaceFolder, configurationFileFromArgs(args))
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// 9. コンテナへ接続
+	err = services.StartVim(containerID, devcontainerPath, workspaceFolder, shell)
 	if pfCancel != nil {
 		pfCancel()
 	}
 	if err != nil {
-		return err
+		return result, err
 	}
 
 	// コンテナ停止は別途 down コマンドで行う
-	return nil
+	return result, nil
 }
 
 // コンテナへ接続
 // `docker exec <dockerrun 時に標準出力に表示される CONTAINER ID> /Vim-AppImage`
-func startVim(containerID string, devcontainerPath string, workspaceFolder string, vimFileName string, tmuxFileName string, sendToTCP string, containerArch string, useSystemVim bool, useSystemTmux bool, noTmux bool, shell string, configFilePathForDevcontainer string) error {
+func startVim(containerID string, devcontainerPath string, workspaceFolder string, shell string) error {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	sendToTCPName := filepath.Base(sendToTCP)
-	devcontainerStartVimArgs, err := devcontainerStartVimArgs(containerID, workspaceFolder, vimFileName, tmuxFileName, sendToTCPName, containerArch, useSystemVim, useSystemTmux, noTmux, shell, configFilePathForDevcontainer)
-	if err != nil {
-		return err
-	}
-	fmt.Printf("Start vim: `%s \"%s\"`\n", devcontainerPath, strings.Join(devcontainerStartVimArgs, "\" \""))
+	devcontainerStartVimArgs := buildDevcontainerStartVimExecArgs(containerID, workspaceFolder, shell)
+	fmt.Fprintf(output.Progress(), "Start vim: `%s \"%s\"`\n", devcontainerPath, strings.Join(devcontainerStartVimArgs, "\" \""))
 	dockerExec := createStartVimCommand(ctx, devcontainerPath, devcontainerStartVimArgs)
 	dockerExec.Stdin = os.Stdin
-	dockerExec.Stdout = os.Stdout
+	// JSON 形式の場合、 Vim の画面で実行結果の JSON を汚さないよう標準エラー出力へ描画する
+	dockerExec.Stdout = output.Progress()
 	dockerExec.Stderr = os.Stderr
 	dockerExec.Cancel = func() error {
 		fmt.Fprintf(os.Stderr, "Receive SIGINT.\n")
 		return dockerExec.Process.Signal(os.Interrupt)
 	}
 
-	err = dockerExec.Run()
+	err := dockerExec.Run()
 	if err != nil {
 		return err
 	} else {
@@ -345,17 +401,17 @@ func startVim(containerID string, devcontainerPath string, workspaceFolder strin
 	}
 }
 
-func createStartVimCommand(ctx context.Context, devcontainerPath string, devcontainerStartVimArgs []string) *exec.Cmd {
+func createStartVimCommand(ctx context.Context, devcontainerPath string, devcontainerStartVimArgs []string) *runner.Cmd {
 	if util.IsWsl() && util.IsExistsCommand("script") {
 		scriptArgs := []string{"-qefc", shellQuote(devcontainerPath)}
 		for _, arg := range devcontainerStartVimArgs {
 			scriptArgs[1] += " " + shellQuote(arg)
 		}
 		scriptArgs = append(scriptArgs, "/dev/null")
-		return exec.CommandContext(ctx, "script", scriptArgs...)
+		return runner.CommandContext(ctx, "script", scriptArgs...)
 	}
 
-	return exec.CommandContext(ctx, devcontainerPath, devcontainerStartVimArgs...)
+	return runner.CommandContext(ctx, devcontainerPath, devcontainerStartVimArgs...)
 }
 
 func shellQuote(arg string) string {
@@ -42,7 +42,7 @@ func TestStartStepByStep(t *testing.T) {
 		devcontainerPath := requireTestBinary(t, "devcontainer")
 
 		// 設定ファイルが作成できるか確認
-		configFilePath, err := CreateConfigFile(devcontainerPath, "../test/project/TestStart", configDirForDevcontainer)
+		configFilePath, err := CreateConfigFile(devcontainerPath, "../test/project/TestStart", "", configDirForDevcontainer, "")
 		if err != nil {
 			// devcontainerコマンドが失敗する場合はスキップ
 			if strings.Contains(err.Error(), "出力パースに失敗") {
@@ -1,6 +1,10 @@
 package devcontainer
 
-import "testing"
+import (
+	"net"
+	"strconv"
+	"testing"
+)
 
 func TestParsePortForwarderMarker(t *testing.T) {
 	srcPort, destPort, err := parsePortForwarderMarker("localhost:8080_172.17.0.2:45123")
@@ -21,3 +25,27 @@ func TestParsePortForwarderMarkerRejectsInvalidMarker(t *testing.T) {
 		t.Fatal("expected error for invalid marker")
 	}
 }
+
+func TestAppendPortSkipsNonNumericPort(t *testing.T) {
+	ports := appendPort([]int{}, "8080")
+	ports = appendPort(ports, "http")
+	ports = appendPort(ports, "5432")
+	if len(ports) != 2 || ports[0] != 8080 || ports[1] != 5432 {
+		t.Fatalf("expected [8080 5432], got %v", ports)
+	}
+}
+
+func TestRestorePortForwardersSkipsBoundPort(t *testing.T) {
+	// ホスト側のポートを使用中にする
+	listener, err := net.Listen("tcp", "0.0.0.0:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+
+	ports := restorePortForwarders("127.0.0.1", []string{"localhost:" + port + "_127.0.0.1:45123"})
+	if len(ports) != 0 {
+		t.Fatalf("expected bound port to be skipped, got %v", ports)
+	}
+}
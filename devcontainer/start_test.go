@@ -10,7 +10,7 @@ import (
 
 type TestDevcontainerStartUseService struct{}
 
-func (s TestDevcontainerStartUseService) StartVim(containerID string, devcontainerPath string, workspaceFolder string, vimFileName string, tmuxFileName string, sendToTCP string, containerArch string, useSystemVim bool, useSystemTmux bool, noTmux bool, shell string, configFilePathForDevcontainer string) error {
+func (s TestDevcontainerStartUseService) StartVim(containerID string, devcontainerPath string, workspaceFolder string, shell string) error {
 	return nil
 }
 
@@ -41,7 +41,7 @@ func TestStart(t *testing.T) {
 	}
 
 	// コマンドライン引数の末尾は `--workspace-folder` の値として使う
-	configFilePath, err := CreateConfigFile(devcontainerPath, "../test/project/TestStart", configDirForDevcontainer)
+	configFilePath, err := CreateConfigFile(devcontainerPath, "../test/project/TestStart", "", configDirForDevcontainer, "")
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			t.Skipf("Configuration file not found: %v", err)
@@ -57,7 +57,7 @@ func TestStart(t *testing.T) {
 	// devcontainer を用いたコンテナ立ち上げ
 	noCdr := false
 	noPf := false
-	err = Start(TestDevcontainerStartUseService{}, args, devcontainerPath, noCdr, noPf, false, cdrPath, binDir, nvim, "", configFilePath, "../test/resource/TestStart/vimrc")
+	_, err = Start(TestDevcontainerStartUseService{}, args, devcontainerPath, noCdr, noPf, false, cdrPath, binDir, nvim, "", configFilePath, "../test/resource/TestStart/vimrc", nil)
 	if err != nil {
 		if errors.Is(err, os.ErrPermission) {
 			t.Skipf("Permission error: %v", err)
@@ -142,7 +142,7 @@ func TestStartWithDockerCompose(t *testing.T) {
 	cdrPath := requireTestBinary(t, "clipboard-data-receiver")
 
 	// コマンドライン引数の末尾は `--workspace-folder` の値として使う
-	configFilePath, err := CreateConfigFile(devcontainerPath, ".", configDirForDevcontainer)
+	configFilePath, err := CreateConfigFile(devcontainerPath, ".", "", configDirForDevcontainer, "")
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			t.Skipf("Configuration file not found: %v", err)
@@ -158,7 +158,7 @@ func TestStartWithDockerCompose(t *testing.T) {
 	// devcontainer を用いたコンテナ立ち上げ
 	noCdr := false
 	noPf := false
-	err = Start(TestDevcontainerStartUseService{}, args, devcontainerPath, noCdr, noPf, false, cdrPath, binDir, nvim, "", configFilePath, "../../resource/TestStartWithDockerCompose/vimrc")
+	_, err = Start(TestDevcontainerStartUseService{}, args, devcontainerPath, noCdr, noPf, false, cdrPath, binDir, nvim, "", configFilePath, "../../resource/TestStartWithDockerCompose/vimrc", nil)
 	if err != nil {
 		if errors.Is(err, os.ErrPermission) {
 			t.Skipf("Permission error: %v", err)
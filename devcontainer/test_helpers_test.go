@@ -1,9 +1,11 @@
 package devcontainer
 
 import (
+	"io"
 	"path/filepath"
 	"testing"
 
+	"github.com/mikoto2000/devcontainer.vim/v3/docker"
 	"github.com/mikoto2000/devcontainer.vim/v3/util"
 )
 
@@ -37,3 +39,46 @@ func requireTestBinary(t *testing.T, name string) string {
 	}
 	return path
 }
+
+// ps と exec の結果を固定で返却するエンジン
+type fakeEngine struct {
+	psResult   string
+	execResult string
+	execErr    error
+	homeResult string
+	homeErr    error
+}
+
+func (e fakeEngine) Name() string    { return "fake" }
+func (e fakeEngine) Command() string { return "fake" }
+func (e fakeEngine) Version() (string, error) {
+	return "0.0.0", nil
+}
+func (e fakeEngine) Exec(containerID string, command ...string) (string, error) {
+	return e.execResult, e.execErr
+}
+func (e fakeEngine) ExecAsUser(containerID string, user string, command ...string) (string, error) {
+	return e.execResult, e.execErr
+}
+func (e fakeEngine) Ps(filter string) (string, error)    { return e.psResult, nil }
+func (e fakeEngine) PsAll(filter string) (string, error) { return e.psResult, nil }
+func (e fakeEngine) Cp(from string, containerID string, to string) (string, error) {
+	return "", nil
+}
+func (e fakeEngine) Upload(containerID string, destDir string, archive io.Reader) error {
+	return nil
+}
+func (e fakeEngine) Stop(containerID string) error { return nil }
+func (e fakeEngine) Rm(containerID string) error   { return nil }
+func (e fakeEngine) ImageHomeDirectory(image string, user string) (string, error) {
+	return e.homeResult, e.homeErr
+}
+
+func useFakeEngine(t *testing.T, engine docker.Engine) {
+	t.Helper()
+	previous := docker.CurrentEngine()
+	docker.SetEngine(engine)
+	t.Cleanup(func() {
+		docker.SetEngine(previous)
+	})
+}
@@ -0,0 +1,36 @@
+package devcontainer
+
+import (
+	_ "embed"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/util"
+)
+
+// devcontainer.json と devcontainer.vim の追加設定ファイルの検証に使用するスキーマ
+//
+//go:embed devcontainer.schema.json
+var devcontainerSchemaJSON []byte
+
+// devcontainer.vim 起動時にマージする設定ファイルを、それぞれスキーマで検証する。
+// 追加設定ファイルでは、配列に対するマージ指示子を受け付ける。
+func ValidateConfig(workspaceFolder string, configurationFile string, userAdditionalConfigFilePath string) ([]util.ConfigLayer, []util.ValidationProblem, error) {
+	layers, err := ConfigLayers(workspaceFolder, configurationFile, userAdditionalConfigFilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	schema, err := util.ParseJSONSchema(devcontainerSchemaJSON)
+	if err != nil {
+		return layers, nil, err
+	}
+
+	problems := []util.ValidationProblem{}
+	for _, layer := range layers {
+		layerProblems, err := util.ValidateJwcc(layer.Path, schema, layer.Name != ConfigLayerBase)
+		if err != nil {
+			return layers, nil, err
+		}
+		problems = append(problems, layerProblems...)
+	}
+	return layers, problems, nil
+}
@@ -2,7 +2,11 @@ package devcontainer
 
 import (
 	"html/template"
+	"path/filepath"
+	"runtime"
 	"strings"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
 )
 
 type vimRunScriptParams struct {
@@ -26,3 +30,50 @@ func renderVimRunScript(templateSource string, params vimRunScriptParams) (strin
 	}
 	return script.String(), nil
 }
+
+// Vim 起動スクリプト(VimRun.sh)を configDir へ出力し、そのパスを返却する
+//
+// Args:
+//   - vimFileName: コンテナ上に転送した vim/nvim のファイル名
+//   - sendToTCP: コンテナ上に転送した SendToTcp のファイル名
+//   - useSystemVim: true の場合、システムにインストールされた vim/nvim を使用する
+func createVimRunScript(configDir string, vimFileName string, tmuxFileName string, sendToTCP string, containerArch string, useSystemVim bool, useSystemTmux bool, noTmux bool) (string, error) {
+	var templateSource string
+	if useSystemVim {
+		templateSource = vimRunX8664System
+	} else {
+		if containerArch == "amd64" {
+			if runtime.GOOS != "darwin" {
+				templateSource = vimRunX8664AppImage
+			} else {
+				templateSource = vimRunX8664Static
+			}
+		} else {
+			templateSource = vimRunAarch64
+		}
+	}
+
+	tmuxCommand := "/" + tmuxFileName
+	if useSystemTmux {
+		tmuxCommand = tmuxFileName
+	}
+	vimRunScript, err := renderVimRunScript(templateSource, vimRunScriptParams{
+		VimFileName: vimFileName,
+		SendToTcp:   sendToTCP,
+		UseTmux:     !noTmux,
+		TmuxCommand: tmuxCommand,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Vim 起動スクリプトを出力
+	vimLaunchScript := filepath.Join(configDir, "VimRun.sh")
+	runner.RemoveAll(vimLaunchScript)
+	err = runner.WriteFile(vimLaunchScript, []byte(vimRunScript), 0766)
+	if err != nil {
+		return "", err
+	}
+
+	return vimLaunchScript, nil
+}
@@ -0,0 +1,476 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const envDockerHost = "DOCKER_HOST"
+
+// TLS で Docker Engine へ接続する場合の環境変数。
+// 設定されている場合は API を直接呼び出さず、 docker CLI に接続を任せる
+var envDockerTLS = []string{"DOCKER_TLS_VERIFY", "DOCKER_CERT_PATH", "DOCKER_TLS"}
+
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+// docker context の選択に関わる環境変数
+const envDockerContext = "DOCKER_CONTEXT"
+const envDockerConfig = "DOCKER_CONFIG"
+
+const defaultDockerContext = "default"
+
+// 使用する Docker Engine API のバージョン。
+// Docker Engine 20.10 以降で利用できる v1.41 に固定する
+const apiVersion = "v1.41"
+
+// メタデータ取得などの短い API 呼び出し時のタイムアウト。
+// 大きな tar を送るアップロードや exec の出力待ちには適用しない
+const apiTimeout = 60 * time.Second
+
+// Docker Engine API がエラーを返却した場合のエラー
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("docker engine api error (status %d): %s", e.StatusCode, e.Message)
+}
+
+// `exec` で実行したコマンドが 0 以外で終了した場合のエラー
+type ExecError struct {
+	ExitCode int
+	Stderr   string
+}
+
+func (e *ExecError) Error() string {
+	return fmt.Sprintf("exec failed with exit code %d: %s", e.ExitCode, strings.TrimSpace(e.Stderr))
+}
+
+type UnsupportedHostError struct {
+	msg string
+}
+
+func (e *UnsupportedHostError) Error() string {
+	return e.msg
+}
+
+// Docker Engine API を直接呼び出すエンジン。
+// docker CLI を必要とする操作(run, compose, 対話的な exec)のために command も保持する。
+type apiEngine struct {
+	name    string
+	command string
+	client  *http.Client
+	baseURL string
+}
+
+// docker CLI と同じ優先順位(DOCKER_HOST, DOCKER_CONTEXT, config.json の currentContext)で、
+// 使用中の docker context 名を返却する。
+// DOCKER_HOST が設定されている場合や、 context が選択されていない場合は `default` を返却する。
+func currentDockerContext() string {
+	if os.Getenv(envDockerHost) != "" {
+		return defaultDockerContext
+	}
+	if contextName := os.Getenv(envDockerContext); contextName != "" {
+		return contextName
+	}
+
+	configDir := os.Getenv(envDockerConfig)
+	if configDir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return defaultDockerContext
+		}
+		configDir = filepath.Join(homeDir, ".docker")
+	}
+	content, err := os.ReadFile(filepath.Join(configDir, "config.json"))
+	if err != nil {
+		return defaultDockerContext
+	}
+	var config struct {
+		CurrentContext string `json:"currentContext"`
+	}
+	if json.Unmarshal(content, &config) != nil || config.CurrentContext == "" {
+		return defaultDockerContext
+	}
+	return config.CurrentContext
+}
+
+// DOCKER_HOST(未設定の場合は `unix:///var/run/docker.sock`)へ接続する apiEngine を作成する。
+func NewAPIEngine(name string, command string, dockerHost string) (Engine, error) {
+	if dockerHost == "" {
+		dockerHost = defaultDockerHost
+	}
+
+	hostURL, err := url.Parse(dockerHost)
+	if err != nil {
+		return nil, err
+	}
+
+	var dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	var baseURL string
+	switch hostURL.Scheme {
+	case "unix":
+		socketPath := hostURL.Path
+		if _, err := os.Stat(socketPath); err != nil {
+			return nil, err
+		}
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		}
+		baseURL = "http://docker"
+	case "tcp":
+		for _, envName := range envDockerTLS {
+			if os.Getenv(envName) != "" {
+				return nil, &UnsupportedHostError{msg: fmt.Sprintf("TLS connection to DOCKER_HOST (%s is set) is not supported by the Engine API client.", envName)}
+			}
+		}
+		dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, addr)
+		}
+		baseURL = "http://" + hostURL.Host
+	default:
+		return nil, &UnsupportedHostError{msg: "unsupported DOCKER_HOST scheme: " + hostURL.Scheme}
+	}
+
+	return apiEngine{
+		name:    name,
+		command: command,
+		client: &http.Client{
+			Transport: &http.Transport{DialContext: dial},
+		},
+		baseURL: baseURL,
+	}, nil
+}
+
+func (e apiEngine) Name() string {
+	return e.name
+}
+
+func (e apiEngine) Command() string {
+	return e.command
+}
+
+// Engine API へリクエストを送信し、レスポンスを返却する。
+// apiPath には apiVersion のプレフィックスを付与する。
+// 2xx 以外のステータスの場合は APIError を返却する。
+func (e apiEngine) request(ctx context.Context, method string, apiPath string, query url.Values, contentType string, body io.Reader) (*http.Response, error) {
+	requestURL := e.baseURL + "/" + apiVersion + apiPath
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var apiMessage struct {
+			Message string `json:"message"`
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(respBody, &apiMessage) != nil || apiMessage.Message == "" {
+			apiMessage.Message = strings.TrimSpace(string(respBody))
+		}
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: apiMessage.Message}
+	}
+
+	return resp, nil
+}
+
+// JSON をボディに持つリクエストを送信し、レスポンスを result へデコードする。
+// レスポンスが小さい呼び出し向けのため、 apiTimeout でタイムアウトする。
+func (e apiEngine) requestJSON(method string, apiPath string, query url.Values, requestBody any, result any) error {
+	var body io.Reader
+	contentType := ""
+	if requestBody != nil {
+		requestBodyBytes, err := json.Marshal(requestBody)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(requestBodyBytes)
+		contentType = "application/json"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	resp, err := e.request(ctx, method, apiPath, query, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if result == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func (e apiEngine) Version() (string, error) {
+	var version struct {
+		Version string `json:"Version"`
+	}
+	err := e.requestJSON(http.MethodGet, "/version", nil, nil, &version)
+	if err != nil {
+		return "", err
+	}
+	return version.Version, nil
+}
+
+func (e apiEngine) Exec(containerID string, command ...string) (string, error) {
+	return e.ExecAsUser(containerID, "", command...)
+}
+
+func (e apiEngine) ExecAsUser(containerID string, user string, command ...string) (string, error) {
+	// exec インスタンスの作成
+	var created struct {
+		ID string `json:"Id"`
+	}
+	err := e.requestJSON(http.MethodPost, "/containers/"+url.PathEscape(containerID)+"/exec", nil, map[string]any{
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Tty":          false,
+		"User":         user,
+		"Cmd":          command,
+	}, &created)
+	if err != nil {
+		return "", err
+	}
+
+	// exec の開始
+	startBody, err := json.Marshal(map[string]any{"Detach": false, "Tty": false})
+	if err != nil {
+		return "", err
+	}
+	// コマンドの終了まで出力を待つため、タイムアウトは設定しない
+	resp, err := e.request(context.Background(), http.MethodPost, "/exec/"+created.ID+"/start", nil, "application/json", bytes.NewReader(startBody))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var stdout, stderr bytes.Buffer
+	err = demultiplexStream(resp.Body, &stdout, &stderr)
+	if err != nil {
+		return stdout.String(), err
+	}
+
+	// 終了コードの確認
+	var inspected struct {
+		ExitCode int `json:"ExitCode"`
+	}
+	err = e.requestJSON(http.MethodGet, "/exec/"+created.ID+"/json", nil, nil, &inspected)
+	if err != nil {
+		return stdout.String(), err
+	}
+	if inspected.ExitCode != 0 {
+		return stdout.String(), &ExecError{ExitCode: inspected.ExitCode, Stderr: stderr.String()}
+	}
+
+	return stdout.String(), nil
+}
+
+// TTY なしの exec が返却する多重化ストリームを stdout, stderr に分離する。
+//
+// 各フレームは 8 バイトのヘッダ(ストリーム種別 1 バイト + 予約 3 バイト + ペイロード長 4 バイト)と
+// ペイロードで構成される。
+func demultiplexStream(stream io.Reader, stdout io.Writer, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		_, err := io.ReadFull(stream, header)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		var dest io.Writer
+		switch header[0] {
+		case 2:
+			dest = stderr
+		default:
+			dest = stdout
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:8]))
+		_, err = io.CopyN(dest, stream, size)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// `GET /containers/json` の結果のうち、devcontainer.vim が使用するもの
+type apiContainerSummary struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Image  string            `json:"Image"`
+	State  string            `json:"State"`
+	Status string            `json:"Status"`
+	Labels map[string]string `json:"Labels"`
+}
+
+// 1 行 1 コンテナの PsCommandResult の JSON で結果を返却する。
+func (e apiEngine) Ps(filter string) (string, error) {
+	return e.ps(false, filter)
+}
+
+func (e apiEngine) PsAll(filter string) (string, error) {
+	return e.ps(true, filter)
+}
+
+func (e apiEngine) ps(all bool, filter string) (string, error) {
+	query := url.Values{}
+	if all {
+		query.Set("all", "1")
+	}
+	if filter != "" {
+		key, value, _ := strings.Cut(filter, "=")
+		filters, err := json.Marshal(map[string][]string{key: {value}})
+		if err != nil {
+			return "", err
+		}
+		query.Set("filters", string(filters))
+	}
+
+	var containers []apiContainerSummary
+	err := e.requestJSON(http.MethodGet, "/containers/json", query, nil, &containers)
+	if err != nil {
+		return "", err
+	}
+
+	var result strings.Builder
+	for _, container := range containers {
+		names := []string{}
+		for _, name := range container.Names {
+			names = append(names, strings.TrimPrefix(name, "/"))
+		}
+
+		line, err := json.Marshal(PsCommandResult{
+			ID:     container.ID,
+			Names:  strings.Join(names, ","),
+			Image:  container.Image,
+			State:  container.State,
+			Status: container.Status,
+			Labels: container.Labels,
+		})
+		if err != nil {
+			return "", err
+		}
+		result.Write(line)
+		result.WriteString("\n")
+	}
+
+	return result.String(), nil
+}
+
+// from のファイルを tar アーカイブにしてコンテナへアップロードする。
+// to が `/` で終わる場合はディレクトリとみなし、 from と同じファイル名で配置する。
+// ファイル全体をメモリに載せないよう、 tar アーカイブはアップロードしながら生成する。
+func (e apiEngine) Cp(from string, containerID string, to string) (string, error) {
+	destDir := path.Dir(to)
+	destName := path.Base(to)
+	if strings.HasSuffix(to, "/") {
+		destDir = to
+		destName = filepath.Base(from)
+	}
+
+	file, err := os.Open(from)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeFileArchive(pw, file, fileInfo, destName))
+	}()
+	defer pr.Close()
+
+	return "", e.Upload(containerID, destDir, pr)
+}
+
+// file を name という名前の 1 エントリだけを持つ tar アーカイブとして w へ書き出す。
+func writeFileArchive(w io.Writer, file io.Reader, fileInfo os.FileInfo, name string) error {
+	tw := tar.NewWriter(w)
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    int64(fileInfo.Mode().Perm()),
+		Size:    fileInfo.Size(),
+		ModTime: fileInfo.ModTime(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, file)
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func (e apiEngine) Upload(containerID string, destDir string, archive io.Reader) error {
+	query := url.Values{}
+	query.Set("path", destDir)
+	resp, err := e.request(context.Background(), http.MethodPut, "/containers/"+url.PathEscape(containerID)+"/archive", query, "application/x-tar", archive)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(io.Discard, resp.Body)
+	return err
+}
+
+func (e apiEngine) Stop(containerID string) error {
+	err := e.requestJSON(http.MethodPost, "/containers/"+url.PathEscape(containerID)+"/stop", nil, nil, nil)
+	// 停止済みのコンテナは 304 Not Modified が返却される。 docker CLI と同様に成功とみなす
+	var apiError *APIError
+	if errors.As(err, &apiError) && apiError.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	return err
+}
+
+// コンテナの作成から削除までを API で行う利点が無いため、 CLI で実行する
+func (e apiEngine) ImageHomeDirectory(image string, user string) (string, error) {
+	return imageHomeDirectory(e.command, image, user)
+}
+
+func (e apiEngine) Rm(containerID string) error {
+	query := url.Values{}
+	query.Set("force", "true")
+	return e.requestJSON(http.MethodDelete, "/containers/"+url.PathEscape(containerID), query, nil, nil)
+}
@@ -0,0 +1,287 @@
+package docker
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// unix ソケット上で Docker Engine API の代わりをする HTTP サーバーを起動し、
+// そこへ接続する apiEngine を返却する。
+func startFakeDockerAPI(t *testing.T, handler http.Handler) Engine {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("failed to listen unix socket: %v", err)
+	}
+	// apiVersion のプレフィックスが無いリクエストは 404 になる
+	server := &http.Server{Handler: http.StripPrefix("/"+apiVersion, handler)}
+	go server.Serve(listener)
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	engine, err := NewAPIEngine(EngineNameDocker, "docker", "unix://"+socketPath)
+	if err != nil {
+		t.Fatalf("failed to create api engine: %v", err)
+	}
+	return engine
+}
+
+func writeMultiplexedFrame(w io.Writer, streamType byte, payload string) {
+	header := make([]byte, 8)
+	header[0] = streamType
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	w.Write(header)
+	w.Write([]byte(payload))
+}
+
+func TestCurrentDockerContext(t *testing.T) {
+	configDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{"currentContext":"remote"}`), 0644)
+	if err != nil {
+		t.Fatalf("failed to create config.json: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		dockerHost    string
+		dockerContext string
+		dockerConfig  string
+		want          string
+	}{
+		{name: "no context", dockerConfig: t.TempDir(), want: "default"},
+		{name: "current context in config.json", dockerConfig: configDir, want: "remote"},
+		{name: "DOCKER_CONTEXT", dockerContext: "colima", dockerConfig: configDir, want: "colima"},
+		{name: "DOCKER_HOST overrides context", dockerHost: "unix:///tmp/docker.sock", dockerContext: "colima", dockerConfig: configDir, want: "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(envDockerHost, tt.dockerHost)
+			t.Setenv(envDockerContext, tt.dockerContext)
+			t.Setenv(envDockerConfig, tt.dockerConfig)
+
+			got := currentDockerContext()
+			if got != tt.want {
+				t.Fatalf("want %s, but got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewAPIEngineUnsupportedScheme(t *testing.T) {
+	_, err := NewAPIEngine(EngineNameDocker, "docker", "npipe:////./pipe/docker_engine")
+	var unsupportedHostError *UnsupportedHostError
+	if !errors.As(err, &unsupportedHostError) {
+		t.Fatalf("want UnsupportedHostError, but got %v", err)
+	}
+}
+
+func TestNewAPIEngineTLSIsUnsupported(t *testing.T) {
+	t.Setenv("DOCKER_TLS_VERIFY", "1")
+	_, err := NewAPIEngine(EngineNameDocker, "docker", "tcp://127.0.0.1:2376")
+	var unsupportedHostError *UnsupportedHostError
+	if !errors.As(err, &unsupportedHostError) {
+		t.Fatalf("want UnsupportedHostError, but got %v", err)
+	}
+}
+
+func TestAPIEngineStopAlreadyStopped(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /containers/abc123/stop", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	})
+	engine := startFakeDockerAPI(t, mux)
+
+	err := engine.Stop("abc123")
+	if err != nil {
+		t.Fatalf("want success for already stopped container, but got %v", err)
+	}
+}
+
+func TestAPIEnginePsWithLabelFilter(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /containers/json", func(w http.ResponseWriter, r *http.Request) {
+		var filters map[string][]string
+		json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters)
+		if len(filters["label"]) != 1 || filters["label"][0] != "devcontainer.local_folder=/work" {
+			t.Errorf("unexpected filters: %v", filters)
+		}
+		w.Write([]byte(`[{"Id":"abc123","Names":["/test"],"Image":"alpine","State":"running","Status":"Up 1 second","Labels":{"devcontainer.local_folder":"/work"}}]`))
+	})
+	engine := startFakeDockerAPI(t, mux)
+
+	result, err := engine.Ps("label=devcontainer.local_folder=/work")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	id, err := GetID(strings.TrimSpace(result))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id != "abc123" {
+		t.Fatalf("want abc123, but got %s", id)
+	}
+	if !strings.Contains(result, `"Names":"test"`) {
+		t.Fatalf("unexpected ps result: %s", result)
+	}
+}
+
+func TestAPIEngineExec(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /containers/abc123/exec", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			User string   `json:"User"`
+			Cmd  []string `json:"Cmd"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.User != "root" || strings.Join(body.Cmd, " ") != "uname -m" {
+			t.Errorf("unexpected exec body: %#v", body)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"Id":"exec1"}`))
+	})
+	mux.HandleFunc("POST /exec/exec1/start", func(w http.ResponseWriter, r *http.Request) {
+		writeMultiplexedFrame(w, 1, "x86_64\n")
+		writeMultiplexedFrame(w, 2, "warning\n")
+	})
+	mux.HandleFunc("GET /exec/exec1/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ExitCode":0}`))
+	})
+	engine := startFakeDockerAPI(t, mux)
+
+	stdout, err := engine.ExecAsUser("abc123", "root", "uname", "-m")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stdout != "x86_64\n" {
+		t.Fatalf("want x86_64, but got %q", stdout)
+	}
+}
+
+func TestAPIEngineExecReturnsExecErrorOnNonZeroExit(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /containers/abc123/exec", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"Id":"exec1"}`))
+	})
+	mux.HandleFunc("POST /exec/exec1/start", func(w http.ResponseWriter, r *http.Request) {
+		writeMultiplexedFrame(w, 2, "not found\n")
+	})
+	mux.HandleFunc("GET /exec/exec1/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ExitCode":1}`))
+	})
+	engine := startFakeDockerAPI(t, mux)
+
+	stdout, err := engine.Exec("abc123", "which", "tmux")
+	var execError *ExecError
+	if !errors.As(err, &execError) {
+		t.Fatalf("want ExecError, but got %v", err)
+	}
+	if execError.ExitCode != 1 || execError.Stderr != "not found\n" {
+		t.Fatalf("unexpected exec error: %#v", execError)
+	}
+	if stdout != "" {
+		t.Fatalf("want empty stdout, but got %q", stdout)
+	}
+}
+
+func TestAPIEngineCp(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /containers/abc123/archive", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("path") != "/" {
+			t.Errorf("unexpected path: %s", r.URL.Query().Get("path"))
+		}
+		tr := tar.NewReader(r.Body)
+		hdr, err := tr.Next()
+		if err != nil {
+			t.Errorf("failed to read archive: %v", err)
+			return
+		}
+		content, _ := io.ReadAll(tr)
+		if hdr.Name != "port-forwarder" || hdr.Mode != 0755 || string(content) != "binary" {
+			t.Errorf("unexpected archive entry: %s %o %s", hdr.Name, hdr.Mode, content)
+		}
+	})
+	engine := startFakeDockerAPI(t, mux)
+
+	from := filepath.Join(t.TempDir(), "port-forwarder-container_amd64")
+	err := os.WriteFile(from, []byte("binary"), 0755)
+	if err != nil {
+		t.Fatalf("failed to create source file: %v", err)
+	}
+
+	_, err = engine.Cp(from, "abc123", "/port-forwarder")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAPIEngineVersion(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /version", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Version":"27.3.1","ApiVersion":"1.47"}`))
+	})
+	engine := startFakeDockerAPI(t, mux)
+
+	version, err := engine.Version()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version != "27.3.1" {
+		t.Fatalf("want 27.3.1, but got %s", version)
+	}
+}
+
+func TestAPIEngineStopAndRm(t *testing.T) {
+	var stopped, removed bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /containers/abc123/stop", func(w http.ResponseWriter, r *http.Request) {
+		stopped = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("DELETE /containers/abc123", func(w http.ResponseWriter, r *http.Request) {
+		removed = r.URL.Query().Get("force") == "true"
+		w.WriteHeader(http.StatusNoContent)
+	})
+	engine := startFakeDockerAPI(t, mux)
+
+	err := engine.Stop("abc123")
+	if err != nil || !stopped {
+		t.Fatalf("stop failed: %v", err)
+	}
+	err = engine.Rm("abc123")
+	if err != nil || !removed {
+		t.Fatalf("rm failed: %v", err)
+	}
+}
+
+func TestAPIEngineReturnsAPIError(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /containers/missing/stop", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"No such container: missing"}`))
+	})
+	engine := startFakeDockerAPI(t, mux)
+
+	err := engine.Stop("missing")
+	var apiError *APIError
+	if !errors.As(err, &apiError) {
+		t.Fatalf("want APIError, but got %v", err)
+	}
+	if apiError.StatusCode != http.StatusNotFound || apiError.Message != "No such container: missing" {
+		t.Fatalf("unexpected api error: %#v", apiError)
+	}
+}
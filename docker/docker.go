@@ -2,13 +2,13 @@ package docker
 
 import (
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
-)
 
-const containerCommand = "docker"
+	"github.com/mikoto2000/devcontainer.vim/v3/output"
+)
 
 type ContainerNotFoundError struct {
 	msg string
@@ -18,77 +18,116 @@ func (e *ContainerNotFoundError) Error() string {
 	return e.msg
 }
 
+const labelLocalFolder = "devcontainer.local_folder"
+const labelConfigFile = "devcontainer.config_file"
+
 // workspaceFolder で指定したディレクトリに対応するコンテナのコンテナ ID を返却する
 func GetContainerIDFromWorkspaceFolder(workspaceFolder string) (string, error) {
+	return GetContainerIDFromWorkspaceFolderAndConfiguration(workspaceFolder, "")
+}
+
+// workspaceFolder と devcontainer.json(configurationFile) の組み合わせに対応するコンテナのコンテナ ID を返却する。
+// configurationFile が空文字の場合は、 workspaceFolder のみで探す。
+func GetContainerIDFromWorkspaceFolderAndConfiguration(workspaceFolder string, configurationFile string) (string, error) {
+	container, err := FindContainer(workspaceFolder, configurationFile, false)
+	if err != nil {
+		return "", err
+	}
+	return container.ID, nil
+}
+
+// workspaceFolder と devcontainer.json(configurationFile) の組み合わせに対応するコンテナを返却する。
+// configurationFile が空文字の場合は、 workspaceFolder のみで探す。
+// all が true の場合、停止中のコンテナも対象にする。
+func FindContainer(workspaceFolder string, configurationFile string, all bool) (PsCommandResult, error) {
 
 	// `devcontainer.local_folder=${workspaceFolder}` が含まれている行を探す
 
 	workspaceFilderAbs, err := filepath.Abs(workspaceFolder)
 	if err != nil {
-		return "", err
+		return PsCommandResult{}, err
 	}
 
-	psResult, err := Ps("label=devcontainer.local_folder=" + workspaceFilderAbs)
-	if psResult == "" {
-		return "", &ContainerNotFoundError{msg: "container not found."}
+	filter := "label=" + labelLocalFolder + "=" + workspaceFilderAbs
+	var psResult string
+	if all {
+		psResult, err = PsAll(filter)
+	} else {
+		psResult, err = Ps(filter)
 	}
 	if err != nil {
-		return "", err
+		return PsCommandResult{}, err
 	}
-
-	id, err := GetID(psResult)
+	containers, err := UnmarshalPsCommandResults(psResult)
 	if err != nil {
-		return "", err
+		return PsCommandResult{}, err
 	}
 
-	return id, nil
+	for _, container := range containers {
+		// `devcontainer.config_file` ラベルで、同じワークスペースの別の設定のコンテナと区別する
+		if configurationFile == "" || container.Label(labelConfigFile) == configurationFile {
+			return container, nil
+		}
+	}
+	return PsCommandResult{}, &ContainerNotFoundError{msg: "container not found."}
+}
+
+// コンテナエンジンのバージョンを返却する。
+func Version() (string, error) {
+	return currentEngine.Version()
 }
 
 // `docker exec` コマンドを実行する。
 func Exec(containerID string, command ...string) (string, error) {
+	return currentEngine.Exec(containerID, command...)
+}
 
-	dockerExecArgs := []string{"exec", "-t", containerID}
-	dockerExecArgs = append(dockerExecArgs, command...)
-
-	dockerExecCommand := exec.Command(containerCommand, dockerExecArgs...)
-	stdout, err := dockerExecCommand.Output()
-	return string(stdout), err
+// `docker exec --user ${user}` コマンドを実行する。
+func ExecAsUser(containerID string, user string, command ...string) (string, error) {
+	return currentEngine.ExecAsUser(containerID, user, command...)
 }
 
 // `docker ps --format json` コマンドを実行する。
 func Ps(filter string) (string, error) {
-	args := []string{"ps", "--format", "json"}
-	if filter != "" {
-		args = append(args, "--filter", filter)
-	}
-	dockerPsCommand := exec.Command(containerCommand, args...)
-	stdout, err := dockerPsCommand.Output()
-	return string(stdout), err
+	return currentEngine.Ps(filter)
+}
+
+// `docker ps --all --format json` コマンドを実行する。
+func PsAll(filter string) (string, error) {
+	return currentEngine.PsAll(filter)
 }
 
 // `docker stop -f ${containerID}` コマンドを実行する。
 func Stop(containerID string) error {
-	dockerStopCommand := exec.Command(containerCommand, "stop", containerID)
-	err := dockerStopCommand.Start()
-	return err
+	return currentEngine.Stop(containerID)
 }
 
 // `docker rm -f ${containerID}` コマンドを実行する。
 func Rm(containerID string) error {
-	dockerRmCommand := exec.Command(containerCommand, "rm", "-f", containerID)
-	err := dockerRmCommand.Start()
-	return err
+	return currentEngine.Rm(containerID)
 }
 
 func Cp(tagForLog string, from string, containerID string, to string) error {
-	dockerCpArgs := []string{"cp", from, containerID + ":" + to}
-	fmt.Printf("Copy %s: `%s \"%s\"` ...", tagForLog, containerCommand, strings.Join(dockerCpArgs, "\" \""))
-	copyResult, err := exec.Command(containerCommand, dockerCpArgs...).CombinedOutput()
+	fmt.Fprintf(output.Progress(), "Copy %s: `%s \"%s\"` ...", tagForLog, currentEngine.Command(), strings.Join([]string{"cp", from, containerID + ":" + to}, "\" \""))
+	copyResult, err := currentEngine.Cp(from, containerID, to)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "copy error.")
-		fmt.Fprintln(os.Stderr, string(copyResult))
+		fmt.Fprintln(os.Stderr, copyResult)
+		return err
+	}
+	fmt.Fprintf(output.Progress(), " done.\n")
+	return nil
+}
+
+// tar アーカイブをコンテナの destDir へ展開する。
+func Upload(tagForLog string, containerID string, destDir string, archive io.Reader) error {
+	fmt.Fprintf(output.Progress(), "Upload %s to %s:%s ...", tagForLog, containerID, destDir)
+	err := currentEngine.Upload(containerID, destDir, archive)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "upload error.")
+		fmt.Fprintln(os.Stderr, err)
 		return err
 	}
-	fmt.Printf(" done.\n")
+	fmt.Fprintf(output.Progress(), " done.\n")
 	return nil
 }
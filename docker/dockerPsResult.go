@@ -2,16 +2,37 @@ package docker
 
 import (
 	"encoding/json"
+	"strings"
 )
 
-// `docker ps --format json` コマンドの実行結果スキーマ
+// Engine.Ps が返却する、 1 コンテナ分の結果のスキーマ
+//
+// `docker ps --format json` の Labels は `,` 区切りの文字列で、
+// 値に `,` を含むラベル(devcontainer.metadata など)を分割できないため、 Labels はオブジェクトとして扱う。
 //
 // Example:
 //
 //	{
+//	  "ID":"1ab1fd63fb94",
+//	  "Image":"mcr.microsoft.com/devcontainers/base:bookworm",
+//	  "Labels":{"devcontainer.config_file":"/work/.devcontainer/devcontainer.json","devcontainer.local_folder":"/work"},
+//	  "Names":"festive_hopper",
+//	  "State":"running",
+//	  "Status":"Up 2 minutes"
 //	}
 type PsCommandResult struct {
-	ID string `json:"ID"`
+	ID     string            `json:"ID"`
+	Names  string            `json:"Names"`
+	Image  string            `json:"Image"`
+	State  string            `json:"State"`
+	Status string            `json:"Status"`
+	Labels map[string]string `json:"Labels"`
+}
+
+// Labels から key に対応する値を返却する。
+// 存在しない場合は空文字を返却する。
+func (r PsCommandResult) Label(key string) string {
+	return r.Labels[key]
 }
 
 func GetID(psCommandResult string) (string, error) {
@@ -33,3 +54,21 @@ func UnmarshalPsCommandResult(data []byte) (PsCommandResult, error) {
 
 	return result, nil
 }
+
+// 1 行 1 コンテナの `docker ps --format json` の結果をパースする。
+func UnmarshalPsCommandResults(psResult string) ([]PsCommandResult, error) {
+	results := []PsCommandResult{}
+	for _, line := range strings.Split(psResult, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		result, err := UnmarshalPsCommandResult([]byte(line))
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
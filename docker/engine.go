@@ -0,0 +1,344 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
+)
+
+const EngineNameDocker = "docker"
+const EngineNamePodman = "podman"
+const EngineNameNerdctl = "nerdctl"
+
+type UnknownEngineError struct {
+	msg string
+}
+
+func (e *UnknownEngineError) Error() string {
+	return e.msg
+}
+
+// コンテナエンジン(docker, podman, nerdctl)の操作を抽象化したもの
+type Engine interface {
+	// エンジン名を返却する
+	Name() string
+
+	// エンジンの CLI コマンド名を返却する
+	Command() string
+
+	// `exec` を実行し、標準出力を返却する
+	Exec(containerID string, command ...string) (string, error)
+
+	// `exec --user ${user}` を実行し、標準出力を返却する
+	ExecAsUser(containerID string, user string, command ...string) (string, error)
+
+	// エンジンのバージョンを返却する。エンジンへ接続できない場合はエラーを返却する
+	Version() (string, error)
+
+	// `ps` を実行し、 1 行 1 コンテナの PsCommandResult の JSON を返却する。
+	// Labels はラベル名と値のオブジェクトとする
+	Ps(filter string) (string, error)
+
+	// `ps --all` を実行し、 1 行 1 コンテナの PsCommandResult の JSON を返却する
+	PsAll(filter string) (string, error)
+
+	// `cp` を実行し、標準出力・標準エラー出力を返却する
+	Cp(from string, containerID string, to string) (string, error)
+
+	// tar アーカイブをコンテナの destDir へ展開する
+	Upload(containerID string, destDir string, archive io.Reader) error
+
+	// `stop` を実行する
+	Stop(containerID string) error
+
+	// `rm -f` を実行する
+	Rm(containerID string) error
+
+	// image のコンテナを一時的に起動し、 user (空文字の場合はイメージの USER)のホームディレクトリを返却する
+	ImageHomeDirectory(image string, user string) (string, error)
+}
+
+// docker 互換 CLI を実行するエンジン
+type cliEngine struct {
+	name    string
+	command string
+
+	// `cp -` で標準入力から tar を受け取れない場合 true。
+	// その場合、コンテナ上の tar コマンドで展開する。
+	extractWithTar bool
+}
+
+func (e cliEngine) Name() string {
+	return e.name
+}
+
+func (e cliEngine) Command() string {
+	return e.command
+}
+
+// `version --format "{{json .}}"` の結果から、サーバー(無い場合はクライアント)のバージョンを返却する。
+func (e cliEngine) Version() (string, error) {
+	output, err := runner.ReadOnlyCommand(e.command, "version", "--format", "{{json .}}").Output()
+	if err != nil {
+		return "", err
+	}
+
+	var version struct {
+		Client struct {
+			Version string `json:"Version"`
+		} `json:"Client"`
+		Server struct {
+			Version string `json:"Version"`
+		} `json:"Server"`
+	}
+	err = json.Unmarshal(output, &version)
+	if err != nil {
+		return "", err
+	}
+
+	if version.Server.Version != "" {
+		return version.Server.Version, nil
+	}
+	return version.Client.Version, nil
+}
+
+func (e cliEngine) Exec(containerID string, command ...string) (string, error) {
+	execArgs := []string{"exec", "-t", containerID}
+	execArgs = append(execArgs, command...)
+
+	stdout, err := runner.Command(e.command, execArgs...).Output()
+	return string(stdout), err
+}
+
+func (e cliEngine) ExecAsUser(containerID string, user string, command ...string) (string, error) {
+	execArgs := []string{"exec", "--user", user, containerID}
+	execArgs = append(execArgs, command...)
+
+	output, err := runner.Command(e.command, execArgs...).CombinedOutput()
+	return string(output), err
+}
+
+func (e cliEngine) Ps(filter string) (string, error) {
+	return e.ps(false, filter)
+}
+
+func (e cliEngine) PsAll(filter string) (string, error) {
+	return e.ps(true, filter)
+}
+
+func (e cliEngine) ps(all bool, filter string) (string, error) {
+	args := []string{"ps", "--format", "json"}
+	if all {
+		args = append(args, "--all")
+	}
+	if filter != "" {
+		args = append(args, "--filter", filter)
+	}
+	stdout, err := runner.ReadOnlyCommand(e.command, args...).Output()
+	if err != nil {
+		return string(stdout), err
+	}
+	return e.withInspectedLabels(string(stdout))
+}
+
+// `ps --format json` の結果の Labels を、 `inspect` で取得したラベルのオブジェクトへ置き換える。
+//
+// `ps` の Labels は `,` 区切りの文字列で、値に `,` を含むラベル(devcontainer.metadata など)を分割できないため。
+func (e cliEngine) withInspectedLabels(psResult string) (string, error) {
+	containers := []map[string]any{}
+	ids := []string{}
+	for _, line := range strings.Split(psResult, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		container := map[string]any{}
+		err := json.Unmarshal([]byte(line), &container)
+		if err != nil {
+			return "", err
+		}
+		id, _ := container["ID"].(string)
+		containers = append(containers, container)
+		ids = append(ids, id)
+	}
+	if len(containers) == 0 {
+		return "", nil
+	}
+
+	// `ps` の後に削除されたコンテナがあると `inspect` は失敗するが、残りのコンテナの結果は標準出力へ出力される
+	inspectArgs := append([]string{"inspect", "--format", "{{.Id}} {{json .Config.Labels}}"}, ids...)
+	inspectResult, _ := runner.ReadOnlyCommand(e.command, inspectArgs...).Output()
+	labelsByID := parseInspectedLabels(string(inspectResult))
+
+	var result strings.Builder
+	for i, container := range containers {
+		labels := map[string]string{}
+		for fullID, inspectedLabels := range labelsByID {
+			// `ps` の ID は短縮形のため、前方一致で対応付ける
+			if ids[i] != "" && strings.HasPrefix(fullID, ids[i]) {
+				labels = inspectedLabels
+				break
+			}
+		}
+		container["Labels"] = labels
+
+		line, err := json.Marshal(container)
+		if err != nil {
+			return "", err
+		}
+		result.Write(line)
+		result.WriteString("\n")
+	}
+	return result.String(), nil
+}
+
+// `inspect --format "{{.Id}} {{json .Config.Labels}}"` の結果を、コンテナ ID ごとのラベルに変換する。
+func parseInspectedLabels(inspectResult string) map[string]map[string]string {
+	labelsByID := map[string]map[string]string{}
+	for _, line := range strings.Split(inspectResult, "\n") {
+		id, labelsJSON, found := strings.Cut(strings.TrimSpace(line), " ")
+		if !found {
+			continue
+		}
+		labels := map[string]string{}
+		err := json.Unmarshal([]byte(labelsJSON), &labels)
+		if err != nil {
+			continue
+		}
+		labelsByID[id] = labels
+	}
+	return labelsByID
+}
+
+func (e cliEngine) Cp(from string, containerID string, to string) (string, error) {
+	copyResult, err := runner.Command(e.command, "cp", from, containerID+":"+to).CombinedOutput()
+	return string(copyResult), err
+}
+
+func (e cliEngine) Upload(containerID string, destDir string, archive io.Reader) error {
+	var cmd *runner.Cmd
+	if e.extractWithTar {
+		cmd = runner.Command(e.command, "exec", "-i", "--user", "root", containerID, "tar", "-x", "-C", destDir)
+	} else {
+		cmd = runner.Command(e.command, "cp", "-", containerID+":"+destDir)
+	}
+	cmd.Stdin = archive
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func (e cliEngine) Stop(containerID string) error {
+	return runner.Command(e.command, "stop", containerID).Start()
+}
+
+func (e cliEngine) Rm(containerID string) error {
+	return runner.Command(e.command, "rm", "-f", containerID).Start()
+}
+
+func (e cliEngine) ImageHomeDirectory(image string, user string) (string, error) {
+	return imageHomeDirectory(e.command, image, user)
+}
+
+// ホームディレクトリを passwd から取得し、取得できなければ HOME 環境変数を出力するスクリプト
+const homeDirectoryScript = `home=$(getent passwd "$(id -u)" 2>/dev/null | cut -d: -f6); echo "${home:-$HOME}"`
+
+// command の `run --rm` で image のコンテナを起動し、 user のホームディレクトリを返却する。
+// 数値の UID や `user:group` 形式も、コンテナエンジンがそのまま解釈する。
+// イメージが無ければ pull するため、 dry-run モードでは実行しない。
+func imageHomeDirectory(command string, image string, user string) (string, error) {
+	runArgs := []string{"run", "--rm", "--entrypoint", "sh"}
+	if user != "" {
+		runArgs = append(runArgs, "--user", user)
+	}
+	runArgs = append(runArgs, image, "-c", homeDirectoryScript)
+
+	stdout, err := runner.Command(command, runArgs...).Output()
+	if err != nil {
+		return "", err
+	}
+	home := strings.TrimSpace(string(stdout))
+	if !strings.HasPrefix(home, "/") {
+		return "", fmt.Errorf("unexpected home directory of %s: %q", image, home)
+	}
+	return home, nil
+}
+
+// devcontainer.vim が対応しているコンテナエンジン
+var Docker Engine = cliEngine{name: EngineNameDocker, command: "docker"}
+var Podman Engine = cliEngine{name: EngineNamePodman, command: "podman"}
+var Nerdctl Engine = cliEngine{name: EngineNameNerdctl, command: "nerdctl", extractWithTar: true}
+
+var supportedEngines = []Engine{Docker, Podman, Nerdctl}
+
+// 現在使用しているコンテナエンジン
+var currentEngine = Docker
+
+// 使用するコンテナエンジンを返却する
+func CurrentEngine() Engine {
+	return currentEngine
+}
+
+// 使用するコンテナエンジンを設定する
+func SetEngine(engine Engine) {
+	currentEngine = engine
+}
+
+// エンジン名から Engine を返却する。
+// エンジン名が空文字の場合、 PATH に存在するエンジンから自動判定する。
+//
+// docker の場合、 Docker Engine API へ接続できるなら API を直接呼び出すエンジンを返却する(TLS 接続の場合を除く)。
+// ただし dry-run モードの場合や、 default 以外の docker context を使用している場合は
+// docker CLI を実行するエンジンを返却する。
+func FindEngine(name string) (Engine, error) {
+	if name == "" {
+		name = DetectEngine().Name()
+	}
+
+	// dry-run モードでは実行内容を argv で表示するため、 API を直接呼び出すエンジンは使わない
+	// default 以外の docker context は接続先(ssh や TLS など)の解決を docker CLI に任せる
+	if name == EngineNameDocker && !runner.IsDryRun() && currentDockerContext() == defaultDockerContext {
+		apiEngine, err := NewAPIEngine(EngineNameDocker, Docker.Command(), os.Getenv(envDockerHost))
+		if err == nil {
+			return apiEngine, nil
+		}
+		return Docker, nil
+	}
+
+	for _, engine := range supportedEngines {
+		if engine.Name() == name {
+			return engine, nil
+		}
+	}
+
+	names := []string{}
+	for _, engine := range supportedEngines {
+		names = append(names, engine.Name())
+	}
+	return nil, &UnknownEngineError{msg: fmt.Sprintf("unknown container engine: %s (available: %s)", name, strings.Join(names, ", "))}
+}
+
+// PATH に存在するエンジンが 1 つだけの場合、そのエンジンを返却する。
+// それ以外の場合は docker を返却する。
+func DetectEngine() Engine {
+	var found []Engine
+	for _, engine := range supportedEngines {
+		_, err := exec.LookPath(engine.Command())
+		if err == nil {
+			found = append(found, engine)
+		}
+	}
+
+	if len(found) == 1 {
+		return found[0]
+	}
+	return Docker
+}
@@ -0,0 +1,112 @@
+package docker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindEngine(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "docker", want: "docker"},
+		{name: "podman", want: "podman"},
+		{name: "nerdctl", want: "nerdctl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine, err := FindEngine(tt.name)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if engine.Command() != tt.want {
+				t.Fatalf("want %s, but got %s", tt.want, engine.Command())
+			}
+		})
+	}
+}
+
+func TestFindEngineUsesCliWithDockerContext(t *testing.T) {
+	t.Setenv(envDockerHost, "")
+	t.Setenv(envDockerContext, "remote")
+
+	engine, err := FindEngine(EngineNameDocker)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := engine.(cliEngine); !ok {
+		t.Fatalf("want cliEngine, but got %T", engine)
+	}
+}
+
+func TestFindEngineUnknown(t *testing.T) {
+	_, err := FindEngine("unknown")
+	var unknownEngineError *UnknownEngineError
+	if !errors.As(err, &unknownEngineError) {
+		t.Fatalf("want UnknownEngineError, but got %v", err)
+	}
+}
+
+func TestDetectEngineUsesOnlyEngineOnPath(t *testing.T) {
+	tempDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(tempDir, "podman"), []byte("#!/bin/sh\n"), 0755)
+	if err != nil {
+		t.Fatalf("failed to create mock podman command: %v", err)
+	}
+	t.Setenv("PATH", tempDir)
+
+	engine := DetectEngine()
+	if engine.Name() != EngineNamePodman {
+		t.Fatalf("want podman, but got %s", engine.Name())
+	}
+}
+
+func TestDetectEngineFallbackToDocker(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	engine := DetectEngine()
+	if engine.Name() != EngineNameDocker {
+		t.Fatalf("want docker, but got %s", engine.Name())
+	}
+}
+
+func TestCliEnginePsReadsLabelsFromInspect(t *testing.T) {
+	tempDir := t.TempDir()
+	// `ps` は `,` 区切りの Labels を、 `inspect` はラベルのオブジェクトを返す
+	script := `#!/bin/sh
+case "$1" in
+ps)
+  echo '{"ID":"0123456789ab","State":"running","Labels":"devcontainer.local_folder=/work,devcontainer.metadata=[{\"a\":1,\"b\":2}]"}'
+  ;;
+inspect)
+  echo '0123456789abcdef {"devcontainer.local_folder":"/work","devcontainer.metadata":"[{\"a\":1,\"b\":2}]"}'
+  ;;
+esac
+`
+	err := os.WriteFile(filepath.Join(tempDir, "docker"), []byte(script), 0755)
+	if err != nil {
+		t.Fatalf("failed to create mock docker command: %v", err)
+	}
+
+	psResult, err := cliEngine{name: EngineNameDocker, command: filepath.Join(tempDir, "docker")}.Ps("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	containers, err := UnmarshalPsCommandResults(psResult)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(containers) != 1 {
+		t.Fatalf("want 1 container, but got %v", containers)
+	}
+	if got := containers[0].Label("devcontainer.metadata"); got != `[{"a":1,"b":2}]` {
+		t.Fatalf("label containing `,` must not be split, got %s", got)
+	}
+	if got := containers[0].Label("devcontainer.local_folder"); got != "/work" {
+		t.Fatalf("want /work, but got %s", got)
+	}
+}
@@ -2,10 +2,10 @@ package dockercompose
 
 import (
 	"os"
-	"os/exec"
-)
 
-const containerCommand = "docker"
+	"github.com/mikoto2000/devcontainer.vim/v3/docker"
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
+)
 
 type PsCommandError struct {
 	msg string
@@ -55,7 +55,7 @@ func Ps(workspaceFolder string) (string, error) {
 		return "", &PsCommandError{msg: "ワークスペースへの移動に失敗しました。指定したディレクトリが存在するか・パーミッションが正しいかの確認をしてください。 "}
 	}
 
-	dockerComposePsCommand := exec.Command(containerCommand, "compose", "ps", "--all", "--format", "json")
+	dockerComposePsCommand := composeReadOnlyCommand("ps", "--all", "--format", "json")
 	stdout, err := dockerComposePsCommand.Output()
 	if err != nil {
 		return "", &PsCommandError{msg: "docker compose ps コマンドの実行に失敗しました。docker がインストールされているか・docker エンジンが起動しているかの確認をしてください。 "}
@@ -65,7 +65,7 @@ func Ps(workspaceFolder string) (string, error) {
 
 // `docker compose -p ${projectName} stop` を実行する。
 func Stop(projectName string) error {
-	dockerComposeStopCommand := exec.Command(containerCommand, "compose", "-p", projectName, "stop")
+	dockerComposeStopCommand := composeCommand("-p", projectName, "stop")
 	err := dockerComposeStopCommand.Start()
 	if err != nil {
 		return &StopCommandError{msg: "docker compose stop コマンドの実行に失敗しました。docker がインストールされているか・docker エンジンが起動しているかの確認をしてください。 "}
@@ -75,10 +75,22 @@ func Stop(projectName string) error {
 
 // `docker compose -p ${projectName} down` を実行する。
 func Down(projectName string) error {
-	dockerComposeDownCommand := exec.Command(containerCommand, "compose", "-p", projectName, "down")
+	dockerComposeDownCommand := composeCommand("-p", projectName, "down")
 	err := dockerComposeDownCommand.Start()
 	if err != nil {
 		return &DownCommandError{msg: "docker compose down コマンドの実行に失敗しました。docker がインストールされているか・docker エンジンが起動しているかの確認をしてください。 "}
 	}
 	return nil
 }
+
+// 使用中のコンテナエンジンで `<engine> compose ${args}` を実行するコマンドを組み立てる。
+func composeCommand(args ...string) *runner.Cmd {
+	composeArgs := append([]string{"compose"}, args...)
+	return runner.Command(docker.CurrentEngine().Command(), composeArgs...)
+}
+
+// 副作用の無い `<engine> compose ${args}` を実行するコマンドを組み立てる。 dry-run モードでも実行する。
+func composeReadOnlyCommand(args ...string) *runner.Cmd {
+	composeArgs := append([]string{"compose"}, args...)
+	return runner.ReadOnlyCommand(docker.CurrentEngine().Command(), composeArgs...)
+}
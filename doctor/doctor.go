@@ -0,0 +1,382 @@
+package doctor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/docker"
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
+	"github.com/mikoto2000/devcontainer.vim/v3/tools"
+	"github.com/mikoto2000/devcontainer.vim/v3/util"
+)
+
+const StatusOK = "ok"
+const StatusWarning = "warning"
+const StatusError = "error"
+const StatusSkipped = "skipped"
+
+// ホストのクリップボードポートへの疎通確認に使用するイメージ
+const containerCheckImage = "alpine:latest"
+
+// ホストのクリップボードポートへの疎通確認に使用する `--add-host` オプション
+const addHostOption = "--add-host=host.docker.internal:host-gateway"
+
+// ツールの `--version` 実行時のタイムアウト
+const toolVersionTimeout = 10 * time.Second
+
+// 診断項目ごとの結果
+type CheckResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// 診断結果
+type Report struct {
+	OK     bool          `json:"ok"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// 診断対象のディレクトリ
+type Dirs struct {
+	AppConfigDir             string
+	AppCacheDir              string
+	BinDir                   string
+	ConfigDirForDocker       string
+	ConfigDirForDevcontainer string
+}
+
+// GitHub API のレートリミットの状態
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+type DoctorUseServices interface {
+	// コンテナエンジンのバージョンを返却する
+	EngineVersion() (string, error)
+
+	// ツールを `--version` 付きで実行する
+	RunToolVersion(toolPath string) error
+
+	// GitHub API のレートリミットの状態を返却する
+	GetGitHubRateLimit() (RateLimit, error)
+
+	// WSL 上で動いているかを返却する
+	IsWsl() bool
+
+	// テスト用コンテナからホストの port へ接続できるかを確認する
+	CheckHostPortFromContainer(port int, runArgs []string) error
+}
+
+type DefaultDoctorUseServices struct{}
+
+func (s DefaultDoctorUseServices) EngineVersion() (string, error) {
+	return docker.Version()
+}
+
+func (s DefaultDoctorUseServices) RunToolVersion(toolPath string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), toolVersionTimeout)
+	defer cancel()
+	return exec.CommandContext(ctx, toolPath, "--version").Run()
+}
+
+func (s DefaultDoctorUseServices) GetGitHubRateLimit() (RateLimit, error) {
+	rate, err := util.GetGitHubRateLimit()
+	if err != nil {
+		return RateLimit{}, err
+	}
+	return RateLimit{Limit: rate.Limit, Remaining: rate.Remaining, Reset: rate.Reset.Time}, nil
+}
+
+func (s DefaultDoctorUseServices) IsWsl() bool {
+	return util.IsWsl()
+}
+
+func (s DefaultDoctorUseServices) CheckHostPortFromContainer(port int, runArgs []string) error {
+	args := append([]string{"run", "--rm"}, runArgs...)
+	args = append(args, containerCheckImage, "sh", "-c", "echo devcontainer.vim | nc -w 3 host.docker.internal "+strconv.Itoa(port))
+	output, err := runner.Command(docker.CurrentEngine().Command(), args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// 各診断を実行し、その結果を返却する。
+// checkContainer が false の場合、テスト用コンテナを使った診断は行わない。
+func Run(services DoctorUseServices, dirs Dirs, checkContainer bool) Report {
+	checks := []CheckResult{}
+
+	engineCheck := checkEngine(services)
+	checks = append(checks, engineCheck)
+	checks = append(checks, checkTools(services, dirs.BinDir)...)
+	checks = append(checks, checkDirs(dirs)...)
+	checks = append(checks, checkGitHub(services))
+	checks = append(checks, checkWsl(services))
+
+	if !checkContainer {
+		checks = append(checks, CheckResult{Name: "clipboard port", Status: StatusSkipped, Message: "container check is disabled."})
+	} else if engineCheck.Status != StatusOK {
+		checks = append(checks, CheckResult{Name: "clipboard port", Status: StatusSkipped, Message: "container engine is not reachable."})
+	} else {
+		checks = append(checks, checkClipboardPort(services))
+	}
+
+	ok := true
+	for _, check := range checks {
+		if check.Status == StatusError {
+			ok = false
+		}
+	}
+	return Report{OK: ok, Checks: checks}
+}
+
+func checkEngine(services DoctorUseServices) CheckResult {
+	name := "engine"
+	engine := docker.CurrentEngine()
+	version, err := services.EngineVersion()
+	if err != nil {
+		return CheckResult{
+			Name:    name,
+			Status:  StatusError,
+			Message: fmt.Sprintf("%s is not reachable: %v", engine.Name(), err),
+			Hint:    fmt.Sprintf("`%s` がインストールされ、エンジンが起動していることを確認してください。別のエンジンを使う場合は `--engine` オプションで指定してください。", engine.Command()),
+		}
+	}
+	return CheckResult{Name: name, Status: StatusOK, Message: fmt.Sprintf("%s %s", engine.Name(), version)}
+}
+
+func checkTools(services DoctorUseServices, binDir string) []CheckResult {
+	checks := []CheckResult{}
+
+	// ホスト上で実行するツールは必ず存在する必要がある
+	devcontainerFileName := tools.DEVCONTAINER(tools.DefaultInstallerUseServices{}).FileName
+	hostTools := []string{devcontainerFileName, tools.CDR(tools.DefaultInstallerUseServices{}).FileName}
+	for _, hostTool := range hostTools {
+		if !util.IsExists(filepath.Join(binDir, hostTool)) {
+			checks = append(checks, CheckResult{
+				Name:    "tool " + hostTool,
+				Status:  StatusWarning,
+				Message: "not downloaded yet.",
+				Hint:    fmt.Sprintf("`devcontainer.vim tool %s download` でダウンロードできます(`start` 時にも自動でダウンロードされます)。", strings.TrimSuffix(hostTool, ".exe")),
+			})
+		}
+	}
+
+	// manifest.json やダウンロード途中のファイルなど、ツール以外のファイルは対象外とする
+	installed, err := tools.ListInstalled(binDir)
+	if err != nil {
+		return append(checks, CheckResult{
+			Name:    "tools",
+			Status:  StatusError,
+			Message: fmt.Sprintf("cannot read %s: %v", binDir, err),
+			Hint:    fmt.Sprintf("`%s` のパーミッションを確認してください。", binDir),
+		})
+	}
+
+	for _, tool := range installed {
+		toolPath := tool.Path
+		fileName := filepath.Base(toolPath)
+		name := "tool " + fileName
+		redownloadHint := fmt.Sprintf("`%s` を削除し、 `devcontainer.vim tool` サブコマンドで再ダウンロードしてください。", toolPath)
+
+		fileInfo, err := os.Stat(toolPath)
+		if err != nil {
+			checks = append(checks, CheckResult{Name: name, Status: StatusError, Message: err.Error(), Hint: redownloadHint})
+			continue
+		}
+		if fileInfo.Size() == 0 {
+			checks = append(checks, CheckResult{Name: name, Status: StatusError, Message: "file is empty.", Hint: redownloadHint})
+			continue
+		}
+		if runtime.GOOS != "windows" && fileInfo.Mode().Perm()&0111 == 0 {
+			checks = append(checks, CheckResult{
+				Name:    name,
+				Status:  StatusError,
+				Message: "file is not executable.",
+				Hint:    fmt.Sprintf("`chmod +x %s` で実行権限を付与してください。", toolPath),
+			})
+			continue
+		}
+
+		// devcontainer CLI はホスト上で実行できるかまで確認する
+		if fileName == devcontainerFileName {
+			err = services.RunToolVersion(toolPath)
+			if err != nil {
+				checks = append(checks, CheckResult{Name: name, Status: StatusError, Message: fmt.Sprintf("failed to run: %v", err), Hint: redownloadHint})
+				continue
+			}
+		}
+
+		checks = append(checks, CheckResult{Name: name, Status: StatusOK, Message: fmt.Sprintf("%d bytes, executable.", fileInfo.Size())})
+	}
+
+	return checks
+}
+
+func checkDirs(dirs Dirs) []CheckResult {
+	checks := []CheckResult{}
+	for _, dir := range []string{dirs.AppConfigDir, dirs.AppCacheDir, dirs.BinDir, dirs.ConfigDirForDocker, dirs.ConfigDirForDevcontainer} {
+		name := "directory " + dir
+
+		// 実際にファイルを作成して書き込めることを確認する
+		f, err := os.CreateTemp(dir, ".doctor-*")
+		if err != nil {
+			checks = append(checks, CheckResult{
+				Name:    name,
+				Status:  StatusError,
+				Message: fmt.Sprintf("not writable: %v", err),
+				Hint:    fmt.Sprintf("`%s` の所有者とパーミッションを確認してください。", dir),
+			})
+			continue
+		}
+		f.Close()
+		os.Remove(f.Name())
+
+		checks = append(checks, CheckResult{Name: name, Status: StatusOK, Message: "writable."})
+	}
+	return checks
+}
+
+func checkGitHub(services DoctorUseServices) CheckResult {
+	name := "github api"
+	if tools.IsOffline() {
+		return CheckResult{Name: name, Status: StatusSkipped, Message: "offline mode is enabled."}
+	}
+
+	rateLimit, err := services.GetGitHubRateLimit()
+	if err != nil {
+		return CheckResult{
+			Name:    name,
+			Status:  StatusError,
+			Message: fmt.Sprintf("not reachable: %v", err),
+			Hint:    "ネットワーク接続とプロキシ設定を確認してください。ツールのダウンロードには api.github.com への接続が必要です。",
+		}
+	}
+
+	message := fmt.Sprintf("rate limit %d/%d, reset at %s.", rateLimit.Remaining, rateLimit.Limit, rateLimit.Reset.Local().Format(time.RFC3339))
+	if rateLimit.Remaining == 0 {
+		hint := fmt.Sprintf("GitHub API のレートリミットに達しています。 %s 以降に再実行してください。", rateLimit.Reset.Local().Format(time.RFC3339))
+		if util.GitHubToken() == "" {
+			hint += fmt.Sprintf(" 環境変数 %s でトークンを設定すると、レートリミットが緩和されます。", strings.Join(util.GitHubTokenEnvs, " または "))
+		}
+		return CheckResult{
+			Name:    name,
+			Status:  StatusError,
+			Message: message,
+			Hint:    hint,
+		}
+	}
+	return CheckResult{Name: name, Status: StatusOK, Message: message}
+}
+
+func checkWsl(services DoctorUseServices) CheckResult {
+	name := "wsl"
+	if !services.IsWsl() {
+		return CheckResult{Name: name, Status: StatusOK, Message: "not running on WSL."}
+	}
+
+	if !util.IsExistsCommand("script") {
+		return CheckResult{
+			Name:    name,
+			Status:  StatusWarning,
+			Message: "running on WSL, but `script` command not found.",
+			Hint:    "WSL 上では端末制御のために `script` コマンドを使用します。 util-linux パッケージをインストールしてください。",
+		}
+	}
+	return CheckResult{Name: name, Status: StatusOK, Message: "running on WSL. clipboard-data-receiver.exe is used."}
+}
+
+func checkClipboardPort(services DoctorUseServices) CheckResult {
+	name := "clipboard port"
+
+	// clipboard-data-receiver の代わりに、ランダムポートで待ち受ける
+	listener, err := net.Listen("tcp", "0.0.0.0:0")
+	if err != nil {
+		return CheckResult{Name: name, Status: StatusError, Message: fmt.Sprintf("cannot listen: %v", err)}
+	}
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	received := make(chan struct{}, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			io.Copy(io.Discard, conn)
+			conn.Close()
+			if strings.TrimSpace(line) == "devcontainer.vim" {
+				received <- struct{}{}
+			}
+		}
+	}()
+
+	reached := func(runArgs []string) bool {
+		err := services.CheckHostPortFromContainer(port, runArgs)
+		if err != nil {
+			return false
+		}
+		select {
+		case <-received:
+			return true
+		case <-time.After(3 * time.Second):
+			return false
+		}
+	}
+
+	if reached([]string{}) {
+		return CheckResult{Name: name, Status: StatusOK, Message: fmt.Sprintf("container can reach host.docker.internal:%d.", port)}
+	}
+	if reached([]string{addHostOption}) {
+		return CheckResult{
+			Name:    name,
+			Status:  StatusWarning,
+			Message: fmt.Sprintf("container can reach host.docker.internal:%d only with `%s`.", port, addHostOption),
+			Hint:    fmt.Sprintf("devcontainer.vim.json の `runArgs` に `\"%s\"` を追加してください。", addHostOption),
+		}
+	}
+	return CheckResult{
+		Name:    name,
+		Status:  StatusError,
+		Message: fmt.Sprintf("container cannot reach host.docker.internal:%d.", port),
+		Hint:    "ファイアウォールがコンテナからホストへの接続を遮断していないか確認してください。",
+	}
+}
+
+// 診断結果を表形式で w へ出力し、最後に対処方法を出力する。
+func WriteReport(w io.Writer, report Report) {
+	for _, check := range report.Checks {
+		fmt.Fprintf(w, "[%-7s] %s: %s\n", check.Status, check.Name, check.Message)
+	}
+
+	hints := []string{}
+	for _, check := range report.Checks {
+		if check.Hint != "" {
+			hints = append(hints, fmt.Sprintf("- %s: %s", check.Name, check.Hint))
+		}
+	}
+	if len(hints) == 0 {
+		fmt.Fprintln(w, "\nNo problems found.")
+		return
+	}
+	fmt.Fprintln(w, "\nHints:")
+	for _, hint := range hints {
+		fmt.Fprintln(w, hint)
+	}
+}
@@ -0,0 +1,160 @@
+package doctor
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeDoctorUseServices struct {
+	engineErr      error
+	toolErr        error
+	rateLimit      RateLimit
+	rateLimitErr   error
+	wsl            bool
+	needsAddHost   bool
+	containerCalls *int
+}
+
+func (s fakeDoctorUseServices) EngineVersion() (string, error) {
+	return "27.3.1", s.engineErr
+}
+
+func (s fakeDoctorUseServices) RunToolVersion(toolPath string) error {
+	return s.toolErr
+}
+
+func (s fakeDoctorUseServices) GetGitHubRateLimit() (RateLimit, error) {
+	return s.rateLimit, s.rateLimitErr
+}
+
+func (s fakeDoctorUseServices) IsWsl() bool {
+	return s.wsl
+}
+
+// コンテナの代わりにホストから直接ポートへ接続する
+func (s fakeDoctorUseServices) CheckHostPortFromContainer(port int, runArgs []string) error {
+	if s.containerCalls != nil {
+		*s.containerCalls++
+	}
+	if s.needsAddHost && len(runArgs) == 0 {
+		return errors.New("bad address 'host.docker.internal'")
+	}
+	return sendToLocalPort(port)
+}
+
+func createDirs(t *testing.T) Dirs {
+	t.Helper()
+	baseDir := t.TempDir()
+	dirs := Dirs{
+		AppConfigDir:             filepath.Join(baseDir, "config"),
+		AppCacheDir:              filepath.Join(baseDir, "cache"),
+		BinDir:                   filepath.Join(baseDir, "cache", "bin"),
+		ConfigDirForDocker:       filepath.Join(baseDir, "cache", "config", "docker"),
+		ConfigDirForDevcontainer: filepath.Join(baseDir, "cache", "config", "devcontainer"),
+	}
+	for _, dir := range []string{dirs.AppConfigDir, dirs.BinDir, dirs.ConfigDirForDocker, dirs.ConfigDirForDevcontainer} {
+		if err := os.MkdirAll(dir, 0766); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+	}
+	return dirs
+}
+
+func findCheck(t *testing.T, report Report, name string) CheckResult {
+	t.Helper()
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	t.Fatalf("check not found: %s", name)
+	return CheckResult{}
+}
+
+func TestRunReportsHealthyEnvironment(t *testing.T) {
+	dirs := createDirs(t)
+	os.WriteFile(filepath.Join(dirs.BinDir, "vim_amd64"), []byte("binary"), 0755)
+	// ツール以外のファイルは対象外
+	os.WriteFile(filepath.Join(dirs.BinDir, "manifest.json"), []byte("{}"), 0666)
+	os.WriteFile(filepath.Join(dirs.BinDir, "tmux_amd64.part"), []byte{}, 0644)
+
+	report := Run(fakeDoctorUseServices{rateLimit: RateLimit{Limit: 60, Remaining: 59, Reset: time.Now()}}, dirs, true)
+
+	if !report.OK {
+		t.Fatalf("want ok, but got: %#v", report)
+	}
+	if check := findCheck(t, report, "tool vim_amd64"); check.Status != StatusOK {
+		t.Fatalf("unexpected tool check: %#v", check)
+	}
+	for _, check := range report.Checks {
+		if check.Name == "tool manifest.json" || check.Name == "tool tmux_amd64.part" {
+			t.Fatalf("non-tool file must not be checked: %#v", check)
+		}
+	}
+	if check := findCheck(t, report, "clipboard port"); check.Status != StatusOK {
+		t.Fatalf("unexpected clipboard check: %#v", check)
+	}
+}
+
+func TestRunReportsProblemsWithHints(t *testing.T) {
+	dirs := createDirs(t)
+	os.WriteFile(filepath.Join(dirs.BinDir, "tmux_amd64"), []byte("binary"), 0644)
+	os.WriteFile(filepath.Join(dirs.BinDir, "nvim_amd64"), []byte{}, 0755)
+
+	reset := time.Now().Add(30 * time.Minute)
+	report := Run(fakeDoctorUseServices{rateLimit: RateLimit{Limit: 60, Remaining: 0, Reset: reset}, needsAddHost: true}, dirs, true)
+
+	if report.OK {
+		t.Fatalf("want not ok, but got ok")
+	}
+	for _, name := range []string{"tool tmux_amd64", "tool nvim_amd64", "github api"} {
+		check := findCheck(t, report, name)
+		if check.Status != StatusError || check.Hint == "" {
+			t.Fatalf("want error with hint, but got: %#v", check)
+		}
+	}
+	clipboardCheck := findCheck(t, report, "clipboard port")
+	if clipboardCheck.Status != StatusWarning || !strings.Contains(clipboardCheck.Hint, addHostOption) {
+		t.Fatalf("want add-host hint, but got: %#v", clipboardCheck)
+	}
+
+	var out bytes.Buffer
+	WriteReport(&out, report)
+	if !strings.Contains(out.String(), "Hints:") {
+		t.Fatalf("report must contain hints: %s", out.String())
+	}
+}
+
+func TestRunSkipsContainerCheckWhenEngineUnreachable(t *testing.T) {
+	dirs := createDirs(t)
+	containerCalls := 0
+
+	report := Run(fakeDoctorUseServices{engineErr: errors.New("connection refused"), containerCalls: &containerCalls}, dirs, true)
+
+	if check := findCheck(t, report, "engine"); check.Status != StatusError {
+		t.Fatalf("unexpected engine check: %#v", check)
+	}
+	if check := findCheck(t, report, "clipboard port"); check.Status != StatusSkipped {
+		t.Fatalf("unexpected clipboard check: %#v", check)
+	}
+	if containerCalls != 0 {
+		t.Fatalf("must not run container, but called %d times", containerCalls)
+	}
+}
+
+func sendToLocalPort(port int) error {
+	conn, err := net.Dial("tcp", "127.0.0.1:"+strconv.Itoa(port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte("devcontainer.vim\n"))
+	return err
+}
@@ -3,7 +3,6 @@ module github.com/mikoto2000/devcontainer.vim/v3
 go 1.26
 
 require (
-	github.com/Jeffail/gabs/v2 v2.7.0
 	github.com/anmitsu/go-shlex v0.0.0-20200514113438-38f4b401e2be
 	github.com/google/go-github/v62 v62.0.0
 	github.com/manifoldco/promptui v0.9.0
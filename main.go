@@ -8,14 +8,21 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
+	"time"
 
-	"github.com/anmitsu/go-shlex"
 	"github.com/manifoldco/promptui"
 	"github.com/urfave/cli/v2"
 
 	"github.com/mikoto2000/devcontainer.vim/v3/devcontainer"
+	"github.com/mikoto2000/devcontainer.vim/v3/docker"
+	"github.com/mikoto2000/devcontainer.vim/v3/doctor"
 	"github.com/mikoto2000/devcontainer.vim/v3/oras"
+	"github.com/mikoto2000/devcontainer.vim/v3/output"
+	"github.com/mikoto2000/devcontainer.vim/v3/runargs"
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
+	"github.com/mikoto2000/devcontainer.vim/v3/settings"
 	"github.com/mikoto2000/devcontainer.vim/v3/tools"
 	"github.com/mikoto2000/devcontainer.vim/v3/util"
 )
@@ -34,12 +41,87 @@ type AvailableTemplateItem struct {
 	Name    string `json:"name"`
 }
 
-const containerCommand = "docker"
+// `config --generate` の実行結果
+type ConfigGenerateResult struct {
+	OutputFile string `json:"outputFile,omitempty"`
+	Content    string `json:"content,omitempty"`
+}
+
+// `config show` の実行結果
+type ConfigShowResult struct {
+	Layers     []util.ConfigLayer `json:"layers"`
+	Config     any                `json:"config"`
+	Provenance map[string]string  `json:"provenance"`
+}
+
+// `config show --diff` の実行結果
+type ConfigDiffResult struct {
+	Layers  []util.ConfigLayer  `json:"layers"`
+	Changes []util.ConfigChange `json:"changes"`
+}
+
+// `config validate` の実行結果
+type ConfigValidateResult struct {
+	Layers   []util.ConfigLayer       `json:"layers"`
+	Problems []util.ValidationProblem `json:"problems"`
+}
+
+// `settings show` の実行結果
+type SettingsShowResult struct {
+	SettingsFile string           `json:"settingsFile"`
+	Settings     []settings.Entry `json:"settings"`
+}
+
+// `runargs list` の実行結果
+type RunargsListResult struct {
+	RunargsFile string          `json:"runargsFile"`
+	Profiles    []runargs.Entry `json:"profiles"`
+}
+
+// `runargs show` の実行結果
+type RunargsShowResult struct {
+	Profiles []string `json:"profiles"`
+	Args     []string `json:"args"`
+}
+
+// `runargs generate`, `runargs edit` の実行結果
+type RunargsGenerateResult struct {
+	RunargsFile string `json:"runargsFile"`
+	Profile     string `json:"profile,omitempty"`
+}
+
+// `tool * download`, `tool * install` の実行結果
+type ToolDownloadResult struct {
+	Tool string `json:"tool"`
+	Arch string `json:"arch,omitempty"`
+	Path string `json:"path"`
+}
+
+// `tool list` の実行結果
+type ToolListResult struct {
+	BinDir string                `json:"binDir"`
+	Tools  []tools.InstalledTool `json:"tools"`
+}
+
+// `tool update` の実行結果
+type ToolUpdateResult struct {
+	Tools []tools.UpdatedTool `json:"tools"`
+}
+
+// `tool remove`, `tool gc` の実行結果
+type ToolRemoveResult struct {
+	Removed []tools.InstalledTool `json:"removed"`
+}
+
+// `index update` の実行結果
+type IndexUpdateResult struct {
+	IndexFile string `json:"indexFile"`
+}
 
 var version = "dev"
 
-const envDevcontainerVimType = "DEVCONTAINER_VIM_TYPE"
-const envDevcontainerShellType = "DEVCONTAINER_SHELL_TYPE"
+// テンプレートインデックスを取得する OCI リポジトリ
+const defaultTemplateIndexRepository = "ghcr.io/devcontainers/index"
 
 const flagNameLicense = "license"
 const flagNameNeoVim = "nvim"
@@ -48,11 +130,27 @@ const flagNameNoPf = "nopf"
 const flagNameNoTmux = "notmux"
 const flagNameShell = "shell"
 const flagNameArch = "arch"
+const flagNameVersion = "version"
+const flagNameEngine = "engine"
+const flagNameDryRun = "dry-run"
+const flagNameVerbose = "verbose"
+const flagNameOffline = "offline"
+const flagNameFromFile = "from-file"
+const flagNameAll = "all"
+const flagNameDays = "days"
 
 const flagNameGenerate = "generate"
 const flagNameHome = "home"
 const flagNameOutput = "output"
+const flagNameFormat = "format"
 const flagNameOpen = "open"
+const flagNameUser = "user"
+const flagNameDiff = "diff"
+const flagNameStrict = "strict"
+const flagNameNoContainer = "no-container"
+const flagNameImage = "image"
+const flagNameConfig = "config"
+const flagNameName = "name"
 
 //go:embed LICENSE
 var license string
@@ -66,8 +164,6 @@ var bash_complete_func string
 //go:embed devcontainer.vim.template.json
 var devcontainerVimJSONTemplate string
 
-const runargsContent = "-v \"$(pwd):/work\" -v \"$HOME/.vim:/root/.vim\" -v \"$HOME/.gitconfig:/root/.gitconfig\" -v \"$HOME/.ssh:/root/.ssh\" --workdir /work"
-
 //go:embed vimrc.template.vim
 var additionalVimrc string
 
@@ -77,7 +173,7 @@ func main() {
 	// Windows でも `${ localEnv:HOME }` でホームディレクトリの指定ができるように、
 	// 環境変数を更新
 	if runtime.GOOS == "windows" {
-		fmt.Printf("Set environment variable HOME to %s.\n", os.Getenv("USERPROFILE"))
+		fmt.Fprintf(os.Stderr, "Set environment variable HOME to %s.\n", os.Getenv("USERPROFILE"))
 		os.Setenv("HOME", os.Getenv("USERPROFILE"))
 	}
 
@@ -99,6 +195,9 @@ func main() {
 		os.Exit(1)
 	}
 
+	// 最新リリースのタグ名はキャッシュディレクトリへキャッシュし、 GitHub API への問い合わせを減らす
+	tools.SetReleaseCacheFile(filepath.Join(appCacheDir, tools.ReleaseCacheFileName))
+
 	// vimrc ファイルの出力先を組み立て
 	// vimrc を出力(既に存在するなら何もしない)
 	vimrc := filepath.Join(appConfigDir, "vimrc")
@@ -108,19 +207,32 @@ func main() {
 			fmt.Fprintf(os.Stderr, "Error creating vimrc file: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Printf("Generated additional vimrc to: %s\n", vimrc)
+		fmt.Fprintf(os.Stderr, "Generated additional vimrc to: %s\n", vimrc)
 	}
 
-	// runargs ファイルの出力先を組み立て
-	// runargs を出力(既に存在するなら何もしない)
-	runargs := filepath.Join(appConfigDir, "runargs")
-	if !util.IsExists(runargs) {
-		err := util.CreateFileWithContents(runargs, runargsContent, 0666)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating runargs file: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Printf("Generated additional runargs to: %s\n", runargs)
+	// runargs プロファイルファイルの出力先を組み立て
+	// runargs プロファイルファイルを出力(既に存在するなら何もしない)
+	// プロファイル導入前の runargs ファイルがあれば、その内容を default プロファイルとして引き継ぐ
+	runargsFile := filepath.Join(appConfigDir, runargs.FileName)
+	created, err := runargs.CreateIfNotExists(runargsFile, filepath.Join(appConfigDir, runargs.LegacyFileName))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating runargs file: %v\n", err)
+		os.Exit(1)
+	}
+	if created {
+		fmt.Fprintf(os.Stderr, "Generated additional runargs to: %s\n", runargsFile)
+	}
+
+	// ユーザー共通の追加設定ファイル(devcontainer.vim.json)のパスを組み立て
+	// `config --user --generate` で生成した場合のみ使用する
+	userAdditionalConfig := filepath.Join(appConfigDir, devcontainer.UserAdditionalConfigFileName)
+
+	// ユーザー設定ファイルの読み込み
+	settingsFile := filepath.Join(appConfigDir, settings.FileName)
+	userSettings, err := settings.Load(settingsFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading settings file: %v\n", err)
+		os.Exit(1)
 	}
 
 	devcontainerVimArgProcess := (&cli.App{
@@ -165,13 +277,70 @@ func main() {
 				Value: "",
 				Usage: "start with shell.",
 			},
+			&cli.StringFlag{
+				Name:  flagNameEngine,
+				Value: "",
+				Usage: "container engine to use (docker, podman, nerdctl). auto detect if not specified.",
+			},
+			&cli.StringFlag{
+				Name:  flagNameFormat,
+				Value: output.FormatText,
+				Usage: "output format (text, json). with json, results are printed to stdout and progress to stderr.",
+			},
+			&cli.BoolFlag{
+				Name:               flagNameDryRun,
+				Value:              false,
+				DisableDefaultText: true,
+				Usage:              "print commands and file operations instead of executing them.",
+			},
+			&cli.BoolFlag{
+				Name:               flagNameVerbose,
+				Value:              false,
+				DisableDefaultText: true,
+				Usage:              "print external commands before executing them.",
+			},
+			&cli.BoolFlag{
+				Name:               flagNameOffline,
+				Value:              false,
+				DisableDefaultText: true,
+				Usage:              "never access the network. use only downloaded tools and index.",
+			},
+		},
+		Before: func(cCtx *cli.Context) error {
+			// 出力形式の設定
+			// dry-run の出力先も進捗表示と合わせる
+			err := output.SetFormat(cCtx.String(flagNameFormat))
+			if err != nil {
+				return err
+			}
+			runner.SetOutput(output.Progress())
+
+			// dry-run モードはエンジン判定より先に設定する
+			runner.SetDryRun(cCtx.Bool(flagNameDryRun))
+
+			// コンテナエンジン、 verbose モードはプロジェクト設定を読み込む前に決める必要があるため、
+			// プロジェクト設定を除いて解決する
+			// ツールのバージョン固定も同様に設定し、ワークスペースフォルダが分かるサブコマンドではロックファイルを含めて再設定する
+			resolvedSettings := resolveSettings(cCtx, userSettings, "", nil)
+			runner.SetVerbose(resolvedSettings.Verbose)
+			configureTools(resolvedSettings)
+
+			// コンテナエンジン判定
+			// 設定で指定されていなければ自動判定
+			engine, err := docker.FindEngine(resolvedSettings.Engine)
+			if err != nil {
+				return err
+			}
+			docker.SetEngine(engine)
+
+			return nil
 		},
 		Action: func(cCtx *cli.Context) error {
 			// ライセンスフラグが立っていればライセンスを表示して終
 			if cCtx.Bool(flagNameLicense) {
-				fmt.Println(license)
-				fmt.Println()
-				fmt.Println(notice)
+				fmt.Fprintln(output.Progress(), license)
+				fmt.Fprintln(output.Progress())
+				fmt.Fprintln(output.Progress(), notice)
 				os.Exit(0)
 			}
 
@@ -181,101 +350,76 @@ func main() {
 			{
 				Name:            "run",
 				Usage:           "Run container use `docker run`",
-				UsageText:       "devcontainer.vim run [DOCKER_OPTIONS...] [DOCKER_ARGS...]",
+				UsageText:       "devcontainer.vim run [--profile NAME] [DOCKER_OPTIONS...] [DOCKER_ARGS...]",
 				HideHelp:        true,
 				SkipFlagParsing: true,
 				Action: func(cCtx *cli.Context) error {
 					// `docker run` でコンテナを立てる
 
 					// Requirements のチェック
-					// 1. docker
+					// 1. コンテナエンジン
+					containerCommand := docker.CurrentEngine().Command()
 					isExistsDocker := util.IsExistsCommand(containerCommand)
 					if !isExistsDocker {
-						fmt.Fprintf(os.Stderr, "docker not found.")
+						fmt.Fprintf(os.Stderr, "%s not found.", containerCommand)
 						os.Exit(1)
 					}
 
-					// シェル使用判定
-					shell := ""
-					if cCtx.String(flagNameShell) != "" {
-						shell = cCtx.String(flagNameShell)
-					} else if os.Getenv(envDevcontainerShellType) != "" {
-						shell = os.Getenv(envDevcontainerShellType)
-					}
-
-					// cdr 使用判定
-					noCdr := false
-					if cCtx.String(flagNameNoCdr) != "" {
-						noCdr = cCtx.Bool(flagNameNoCdr)
-					}
-
-					// port-forwarder 使用判定
-					noPf := false
-					if cCtx.String(flagNameNoPf) != "" {
-						noPf = cCtx.Bool(flagNameNoPf)
-					}
-
-					// tmux 使用判定
-					noTmux := false
-					if cCtx.String(flagNameNoTmux) != "" {
-						noTmux = cCtx.Bool(flagNameNoTmux)
-					}
+					// エディター、シェル、 tmux, cdr, port-forwarder 使用判定
+					// `docker run` で起動する場合、プロジェクト設定は無い
+					// ロックファイルはカレントディレクトリのものを使用する
+					resolvedSettings := resolveSettings(cCtx, userSettings, ".", nil)
+					configureTools(resolvedSettings)
+					nvim := resolvedSettings.Nvim()
+					shell := resolvedSettings.Shell
+					noCdr := !resolvedSettings.Clipboard
+					noPf := !resolvedSettings.PortForwarding
+					noTmux := !resolvedSettings.Tmux
 
 					// 必要なファイルのダウンロード
-
-					nvim := false
-					if cCtx.Bool(flagNameNeoVim) || os.Getenv(envDevcontainerVimType) == "nvim" {
-						nvim = true
-					}
 					cdrPath, err := tools.InstallRunTools(binDir, nvim)
 					if err != nil {
 						fmt.Fprintf(os.Stderr, "Error installing run tools: %v\n", err)
 						os.Exit(1)
 					}
 
+					// `--profile` を取り除き、残りを `docker run` の引数とする
+					profileName, args := runargs.ParseProfileArgs(cCtx.Args().Slice())
+					if len(args) == 0 {
+						fmt.Fprintf(os.Stderr, "Usage: devcontainer.vim run [--profile NAME] <IMAGE_OR_CONTAINER>\n")
+						os.Exit(1)
+					}
+
 					// デフォルト引数取得
-					defaultRunargsBytes, err := os.ReadFile(runargs)
+					// 末尾のイメージ名でプロファイルを自動選択する
+					profiles, err := runargs.Load(runargsFile)
 					if err != nil {
 						fmt.Fprintf(os.Stderr, "Error reading runargs: %v\n", err)
 						os.Exit(1)
 					}
-					defaultRunargsString := string(defaultRunargsBytes)
-
-					if runtime.GOOS == "windows" {
-						// コンテナ起動
-						// windows はシェル変数展開が上手くいかないので runargs を使用しない
-						err = devcontainer.Run(cCtx.Args().Slice(), noCdr, noPf, noTmux, cdrPath, binDir, nvim, shell, configDirForDocker, vimrc, []string{})
-						if err != nil {
-							fmt.Fprintf(os.Stderr, "Error running docker: %v\n", err)
-							os.Exit(1)
-						}
-					} else {
-						// デフォルト引数内のシェル変数を展開
-						extractedDofaultRunargsString, err := util.ExtractShellVariables(defaultRunargsString)
-						if err != nil {
-							fmt.Fprintf(os.Stderr, "Error extracting shell variables: %v\n", err)
-							os.Exit(1)
-						}
+					selectedProfiles, err := profiles.Select(profileName, args[len(args)-1])
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Error selecting runargs profile: %v\n", err)
+						os.Exit(1)
+					}
+					if len(selectedProfiles) > 0 {
+						fmt.Fprintf(os.Stderr, "Use runargs profile: %s\n", strings.Join(selectedProfiles, ", "))
+					}
 
-						// 展開したものを配列へ分割
-						defaultRunargs, err := shlex.Split(extractedDofaultRunargsString, true)
-						if err != nil {
-							fmt.Fprintf(os.Stderr, "Error splitting runargs: %v\n", err)
-							os.Exit(1)
-						}
+					// デフォルト引数内のシェル変数を展開し、配列へ分割
+					defaultRunargs, err := profiles.Expand(selectedProfiles, resolvedSettings.RunargsCommandSubstitution)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Error extracting runargs: %v\n", err)
+						os.Exit(1)
+					}
 
-						// コンテナ起動
-						args := cCtx.Args().Slice()
-						if len(args) == 0 {
-							fmt.Fprintf(os.Stderr, "Usage: devcontainer.vim run <IMAGE_OR_CONTAINER>\n")
-							os.Exit(1)
-						}
-						err = devcontainer.Run(cCtx.Args().Slice(), noCdr, noPf, noTmux, cdrPath, binDir, nvim, shell, configDirForDocker, vimrc, defaultRunargs)
-						if err != nil {
-							fmt.Fprintf(os.Stderr, "Error running docker: %v\n", err)
-							os.Exit(1)
-						}
+					// コンテナ起動
+					result, err := devcontainer.Run(args, noCdr, noPf, noTmux, cdrPath, binDir, nvim, shell, configDirForDocker, vimrc, defaultRunargs)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Error running docker: %v\n", err)
+						os.Exit(1)
 					}
+					writeJSONResult(result)
 
 					return nil
 				},
@@ -295,13 +439,17 @@ func main() {
 							indexFileName := "devcontainer-index.json"
 							indexFile := filepath.Join(appCacheDir, indexFileName)
 							if !util.IsExists(indexFile) {
-								fmt.Println("Download template index ... ")
-								err := oras.Pull("ghcr.io/devcontainers/index", "latest", appCacheDir)
+								if tools.IsOffline() {
+									fmt.Fprintf(os.Stderr, "Error: template index %s is not downloaded and offline mode is enabled. Run `devcontainer.vim index update` without offline mode first.\n", indexFile)
+									os.Exit(1)
+								}
+								fmt.Fprintln(output.Progress(), "Download template index ... ")
+								err := oras.Pull(templateIndexRepository(), "latest", appCacheDir)
 								if err != nil {
 									fmt.Fprintf(os.Stderr, "Error downloading template index: %v\n", err)
 									os.Exit(1)
 								}
-								fmt.Println("done.")
+								fmt.Fprintln(output.Progress(), "done.")
 							}
 
 							var indexRoot IndexRoot
@@ -364,7 +512,7 @@ func main() {
 							templateID := selectedItem.ID + ":" + selectedItem.Version
 
 							// devcontainer を用いたコンテナ立ち上げ
-							output, err := devcontainer.Templates(
+							templateOutput, err := devcontainer.Templates(
 								devcontainerFilePath,
 								workspaceFolder,
 								templateID)
@@ -373,7 +521,7 @@ func main() {
 								os.Exit(1)
 							}
 
-							fmt.Println(output)
+							fmt.Fprintln(output.Progress(), templateOutput)
 
 							return nil
 						},
@@ -383,77 +531,186 @@ func main() {
 			{
 				Name:            "start",
 				Usage:           "Run `devcontainer up` and `devcontainer exec`",
-				UsageText:       "devcontainer.vim start [DEVCONTAINER_OPTIONS...] WORKSPACE_FOLDER",
+				UsageText:       "devcontainer.vim start [--config PATH|--name NAME] [DEVCONTAINER_OPTIONS...] WORKSPACE_FOLDER",
 				HideHelp:        true,
 				SkipFlagParsing: true,
 				Action: func(cCtx *cli.Context) error {
 					// devcontainer でコンテナを立てる
 
-					// シェル使用判定
-					shell := ""
-					if cCtx.String(flagNameShell) != "" {
-						shell = cCtx.String(flagNameShell)
-					} else if os.Getenv(envDevcontainerShellType) != "" {
-						shell = os.Getenv(envDevcontainerShellType)
+					// コマンドライン引数の末尾は `--workspace-folder` の値として使う
+					args := cCtx.Args().Slice()
+					if len(args) == 0 {
+						fmt.Fprintf(os.Stderr, "Error: missing workspace folder.\n")
+						fmt.Fprintf(os.Stderr, "Usage: devcontainer.vim start [--config PATH|--name NAME] <WORKSPACE_FOLDER>\n")
+						os.Exit(1)
 					}
+					args = selectDevcontainerConfig(args)
+					workspaceFolder := args[len(args)-1]
+					configurationFile, _, _ := devcontainer.ParseConfigArgs(args)
 
-					// cdr 使用判定
-					noCdr := false
-					if cCtx.String(flagNameNoCdr) != "" {
-						noCdr = cCtx.Bool(flagNameNoCdr)
+					// 必要なファイルのダウンロード
+					// プロジェクト設定の読み込みに devcontainer CLI が必要なため、ロックファイルのみでバージョンを固定する
+					configureTools(resolveSettings(cCtx, userSettings, workspaceFolder, nil))
+					devcontainerPath, cdrPath, err := tools.InstallStartTools(tools.DefaultInstallerUseServices{}, binDir)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Error installing start tools: %v\n", err)
+						os.Exit(1)
 					}
 
-					// port-forwarder 使用判定
-					noPf := false
-					if cCtx.String(flagNameNoCdr) != "" {
-						noPf = cCtx.Bool(flagNameNoPf)
+					// エディター、シェル、 tmux, cdr, port-forwarder 使用判定
+					projectSettings, vimrcSnippets := readProjectSettings(devcontainerPath, args)
+					resolvedSettings := resolveSettings(cCtx, userSettings, workspaceFolder, &projectSettings)
+					nvim := resolvedSettings.Nvim()
+					shell := resolvedSettings.Shell
+					noCdr := !resolvedSettings.Clipboard
+					noPf := !resolvedSettings.PortForwarding
+					noTmux := !resolvedSettings.Tmux
+
+					configFilePath, err := devcontainer.CreateConfigFile(devcontainerPath, workspaceFolder, configurationFile, configDirForDevcontainer, userAdditionalConfig)
+					if err != nil {
+						if errors.Is(err, os.ErrNotExist) {
+							fmt.Fprintf(os.Stderr, "Configuration file not found: %v\n", err)
+						} else {
+							fmt.Fprintf(os.Stderr, "Error creating config file: %v\n", err)
+						}
+						os.Exit(1)
 					}
 
-					// tmux 使用判定
-					noTmux := false
-					if cCtx.String(flagNameNoTmux) != "" {
-						noTmux = cCtx.Bool(flagNameNoTmux)
+					// devcontainer を用いたコンテナ立ち上げ
+					result, err := devcontainer.Start(devcontainer.DefaultDevcontainerStartUseService{}, args, devcontainerPath, noCdr, noPf, noTmux, cdrPath, binDir, nvim, shell, configFilePath, vimrc, vimrcSnippets)
+					if err != nil {
+						if errors.Is(err, os.ErrPermission) {
+							fmt.Fprintf(os.Stderr, "Permission error: %v\n", err)
+						} else {
+							fmt.Fprintf(os.Stderr, "Error executing devcontainer: %v\n", err)
+						}
+						os.Exit(1)
 					}
+					writeJSONResult(result)
 
-					// 必要なファイルのダウンロード
-					nvim := false
-					if cCtx.Bool(flagNameNeoVim) || os.Getenv(envDevcontainerVimType) == "nvim" {
-						nvim = true
+					return nil
+				},
+			},
+			{
+				Name:            "rebuild",
+				Usage:           "Recreate devcontainer and start vim.",
+				UsageText:       "devcontainer.vim rebuild [--no-cache] [--config PATH|--name NAME] [DEVCONTAINER_OPTIONS...] WORKSPACE_FOLDER",
+				HideHelp:        true,
+				SkipFlagParsing: true,
+				Action: func(cCtx *cli.Context) error {
+					// コマンドライン引数の末尾は `--workspace-folder` の値として使う
+					noCache, args := devcontainer.ParseRebuildArgs(cCtx.Args().Slice())
+					if len(args) == 0 {
+						fmt.Fprintf(os.Stderr, "Error: missing workspace folder.\n")
+						fmt.Fprintf(os.Stderr, "Usage: devcontainer.vim rebuild [--no-cache] [--config PATH|--name NAME] <WORKSPACE_FOLDER>\n")
+						os.Exit(1)
 					}
+					args = selectDevcontainerConfig(args)
+					workspaceFolder := args[len(args)-1]
+
+					// 必要なファイルのダウンロード
+					// プロジェクト設定の読み込みに devcontainer CLI が必要なため、ロックファイルのみでバージョンを固定する
+					configureTools(resolveSettings(cCtx, userSettings, workspaceFolder, nil))
 					devcontainerPath, cdrPath, err := tools.InstallStartTools(tools.DefaultInstallerUseServices{}, binDir)
 					if err != nil {
 						fmt.Fprintf(os.Stderr, "Error installing start tools: %v\n", err)
 						os.Exit(1)
 					}
 
+					// エディター、シェル、 tmux, cdr, port-forwarder 使用判定
+					projectSettings, vimrcSnippets := readProjectSettings(devcontainerPath, args)
+					resolvedSettings := resolveSettings(cCtx, userSettings, workspaceFolder, &projectSettings)
+					nvim := resolvedSettings.Nvim()
+					shell := resolvedSettings.Shell
+					noCdr := !resolvedSettings.Clipboard
+					noPf := !resolvedSettings.PortForwarding
+					noTmux := !resolvedSettings.Tmux
+
+					// コンテナを作り直して Vim を起動
+					result, err := devcontainer.Rebuild(devcontainer.DefaultDevcontainerStartUseService{}, args, devcontainerPath, noCache, noCdr, noPf, noTmux, cdrPath, binDir, nvim, shell, configDirForDevcontainer, userAdditionalConfig, vimrc, vimrcSnippets)
+					if err != nil {
+						if errors.Is(err, os.ErrPermission) {
+							fmt.Fprintf(os.Stderr, "Permission error: %v\n", err)
+						} else {
+							fmt.Fprintf(os.Stderr, "Error rebuilding devcontainer: %v\n", err)
+						}
+						os.Exit(1)
+					}
+					writeJSONResult(result)
+
+					return nil
+				},
+			},
+			{
+				Name:            "attach",
+				Usage:           "Attach to running devcontainer started by `start`",
+				UsageText:       "devcontainer.vim attach [--config PATH|--name NAME] WORKSPACE_FOLDER",
+				HideHelp:        true,
+				SkipFlagParsing: true,
+				Action: func(cCtx *cli.Context) error {
 					// コマンドライン引数の末尾は `--workspace-folder` の値として使う
 					args := cCtx.Args().Slice()
 					if len(args) == 0 {
 						fmt.Fprintf(os.Stderr, "Error: missing workspace folder.\n")
-						fmt.Fprintf(os.Stderr, "Usage: devcontainer.vim start <WORKSPACE_FOLDER>\n")
+						fmt.Fprintf(os.Stderr, "Usage: devcontainer.vim attach [--config PATH|--name NAME] <WORKSPACE_FOLDER>\n")
 						os.Exit(1)
 					}
+					args = selectDevcontainerConfig(args)
 					workspaceFolder := args[len(args)-1]
-					configFilePath, err := devcontainer.CreateConfigFile(devcontainerPath, workspaceFolder, configDirForDevcontainer)
+
+					// 必要なファイルのダウンロード
+					configureTools(resolveSettings(cCtx, userSettings, workspaceFolder, nil))
+					devcontainerPath, cdrPath, err := tools.InstallStartTools(tools.DefaultInstallerUseServices{}, binDir)
 					if err != nil {
-						if errors.Is(err, os.ErrNotExist) {
-							fmt.Fprintf(os.Stderr, "Configuration file not found: %v\n", err)
+						fmt.Fprintf(os.Stderr, "Error installing attach tools: %v\n", err)
+						os.Exit(1)
+					}
+
+					// シェル、 port-forwarder 使用判定
+					projectSettings, _ := readProjectSettings(devcontainerPath, args)
+					resolvedSettings := resolveSettings(cCtx, userSettings, workspaceFolder, &projectSettings)
+					shell := resolvedSettings.Shell
+					noPf := !resolvedSettings.PortForwarding
+
+					// 起動済みのコンテナへ接続
+					err = devcontainer.Attach(devcontainer.DefaultDevcontainerStartUseService{}, args, devcontainerPath, cdrPath, noPf, shell, configDirForDevcontainer)
+					if err != nil {
+						if errors.Is(err, os.ErrPermission) {
+							fmt.Fprintf(os.Stderr, "Permission error: %v\n", err)
 						} else {
-							fmt.Fprintf(os.Stderr, "Error creating config file: %v\n", err)
+							fmt.Fprintf(os.Stderr, "Error attaching devcontainer: %v\n", err)
 						}
 						os.Exit(1)
 					}
 
-					// devcontainer を用いたコンテナ立ち上げ
-					err = devcontainer.Start(devcontainer.DefaultDevcontainerStartUseService{}, args, devcontainerPath, noCdr, noPf, noTmux, cdrPath, binDir, nvim, shell, configFilePath, vimrc)
+					return nil
+				},
+			},
+			{
+				Name:            "exec",
+				Usage:           "Run command in running devcontainer.",
+				UsageText:       "devcontainer.vim exec [--tty|--no-tty] [--config PATH|--name NAME] WORKSPACE_FOLDER -- COMMAND [ARGS...]",
+				HideHelp:        true,
+				SkipFlagParsing: true,
+				Action: func(cCtx *cli.Context) error {
+					// 必要なファイルのダウンロード
+					devcontainerPath, err := tools.InstallStopTools(binDir)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Error installing exec tools: %v\n", err)
+						os.Exit(1)
+					}
+
+					// コンテナ上でコマンドを実行し、終了コードをそのまま返却する
+					exitCode, err := devcontainer.Exec(cCtx.Args().Slice(), devcontainerPath, configDirForDevcontainer)
 					if err != nil {
 						if errors.Is(err, os.ErrPermission) {
 							fmt.Fprintf(os.Stderr, "Permission error: %v\n", err)
 						} else {
-							fmt.Fprintf(os.Stderr, "Error executing devcontainer: %v\n", err)
+							fmt.Fprintf(os.Stderr, "Error executing command: %v\n", err)
+							fmt.Fprintf(os.Stderr, "Usage: devcontainer.vim exec [--tty|--no-tty] [--config PATH|--name NAME] <WORKSPACE_FOLDER> -- <COMMAND> [ARGS...]\n")
 						}
-						os.Exit(1)
 					}
+					os.Exit(exitCode)
 
 					return nil
 				},
@@ -461,7 +718,7 @@ func main() {
 			{
 				Name:            "stop",
 				Usage:           "Stop devcontainers.",
-				UsageText:       "devcontainer.vim stop WORKSPACE_FOLDER",
+				UsageText:       "devcontainer.vim stop [--config PATH|--name NAME] WORKSPACE_FOLDER",
 				HideHelp:        true,
 				SkipFlagParsing: true,
 				Action: func(cCtx *cli.Context) error {
@@ -479,7 +736,13 @@ func main() {
 					}
 
 					// devcontainer を用いたコンテナ終了
-					err = devcontainer.Stop(cCtx.Args().Slice(), devcontainerPath, configDirForDevcontainer)
+					args := cCtx.Args().Slice()
+					if len(args) == 0 {
+						fmt.Fprintf(os.Stderr, "Error: missing workspace folder.\n")
+						fmt.Fprintf(os.Stderr, "Usage: devcontainer.vim stop [--config PATH|--name NAME] <WORKSPACE_FOLDER>\n")
+						os.Exit(1)
+					}
+					result, err := devcontainer.Stop(selectDevcontainerConfig(args), devcontainerPath, configDirForDevcontainer)
 					if err != nil {
 						if errors.Is(err, os.ErrPermission) {
 							fmt.Fprintf(os.Stderr, "Permission error: %v\n", err)
@@ -489,7 +752,8 @@ func main() {
 						os.Exit(1)
 					}
 
-					fmt.Printf("Stop containers\n")
+					fmt.Fprintf(output.Progress(), "Stop containers\n")
+					writeJSONResult(result)
 
 					return nil
 				},
@@ -497,7 +761,7 @@ func main() {
 			{
 				Name:            "down",
 				Usage:           "Stop and remove devcontainers.",
-				UsageText:       "devcontainer.vim down WORKSPACE_FOLDER",
+				UsageText:       "devcontainer.vim down [--config PATH|--name NAME] WORKSPACE_FOLDER",
 				HideHelp:        true,
 				SkipFlagParsing: true,
 				Action: func(cCtx *cli.Context) error {
@@ -515,7 +779,14 @@ func main() {
 					}
 
 					// devcontainer を用いたコンテナ終了
-					err = devcontainer.Down(cCtx.Args().Slice(), devcontainerPath, configDirForDevcontainer)
+					args := cCtx.Args().Slice()
+					if len(args) == 0 {
+						fmt.Fprintf(os.Stderr, "Error: missing workspace folder.\n")
+						fmt.Fprintf(os.Stderr, "Usage: devcontainer.vim down [--config PATH|--name NAME] <WORKSPACE_FOLDER>\n")
+						os.Exit(1)
+					}
+					args = selectDevcontainerConfig(args)
+					result, err := devcontainer.Down(args, devcontainerPath, configDirForDevcontainer)
 					if err != nil {
 						if errors.Is(err, os.ErrPermission) {
 							fmt.Fprintf(os.Stderr, "Permission error: %v\n", err)
@@ -527,9 +798,9 @@ func main() {
 
 					// 設定ファイルを削除
 					// コマンドライン引数の末尾は `--workspace-folder` の値として使う
-					args := cCtx.Args().Slice()
 					workspaceFolder := args[len(args)-1]
-					configDir, err := util.GetConfigDir(appCacheDir, workspaceFolder)
+					configurationFile, _, _ := devcontainer.ParseConfigArgs(args)
+					configDir, err := util.GetConfigDirForConfiguration(configDirForDevcontainer, workspaceFolder, devcontainer.ConfigurationName(workspaceFolder, configurationFile))
 					if err != nil {
 						if errors.Is(err, os.ErrPermission) {
 							fmt.Fprintf(os.Stderr, "Permission error: %v\n", err)
@@ -539,8 +810,8 @@ func main() {
 						os.Exit(1)
 					}
 
-					fmt.Printf("Remove configuration file: `%s`\n", configDir)
-					err = os.RemoveAll(configDir)
+					fmt.Fprintf(output.Progress(), "Remove configuration file: `%s`\n", configDir)
+					err = runner.RemoveAll(configDir)
 					if err != nil {
 						if errors.Is(err, os.ErrPermission) {
 							fmt.Fprintf(os.Stderr, "Permission error: %v\n", err)
@@ -549,6 +820,37 @@ func main() {
 						}
 						os.Exit(1)
 					}
+					writeJSONResult(result)
+
+					return nil
+				},
+			},
+			{
+				Name:      "list",
+				Aliases:   []string{"ps"},
+				Usage:     "List workspaces and containers managed by devcontainer.vim.",
+				UsageText: "devcontainer.vim list",
+				Action: func(cCtx *cli.Context) error {
+					statuses, err := devcontainer.List(configDirForDevcontainer)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Error listing workspaces: %v\n", err)
+						os.Exit(1)
+					}
+
+					if output.IsJSON() {
+						err := output.WriteJSON(statuses)
+						if err != nil {
+							fmt.Fprintf(os.Stderr, "Error marshaling workspaces: %v\n", err)
+							os.Exit(1)
+						}
+						return nil
+					}
+
+					err = devcontainer.WriteWorkspaceStatusTable(os.Stdout, statuses)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Error writing workspaces: %v\n", err)
+						os.Exit(1)
+					}
 
 					return nil
 				},
@@ -556,7 +858,7 @@ func main() {
 			{
 				Name:            "config",
 				Usage:           "devcontainer.vim's config information.",
-				UsageText:       "devcontainer.vim config [OPTIONS...]",
+				UsageText:       "devcontainer.vim config [--user] [OPTIONS...]",
 				HideHelp:        false,
 				SkipFlagParsing: false,
 				Flags: []cli.Flag{
@@ -569,8 +871,8 @@ func main() {
 					&cli.StringFlag{
 						Name:    flagNameHome,
 						Aliases: []string{},
-						Value:   "/home/vscode",
-						Usage:   "generate sample config's home directory.",
+						Value:   "",
+						Usage:   "generate sample config's home directory. if not specified, resolved from remoteUser on start.",
 					},
 					&cli.StringFlag{
 						Name:    flagNameOutput,
@@ -578,44 +880,261 @@ func main() {
 						Value:   ".devcontainer/devcontainer.vim.json",
 						Usage:   "generate sample config output file path.",
 					},
+					&cli.BoolFlag{
+						Name:  flagNameUser,
+						Value: false,
+						Usage: "target user-global config file, merged under every project's devcontainer.vim.json.",
+					},
+					&cli.BoolFlag{
+						Name:  flagNameOpen,
+						Value: false,
+						Usage: "open and display user-global config file. use with --user.",
+					},
 				},
-				Action: func(cCtx *cli.Context) error {
-					// 何かしらオプションでない引数を渡されたらヘルプを出力して終了
-					if cCtx.NumFlags() == 0 || cCtx.Args().Present() {
-						cli.ShowSubcommandHelpAndExit(cCtx, 0)
-					}
-
-					// generate フラグがセットされていたら設定ファイルのひな形を出力する
-					if cCtx.Bool(flagNameGenerate) {
+				Subcommands: []*cli.Command{
+					{
+						Name:            "show",
+						Usage:           "Show effective configuration passed to devcontainer CLI.",
+						UsageText:       "devcontainer.vim config show [--config PATH|--name NAME] [--diff] WORKSPACE_FOLDER",
+						HideHelp:        false,
+						SkipFlagParsing: false,
+						Flags: append(configSelectionFlags(),
+							&cli.BoolFlag{
+								Name:  flagNameDiff,
+								Value: false,
+								Usage: "show only values changed by devcontainer.vim's additional config files instead of the annotated merged configuration.",
+							},
+						),
+						Action: func(cCtx *cli.Context) error {
+							// ワークスペースフォルダの指定が無ければヘルプを出力して終了
+							if cCtx.Args().Len() != 1 {
+								cli.ShowSubcommandHelpAndExit(cCtx, 1)
+							}
+							workspaceFolder := cCtx.Args().First()
 
-						// home オプションで指定された値を利用して、バインド先を置換
-						devcontainerVimJSON := strings.Replace(devcontainerVimJSONTemplate, "{{ remoteEnv:HOME }}", cCtx.String(flagNameHome), -1)
+							configurationFile := selectedConfigurationFile(cCtx, workspaceFolder)
 
-						if cCtx.IsSet(flagNameOutput) {
-							// output オプションが指定されている場合、指定されたパスへ出力する
-							configFilePath := cCtx.String(flagNameOutput)
+							// ホームディレクトリの解決に使用する devcontainer CLI は、ダウンロード済みの場合のみ使用する
+							devcontainerPath := filepath.Join(binDir, tools.DEVCONTAINER(tools.DefaultInstallerUseServices{}).FileName)
+							if !util.IsExists(devcontainerPath) {
+								devcontainerPath = ""
+							}
 
-							// 生成先ディレクトリを作成
-							err := os.MkdirAll(filepath.Dir(configFilePath), 0766)
+							layers, mergedConfig, err := devcontainer.MergeConfig(devcontainerPath, workspaceFolder, configurationFile, userAdditionalConfig)
 							if err != nil {
-								fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
+								fmt.Fprintf(os.Stderr, "Error merging config: %v\n", err)
 								os.Exit(1)
 							}
 
-							// 設定ファイルサンプルを出力
-							err = os.WriteFile(configFilePath, []byte(devcontainerVimJSON), 0666)
+							if cCtx.Bool(flagNameDiff) {
+								if output.IsJSON() {
+									writeJSONResult(ConfigDiffResult{Layers: layers, Changes: mergedConfig.Changes})
+									return nil
+								}
+								err = mergedConfig.WriteDiff(os.Stdout)
+							} else {
+								if output.IsJSON() {
+									writeJSONResult(ConfigShowResult{Layers: layers, Config: mergedConfig.Value, Provenance: mergedConfig.Provenance()})
+									return nil
+								}
+								for _, layer := range layers {
+									fmt.Fprintf(output.Progress(), "// %s: %s\n", layer.Name, layer.Path)
+								}
+								err = mergedConfig.WriteAnnotated(os.Stdout)
+							}
 							if err != nil {
-								fmt.Fprintf(os.Stderr, "Error writing config file: %v\n", err)
+								fmt.Fprintf(os.Stderr, "Error writing config: %v\n", err)
 								os.Exit(1)
 							}
-						} else {
-							// output オプションが指定されていない場合、標準出力へ出力する
-							fmt.Print(devcontainerVimJSON)
-						}
-					}
 
-					return nil
-				},
+							return nil
+						},
+					},
+					{
+						Name:            "validate",
+						Usage:           "Validate devcontainer.json and devcontainer.vim's additional config files.",
+						UsageText:       "devcontainer.vim config validate [--config PATH|--name NAME] [--strict] WORKSPACE_FOLDER",
+						HideHelp:        false,
+						SkipFlagParsing: false,
+						Flags: append(configSelectionFlags(),
+							&cli.BoolFlag{
+								Name:  flagNameStrict,
+								Value: false,
+								Usage: "treat warnings (e.g. unknown keys) as errors and exit non-zero.",
+							},
+						),
+						Action: func(cCtx *cli.Context) error {
+							// ワークスペースフォルダの指定が無ければヘルプを出力して終了
+							if cCtx.Args().Len() != 1 {
+								cli.ShowSubcommandHelpAndExit(cCtx, 1)
+							}
+							workspaceFolder := cCtx.Args().First()
+
+							configurationFile := selectedConfigurationFile(cCtx, workspaceFolder)
+
+							layers, problems, err := devcontainer.ValidateConfig(workspaceFolder, configurationFile, userAdditionalConfig)
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error validating config: %v\n", err)
+								os.Exit(1)
+							}
+
+							if output.IsJSON() {
+								writeJSONResult(ConfigValidateResult{Layers: layers, Problems: problems})
+							} else {
+								for _, problem := range problems {
+									fmt.Fprintln(output.Progress(), problem)
+								}
+								if len(problems) == 0 {
+									fmt.Fprintf(output.Progress(), "No problems found in %d file(s).\n", len(layers))
+								} else {
+									errorCount := util.CountErrors(problems)
+									fmt.Fprintf(os.Stderr, "%d error(s), %d warning(s) found.\n", errorCount, len(problems)-errorCount)
+								}
+							}
+
+							// CI で利用できるよう、エラーがあれば非 0 で終了する
+							// 警告(未知のキーなど)のみの場合は、 --strict が指定された場合のみ非 0 で終了する
+							failureCount := util.CountErrors(problems)
+							if cCtx.Bool(flagNameStrict) {
+								failureCount = len(problems)
+							}
+							if failureCount > 0 {
+								os.Exit(1)
+							}
+
+							return nil
+						},
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					// 何かしらオプションでない引数を渡されたらヘルプを出力して終了
+					if cCtx.NumFlags() == 0 || cCtx.Args().Present() {
+						cli.ShowSubcommandHelpAndExit(cCtx, 0)
+					}
+
+					// user フラグがセットされていたらユーザー共通の追加設定ファイルを対象にする
+					user := cCtx.Bool(flagNameUser)
+					if user && cCtx.IsSet(flagNameOutput) {
+						fmt.Fprintf(os.Stderr, "Error: --user and --output cannot be used together.\n")
+						os.Exit(1)
+					}
+					if cCtx.Bool(flagNameOpen) && !user {
+						fmt.Fprintf(os.Stderr, "Error: --open must be used with --user.\n")
+						os.Exit(1)
+					}
+
+					// generate フラグがセットされていたら設定ファイルのひな形を出力する
+					if cCtx.Bool(flagNameGenerate) {
+
+						// home オプションが指定された場合、その値を利用してバインド先を置換する。
+						// 指定されない場合、プレースホルダーのまま出力し、 start 時に remoteUser から解決する
+						devcontainerVimJSON := devcontainerVimJSONTemplate
+						if cCtx.String(flagNameHome) != "" {
+							devcontainerVimJSON = strings.Replace(devcontainerVimJSONTemplate, devcontainer.RemoteHomePlaceholder, cCtx.String(flagNameHome), -1)
+						}
+
+						if cCtx.IsSet(flagNameOutput) || user {
+							// output オプションが指定されている場合、指定されたパスへ出力する
+							// user オプションが指定されている場合、ユーザー共通の追加設定ファイルへ出力する
+							configFilePath := cCtx.String(flagNameOutput)
+							if user {
+								configFilePath = userAdditionalConfig
+							}
+
+							// 生成先ディレクトリを作成
+							err := os.MkdirAll(filepath.Dir(configFilePath), 0766)
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
+								os.Exit(1)
+							}
+
+							// 設定ファイルサンプルを出力
+							err = os.WriteFile(configFilePath, []byte(devcontainerVimJSON), 0666)
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error writing config file: %v\n", err)
+								os.Exit(1)
+							}
+							if user {
+								fmt.Fprintf(output.Progress(), "Generated user config to: %s\n", configFilePath)
+							}
+							writeJSONResult(ConfigGenerateResult{OutputFile: configFilePath})
+						} else if output.IsJSON() {
+							writeJSONResult(ConfigGenerateResult{Content: devcontainerVimJSON})
+						} else {
+							// output オプションが指定されていない場合、標準出力へ出力する
+							fmt.Fprint(output.Progress(), devcontainerVimJSON)
+						}
+					} else if user {
+						// ユーザー共通の追加設定ファイルの場所を表示する
+						if !util.IsExists(userAdditionalConfig) {
+							fmt.Fprintf(os.Stderr, "User config file not found: %s\n", userAdditionalConfig)
+							fmt.Fprintf(os.Stderr, "Run `devcontainer.vim config --user --generate` to create it.\n")
+							os.Exit(1)
+						}
+
+						if cCtx.Bool(flagNameOpen) {
+							err := util.OpenFileWithDefaultApp(userAdditionalConfig)
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error opening user config file: %v\n", err)
+								os.Exit(1)
+							}
+						}
+						if output.IsJSON() {
+							writeJSONResult(ConfigGenerateResult{OutputFile: userAdditionalConfig})
+						} else {
+							fmt.Fprintf(output.Progress(), "%s\n", userAdditionalConfig)
+						}
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:      "settings",
+				Usage:     "devcontainer.vim's settings information.",
+				UsageText: "devcontainer.vim settings show [--config PATH|--name NAME] [WORKSPACE_FOLDER]",
+				Subcommands: []*cli.Command{
+					{
+						Name:            "show",
+						Usage:           "Show resolved settings and where each value came from.",
+						UsageText:       "devcontainer.vim settings show [--config PATH|--name NAME] [WORKSPACE_FOLDER]",
+						HideHelp:        true,
+						SkipFlagParsing: true,
+						Action: func(cCtx *cli.Context) error {
+							// ワークスペースフォルダが指定された場合のみ、プロジェクト設定を読み込む
+							// ロックファイルは、ワークスペースフォルダが指定されない場合はカレントディレクトリのものを使用する
+							var projectSettings *settings.Settings
+							workspaceFolder := "."
+							args := cCtx.Args().Slice()
+							if len(args) > 0 {
+								args = selectDevcontainerConfig(args)
+								workspaceFolder = args[len(args)-1]
+								devcontainerPath, err := tools.InstallStopTools(binDir)
+								if err != nil {
+									fmt.Fprintf(os.Stderr, "Error installing devcontainer: %v\n", err)
+									os.Exit(1)
+								}
+								readSettings, _ := readProjectSettings(devcontainerPath, args)
+								projectSettings = &readSettings
+							}
+
+							entries := resolveSettings(cCtx, userSettings, workspaceFolder, projectSettings).Entries()
+							if output.IsJSON() {
+								writeJSONResult(SettingsShowResult{SettingsFile: settingsFile, Settings: entries})
+								return nil
+							}
+
+							fmt.Fprintf(output.Progress(), "Settings file: %s\n", settingsFile)
+							err := settings.WriteTable(os.Stdout, entries)
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error writing settings: %v\n", err)
+								os.Exit(1)
+							}
+
+							return nil
+						},
+					},
+				},
 			},
 			{
 				Name:            "vimrc",
@@ -650,7 +1169,7 @@ func main() {
 							fmt.Fprintf(os.Stderr, "Error writing vimrc: %v\n", err)
 							os.Exit(1)
 						}
-						fmt.Printf("Generated additional vimrc to: %s\n", vimrc)
+						fmt.Fprintf(output.Progress(), "Generated additional vimrc to: %s\n", vimrc)
 					}
 
 					if cCtx.Bool(flagNameOpen) {
@@ -659,7 +1178,7 @@ func main() {
 							fmt.Fprintf(os.Stderr, "Error opening vimrc: %v\n", err)
 							os.Exit(1)
 						}
-						fmt.Printf("%s\n", vimrc)
+						fmt.Fprintf(output.Progress(), "%s\n", vimrc)
 					}
 
 					return nil
@@ -685,6 +1204,164 @@ func main() {
 						Usage:   "open and display runargs.",
 					},
 				},
+				Subcommands: []*cli.Command{
+					{
+						Name:            "list",
+						Usage:           "List runargs profiles.",
+						UsageText:       "devcontainer.vim runargs list",
+						HideHelp:        false,
+						SkipFlagParsing: false,
+						Action: func(cCtx *cli.Context) error {
+							profiles, err := runargs.Load(runargsFile)
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error reading runargs: %v\n", err)
+								os.Exit(1)
+							}
+
+							entries := profiles.Entries()
+							if output.IsJSON() {
+								writeJSONResult(RunargsListResult{RunargsFile: runargsFile, Profiles: entries})
+								return nil
+							}
+
+							fmt.Fprintf(output.Progress(), "Runargs file: %s\n", runargsFile)
+							err = runargs.WriteTable(os.Stdout, entries)
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error writing runargs: %v\n", err)
+								os.Exit(1)
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:            "show",
+						Usage:           "Show arguments passed to `docker run` by runargs profiles.",
+						UsageText:       "devcontainer.vim runargs show [--image IMAGE] [PROFILE_NAME]",
+						HideHelp:        false,
+						SkipFlagParsing: false,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  flagNameImage,
+								Value: "",
+								Usage: "select profiles as `run` does for the image.",
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							if cCtx.Args().Len() > 1 {
+								cli.ShowSubcommandHelpAndExit(cCtx, 1)
+							}
+
+							profiles, err := runargs.Load(runargsFile)
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error reading runargs: %v\n", err)
+								os.Exit(1)
+							}
+							selectedProfiles, err := profiles.Select(cCtx.Args().First(), cCtx.String(flagNameImage))
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error selecting runargs profile: %v\n", err)
+								os.Exit(1)
+							}
+
+							allowCommandSubstitution := resolveSettings(cCtx, userSettings, "", nil).RunargsCommandSubstitution
+							args, err := profiles.Expand(selectedProfiles, allowCommandSubstitution)
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error extracting runargs: %v\n", err)
+								os.Exit(1)
+							}
+
+							if output.IsJSON() {
+								writeJSONResult(RunargsShowResult{Profiles: selectedProfiles, Args: args})
+								return nil
+							}
+
+							fmt.Fprintf(output.Progress(), "Profiles: %s\n", strings.Join(selectedProfiles, ", "))
+							for _, arg := range args {
+								fmt.Fprintln(output.Progress(), arg)
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:            "edit",
+						Usage:           "Open runargs file. If the profile does not exist, add it before opening.",
+						UsageText:       "devcontainer.vim runargs edit [PROFILE_NAME]",
+						HideHelp:        false,
+						SkipFlagParsing: false,
+						Action: func(cCtx *cli.Context) error {
+							if cCtx.Args().Len() > 1 {
+								cli.ShowSubcommandHelpAndExit(cCtx, 1)
+							}
+
+							// 存在しないプロファイルが指定されたら、ひな形を追加してから開く
+							profileName := cCtx.Args().First()
+							if profileName != "" {
+								profiles, err := runargs.Load(runargsFile)
+								if err != nil {
+									fmt.Fprintf(os.Stderr, "Error reading runargs: %v\n", err)
+									os.Exit(1)
+								}
+								if _, ok := profiles.Profiles[profileName]; !ok {
+									err = runargs.SetProfile(runargsFile, profileName, runargs.ProfileTemplate(profileName))
+									if err != nil {
+										fmt.Fprintf(os.Stderr, "Error writing runargs: %v\n", err)
+										os.Exit(1)
+									}
+									fmt.Fprintf(os.Stderr, "Added runargs profile %s to: %s\n", profileName, runargsFile)
+								}
+							}
+
+							err := util.OpenFileWithDefaultApp(runargsFile)
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error opening runargs: %v\n", err)
+								os.Exit(1)
+							}
+							if output.IsJSON() {
+								writeJSONResult(RunargsGenerateResult{RunargsFile: runargsFile, Profile: profileName})
+							} else {
+								fmt.Fprintf(output.Progress(), "%s\n", runargsFile)
+							}
+
+							return nil
+						},
+					},
+					{
+						Name:            "generate",
+						Usage:           "Regenerate runargs file, or only the specified profile.",
+						UsageText:       "devcontainer.vim runargs generate [PROFILE_NAME]",
+						HideHelp:        false,
+						SkipFlagParsing: false,
+						Action: func(cCtx *cli.Context) error {
+							if cCtx.Args().Len() > 1 {
+								cli.ShowSubcommandHelpAndExit(cCtx, 1)
+							}
+
+							// プロファイルが指定されなければ、ファイル全体を再生成する
+							profileName := cCtx.Args().First()
+							var err error
+							if profileName == "" {
+								err = os.WriteFile(runargsFile, []byte(runargs.Template(runargs.DefaultArgs)), 0666)
+							} else {
+								err = runargs.SetProfile(runargsFile, profileName, runargs.ProfileTemplate(profileName))
+							}
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error writing runargs: %v\n", err)
+								os.Exit(1)
+							}
+
+							if output.IsJSON() {
+								writeJSONResult(RunargsGenerateResult{RunargsFile: runargsFile, Profile: profileName})
+							} else if profileName == "" {
+								fmt.Fprintf(output.Progress(), "Generated additional runargs to: %s\n", runargsFile)
+							} else {
+								fmt.Fprintf(output.Progress(), "Generated runargs profile %s to: %s\n", profileName, runargsFile)
+							}
+
+							return nil
+						},
+					},
+				},
 				Action: func(cCtx *cli.Context) error {
 					// 何かしらオプションでない引数を渡されたらヘルプを出力して終了
 					if cCtx.NumFlags() == 0 || cCtx.Args().Present() {
@@ -693,21 +1370,21 @@ func main() {
 
 					// generate フラグがセットされていたら runargs の再生成を行う
 					if cCtx.Bool(flagNameGenerate) {
-						err := os.WriteFile(runargs, []byte(runargsContent), 0666)
+						err := os.WriteFile(runargsFile, []byte(runargs.Template(runargs.DefaultArgs)), 0666)
 						if err != nil {
 							fmt.Fprintf(os.Stderr, "Error writing runargs: %v\n", err)
 							os.Exit(1)
 						}
-						fmt.Printf("Generated additional runargs to: %s\n", runargs)
+						fmt.Fprintf(output.Progress(), "Generated additional runargs to: %s\n", runargsFile)
 					}
 
 					if cCtx.Bool(flagNameOpen) {
-						err := util.OpenFileWithDefaultApp(runargs)
+						err := util.OpenFileWithDefaultApp(runargsFile)
 						if err != nil {
 							fmt.Fprintf(os.Stderr, "Error opening runargs: %v\n", err)
 							os.Exit(1)
 						}
-						fmt.Printf("%s\n", runargs)
+						fmt.Fprintf(output.Progress(), "%s\n", runargsFile)
 					}
 
 					return nil
@@ -719,7 +1396,142 @@ func main() {
 				UsageText:       "devcontainer.vim tool SUB_COMMAND",
 				HideHelp:        false,
 				SkipFlagParsing: false,
+				Before: func(cCtx *cli.Context) error {
+					// カレントディレクトリのロックファイルも含めてツールのバージョンを固定する
+					configureTools(resolveSettings(cCtx, userSettings, ".", nil))
+					return nil
+				},
 				Subcommands: []*cli.Command{
+					{
+						Name:      "list",
+						Usage:     "List downloaded tools",
+						UsageText: "devcontainer.vim tool list",
+						Action: func(cCtx *cli.Context) error {
+							installed, err := tools.ListInstalled(binDir)
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error listing tools: %v\n", err)
+								os.Exit(1)
+							}
+
+							if output.IsJSON() {
+								err := output.WriteJSON(ToolListResult{BinDir: binDir, Tools: installed})
+								if err != nil {
+									fmt.Fprintf(os.Stderr, "Error marshaling tools: %v\n", err)
+									os.Exit(1)
+								}
+								return nil
+							}
+
+							err = tools.WriteInstalledTable(os.Stdout, installed)
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error writing tools: %v\n", err)
+								os.Exit(1)
+							}
+							return nil
+						},
+					},
+					{
+						Name:      "update",
+						Usage:     "Update downloaded tools to the pinned version or the latest release",
+						UsageText: "devcontainer.vim tool update --all | devcontainer.vim tool update NAME...",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  flagNameAll,
+								Value: false,
+								Usage: "update all downloaded tools.",
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							names := cCtx.Args().Slice()
+							if cCtx.Bool(flagNameAll) == (len(names) != 0) {
+								fmt.Fprintln(os.Stderr, "Error: specify either --all or tool names.")
+								os.Exit(1)
+							}
+
+							updated, err := tools.Update(tools.DefaultInstallerUseServices{RefreshReleases: true}, binDir, names)
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error updating tools: %v\n", err)
+								os.Exit(1)
+							}
+							if output.IsJSON() {
+								writeJSONResult(ToolUpdateResult{Tools: updated})
+								return nil
+							}
+							err = tools.WriteUpdatedTable(os.Stdout, updated)
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error writing tools: %v\n", err)
+								os.Exit(1)
+							}
+							return nil
+						},
+					},
+					{
+						Name:      "remove",
+						Usage:     "Remove downloaded tool",
+						UsageText: "devcontainer.vim tool remove [--arch ARCH] NAME",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  flagNameArch,
+								Value: "",
+								Usage: "remove only this cpu archtecture. if not specified, all archtectures.",
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							if cCtx.NArg() != 1 {
+								fmt.Fprintln(os.Stderr, "Error: specify a tool name.")
+								os.Exit(1)
+							}
+							arch, err := util.NormalizeContainerArch(cCtx.String(flagNameArch))
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+								os.Exit(1)
+							}
+
+							removed, err := tools.Remove(binDir, cCtx.Args().First(), arch)
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error removing tool: %v\n", err)
+								os.Exit(1)
+							}
+							for _, tool := range removed {
+								fmt.Fprintf(output.Progress(), "Removed %s.\n", tool.Path)
+							}
+							writeJSONResult(ToolRemoveResult{Removed: removed})
+							return nil
+						},
+					},
+					{
+						Name:      "gc",
+						Usage:     "Remove tools for cpu archtectures not used recently",
+						UsageText: "devcontainer.vim tool gc [--days N]",
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:  flagNameDays,
+								Value: 30,
+								Usage: "remove tools for archtectures not used in this number of days.",
+							},
+						},
+						Action: func(cCtx *cli.Context) error {
+							days := cCtx.Int(flagNameDays)
+							if days < 0 {
+								fmt.Fprintln(os.Stderr, "Error: --days must not be negative.")
+								os.Exit(1)
+							}
+
+							removed, err := tools.GC(binDir, time.Duration(days)*24*time.Hour, time.Now())
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error removing tools: %v\n", err)
+								os.Exit(1)
+							}
+							for _, tool := range removed {
+								fmt.Fprintf(output.Progress(), "Removed %s (last used at %s).\n", tool.Path, tool.LastUsedAt.Local().Format(time.DateTime))
+							}
+							if len(removed) == 0 {
+								fmt.Fprintln(output.Progress(), "Nothing to remove.")
+							}
+							writeJSONResult(ToolRemoveResult{Removed: removed})
+							return nil
+						},
+					},
 					{
 						Name:            "vim",
 						Usage:           "Management vim",
@@ -730,7 +1542,7 @@ func main() {
 							{
 								Name:            "download",
 								Usage:           "Download newly vim",
-								UsageText:       "devcontainer.vim tool vim download",
+								UsageText:       "devcontainer.vim tool vim download [--version TAG]",
 								HideHelp:        false,
 								SkipFlagParsing: false,
 								Flags: []cli.Flag{
@@ -739,19 +1551,26 @@ func main() {
 										Value: runtime.GOARCH,
 										Usage: "download cpu archtecture.",
 									},
+									&cli.StringFlag{
+										Name:  flagNameVersion,
+										Value: "",
+										Usage: "release tag to download. if not specified, the pinned version or the latest release.",
+									},
 								},
 								Action: func(cCtx *cli.Context) error {
 
 									// Vim のダウンロード
-									_, err := tools.VIM(tools.DefaultInstallerUseServices{}).Install(binDir, cCtx.String(flagNameArch), true)
+									toolPath, err := tools.VIM(tools.DefaultInstallerUseServices{RefreshReleases: true}).InstallVersion(binDir, cCtx.String(flagNameArch), cCtx.String(flagNameVersion))
 									if err != nil {
 										fmt.Fprintf(os.Stderr, "Error installing vim: %v\n", err)
 										os.Exit(1)
 									}
+									writeJSONResult(ToolDownloadResult{Tool: "vim", Arch: cCtx.String(flagNameArch), Path: toolPath})
 
 									return nil
 								},
 							},
+							newToolInstallCommand("vim", binDir, tools.VIM(tools.DefaultInstallerUseServices{}), true),
 						},
 					},
 					{
@@ -764,7 +1583,7 @@ func main() {
 							{
 								Name:            "download",
 								Usage:           "Download newly nvim",
-								UsageText:       "devcontainer.vim tool nvim download",
+								UsageText:       "devcontainer.vim tool nvim download [--version TAG]",
 								HideHelp:        false,
 								SkipFlagParsing: false,
 								Flags: []cli.Flag{
@@ -773,19 +1592,26 @@ func main() {
 										Value: runtime.GOARCH,
 										Usage: "download cpu archtecture.",
 									},
+									&cli.StringFlag{
+										Name:  flagNameVersion,
+										Value: "",
+										Usage: "release tag to download. if not specified, the pinned version or the latest release.",
+									},
 								},
 								Action: func(cCtx *cli.Context) error {
 
 									// NeoVim のダウンロード
-									_, err := tools.NVIM(tools.DefaultInstallerUseServices{}).Install(binDir, cCtx.String(flagNameArch), true)
+									toolPath, err := tools.NVIM(tools.DefaultInstallerUseServices{RefreshReleases: true}).InstallVersion(binDir, cCtx.String(flagNameArch), cCtx.String(flagNameVersion))
 									if err != nil {
 										fmt.Fprintf(os.Stderr, "Error installing nvim: %v\n", err)
 										os.Exit(1)
 									}
+									writeJSONResult(ToolDownloadResult{Tool: "nvim", Arch: cCtx.String(flagNameArch), Path: toolPath})
 
 									return nil
 								},
 							},
+							newToolInstallCommand("nvim", binDir, tools.NVIM(tools.DefaultInstallerUseServices{}), true),
 						},
 					},
 					{
@@ -798,7 +1624,7 @@ func main() {
 							{
 								Name:            "download",
 								Usage:           "Download newly tmux",
-								UsageText:       "devcontainer.vim tool tmux download",
+								UsageText:       "devcontainer.vim tool tmux download [--version TAG]",
 								HideHelp:        false,
 								SkipFlagParsing: false,
 								Flags: []cli.Flag{
@@ -807,19 +1633,26 @@ func main() {
 										Value: runtime.GOARCH,
 										Usage: "download cpu archtecture.",
 									},
+									&cli.StringFlag{
+										Name:  flagNameVersion,
+										Value: "",
+										Usage: "release tag to download. if not specified, the pinned version or the latest release.",
+									},
 								},
 								Action: func(cCtx *cli.Context) error {
 
 									// tmux のダウンロード
-									_, err := tools.Tmux(tools.DefaultInstallerUseServices{}).Install(binDir, cCtx.String(flagNameArch), true)
+									toolPath, err := tools.Tmux(tools.DefaultInstallerUseServices{RefreshReleases: true}).InstallVersion(binDir, cCtx.String(flagNameArch), cCtx.String(flagNameVersion))
 									if err != nil {
 										fmt.Fprintf(os.Stderr, "Error installing tmux: %v\n", err)
 										os.Exit(1)
 									}
+									writeJSONResult(ToolDownloadResult{Tool: "tmux", Arch: cCtx.String(flagNameArch), Path: toolPath})
 
 									return nil
 								},
 							},
+							newToolInstallCommand("tmux", binDir, tools.Tmux(tools.DefaultInstallerUseServices{}), true),
 						},
 					},
 					{
@@ -832,21 +1665,30 @@ func main() {
 							{
 								Name:            "download",
 								Usage:           "Download newly devcontainer cli",
-								UsageText:       "devcontainer.vim tool devcontainer download",
+								UsageText:       "devcontainer.vim tool devcontainer download [--version TAG]",
 								HideHelp:        false,
 								SkipFlagParsing: false,
+								Flags: []cli.Flag{
+									&cli.StringFlag{
+										Name:  flagNameVersion,
+										Value: "",
+										Usage: "release tag to download. if not specified, the pinned version or the latest release.",
+									},
+								},
 								Action: func(cCtx *cli.Context) error {
 
 									// devcontainer のダウンロード
-									_, err := tools.DEVCONTAINER(tools.DefaultInstallerUseServices{}).Install(binDir, "", true)
+									toolPath, err := tools.DEVCONTAINER(tools.DefaultInstallerUseServices{RefreshReleases: true}).InstallVersion(binDir, "", cCtx.String(flagNameVersion))
 									if err != nil {
 										fmt.Fprintf(os.Stderr, "Error installing devcontainer: %v\n", err)
 										os.Exit(1)
 									}
+									writeJSONResult(ToolDownloadResult{Tool: "devcontainer", Path: toolPath})
 
 									return nil
 								},
 							},
+							newToolInstallCommand("devcontainer", binDir, tools.DEVCONTAINER(tools.DefaultInstallerUseServices{}), false),
 						},
 					},
 					{
@@ -859,21 +1701,30 @@ func main() {
 							{
 								Name:            "download",
 								Usage:           "Download newly clipboard-data-receiver cli",
-								UsageText:       "devcontainer.vim tool clipboard-data-receiver download",
+								UsageText:       "devcontainer.vim tool clipboard-data-receiver download [--version TAG]",
 								HideHelp:        false,
 								SkipFlagParsing: false,
+								Flags: []cli.Flag{
+									&cli.StringFlag{
+										Name:  flagNameVersion,
+										Value: "",
+										Usage: "release tag to download. if not specified, the pinned version or the latest release.",
+									},
+								},
 								Action: func(cCtx *cli.Context) error {
 
 									// clipboard-data-receiver のダウンロード
-									_, err := tools.CDR(tools.DefaultInstallerUseServices{}).Install(binDir, "", true)
+									toolPath, err := tools.CDR(tools.DefaultInstallerUseServices{RefreshReleases: true}).InstallVersion(binDir, "", cCtx.String(flagNameVersion))
 									if err != nil {
 										fmt.Fprintf(os.Stderr, "Error installing clipboard-data-receiver: %v\n", err)
 										os.Exit(1)
 									}
+									writeJSONResult(ToolDownloadResult{Tool: "clipboard-data-receiver", Path: toolPath})
 
 									return nil
 								},
 							},
+							newToolInstallCommand("clipboard-data-receiver", binDir, tools.CDR(tools.DefaultInstallerUseServices{}), false),
 						},
 					},
 					{
@@ -886,7 +1737,7 @@ func main() {
 							{
 								Name:            "download",
 								Usage:           "Download newly port-forwarder cli",
-								UsageText:       "devcontainer.vim tool port-forwarder download",
+								UsageText:       "devcontainer.vim tool port-forwarder download [--version TAG]",
 								HideHelp:        false,
 								SkipFlagParsing: false,
 								Flags: []cli.Flag{
@@ -895,19 +1746,26 @@ func main() {
 										Value: runtime.GOARCH,
 										Usage: "download cpu archtecture.",
 									},
+									&cli.StringFlag{
+										Name:  flagNameVersion,
+										Value: "",
+										Usage: "release tag to download. if not specified, the pinned version or the latest release.",
+									},
 								},
 								Action: func(cCtx *cli.Context) error {
 
 									// clipboard-data-receiver のダウンロード
-									_, err := tools.PortForwarderContainer(tools.DefaultInstallerUseServices{}).Install(binDir, cCtx.String(flagNameArch), true)
+									toolPath, err := tools.PortForwarderContainer(tools.DefaultInstallerUseServices{RefreshReleases: true}).InstallVersion(binDir, cCtx.String(flagNameArch), cCtx.String(flagNameVersion))
 									if err != nil {
 										fmt.Fprintf(os.Stderr, "Error installing port-forwarder: %v\n", err)
 										os.Exit(1)
 									}
+									writeJSONResult(ToolDownloadResult{Tool: "port-forwarder", Arch: cCtx.String(flagNameArch), Path: toolPath})
 
 									return nil
 								},
 							},
+							newToolInstallCommand("port-forwarder", binDir, tools.PortForwarderContainer(tools.DefaultInstallerUseServices{}), true),
 						},
 					},
 				},
@@ -920,7 +1778,7 @@ func main() {
 
 					// 実行確認
 					var input string
-					fmt.Printf("全ワークスペースのキャッシュを削除しますか？ [y/n] > ")
+					fmt.Fprintf(output.Progress(), "全ワークスペースのキャッシュを削除しますか？ [y/n] > ")
 					fmt.Scan(&input)
 					input = strings.TrimSpace(input)
 					input = strings.ToLower(input)
@@ -929,7 +1787,7 @@ func main() {
 					}
 
 					// 削除処理
-					err := os.RemoveAll(configDirForDocker)
+					err := runner.RemoveAll(configDirForDocker)
 					if err != nil {
 						if errors.Is(err, os.ErrPermission) {
 							fmt.Fprintf(os.Stderr, "Permission error: %v\n", err)
@@ -938,7 +1796,7 @@ func main() {
 						}
 						os.Exit(1)
 					}
-					err = os.RemoveAll(configDirForDevcontainer)
+					err = runner.RemoveAll(configDirForDevcontainer)
 					if err != nil {
 						if errors.Is(err, os.ErrPermission) {
 							fmt.Fprintf(os.Stderr, "Permission error: %v\n", err)
@@ -965,7 +1823,11 @@ func main() {
 						Action: func(cCtx *cli.Context) error {
 
 							// Features の一覧をダウンロード
-							err := oras.Pull("ghcr.io/devcontainers/index", "latest", appCacheDir)
+							if tools.IsOffline() {
+								fmt.Fprintln(os.Stderr, "Error: offline mode is enabled, cannot update template index. Disable offline mode to update.")
+								os.Exit(1)
+							}
+							err := oras.Pull(templateIndexRepository(), "latest", appCacheDir)
 							if err != nil {
 								if errors.Is(err, os.ErrNotExist) {
 									fmt.Fprintf(os.Stderr, "Index file not found: %v\n", err)
@@ -974,18 +1836,55 @@ func main() {
 								}
 								os.Exit(1)
 							}
+							writeJSONResult(IndexUpdateResult{IndexFile: filepath.Join(appCacheDir, "devcontainer-index.json")})
 
 							return nil
 						},
 					},
 				},
 			},
+			{
+				Name:      "doctor",
+				Usage:     "Diagnose host environment.",
+				UsageText: "devcontainer.vim doctor [OPTIONS...]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  flagNameNoContainer,
+						Value: false,
+						Usage: "skip checks that run a test container.",
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					report := doctor.Run(doctor.DefaultDoctorUseServices{}, doctor.Dirs{
+						AppConfigDir:             appConfigDir,
+						AppCacheDir:              appCacheDir,
+						BinDir:                   binDir,
+						ConfigDirForDocker:       configDirForDocker,
+						ConfigDirForDevcontainer: configDirForDevcontainer,
+					}, !cCtx.Bool(flagNameNoContainer))
+
+					if output.IsJSON() {
+						err := output.WriteJSON(report)
+						if err != nil {
+							fmt.Fprintf(os.Stderr, "Error marshaling report: %v\n", err)
+							os.Exit(1)
+						}
+					} else {
+						doctor.WriteReport(os.Stdout, report)
+					}
+
+					if !report.OK {
+						os.Exit(1)
+					}
+					return nil
+				},
+			},
 			{
 				Name:      "self-update",
 				Usage:     "Update devcontainer.vim itself",
 				UsageText: "devcontainer.vim self-update",
 				Action: func(cCtx *cli.Context) error {
-					err := tools.SelfUpdate(tools.DefaultInstallerUseServices{})
+					err := tools.SelfUpdate(tools.DefaultInstallerUseServices{RefreshReleases: true})
 					if err != nil {
 						if errors.Is(err, os.ErrPermission) {
 							fmt.Fprintf(os.Stderr, "Permission error: %v\n", err)
@@ -1002,7 +1901,7 @@ func main() {
 				Usage:     "Show bash complete func",
 				UsageText: "devcontainer.vim bash-complete-func",
 				Action: func(cCtx *cli.Context) error {
-					fmt.Print(bash_complete_func)
+					fmt.Fprint(output.Progress(), bash_complete_func)
 					return nil
 				},
 			},
@@ -1020,3 +1919,293 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// `--config`, `--name` で指定された devcontainer.json を解決し、
+// `--config <絶対パス>` を挿入した引数を返却する。
+// devcontainer.json が複数あり指定が無い場合、端末からの実行であれば選択肢を表示する。
+func selectDevcontainerConfig(args []string) []string {
+	resolvedArgs, err := devcontainer.ResolveConfigArgs(args)
+	if err == nil {
+		return resolvedArgs
+	}
+
+	var ambiguousConfigurationError *devcontainer.AmbiguousConfigurationError
+	if !errors.As(err, &ambiguousConfigurationError) || output.IsJSON() || !util.IsTerminal(os.Stdin) {
+		fmt.Fprintf(os.Stderr, "Error selecting configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	workspaceFolder := args[len(args)-1]
+	prompt := promptui.Select{
+		Label: "Select Configuration",
+		Items: devcontainer.ConfigurationDisplayNames(workspaceFolder, ambiguousConfigurationError.Candidates),
+	}
+	i, _, err := prompt.Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error running prompt: %v\n", err)
+		os.Exit(1)
+	}
+
+	selectedArgs := append([]string{}, args[:len(args)-1]...)
+	selectedArgs = append(selectedArgs, "--config", ambiguousConfigurationError.Candidates[i], workspaceFolder)
+	resolvedArgs, err = devcontainer.ResolveConfigArgs(selectedArgs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error selecting configuration: %v\n", err)
+		os.Exit(1)
+	}
+	return resolvedArgs
+}
+
+// 使用する devcontainer.json を選択する `--config`, `--name` フラグを返却する
+func configSelectionFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  flagNameConfig,
+			Value: "",
+			Usage: "path to devcontainer.json to use.",
+		},
+		&cli.StringFlag{
+			Name:  flagNameName,
+			Value: "",
+			Usage: "name of configuration to use (`.devcontainer/NAME/devcontainer.json`).",
+		},
+	}
+}
+
+// `--config`, `--name` フラグから、 start などと同じ規則で devcontainer.json を選択して返却する。
+// devcontainer.json が見つからない場合は空文字を返却する。
+func selectedConfigurationFile(cCtx *cli.Context, workspaceFolder string) string {
+	args := []string{}
+	if cCtx.String(flagNameConfig) != "" {
+		args = append(args, "--"+flagNameConfig+"="+cCtx.String(flagNameConfig))
+	}
+	if cCtx.String(flagNameName) != "" {
+		args = append(args, "--"+flagNameName+"="+cCtx.String(flagNameName))
+	}
+	configurationFile, _, _ := devcontainer.ParseConfigArgs(selectDevcontainerConfig(append(args, workspaceFolder)))
+	return configurationFile
+}
+
+// devcontainer.json の `customizations.devcontainer-vim` を、プロジェクト設定として読み込む。
+// 追記する vimrc の Vim script も返却する。
+func readProjectSettings(devcontainerPath string, args []string) (settings.Settings, []string) {
+	workspaceFolder := args[len(args)-1]
+	configurationFile, _, _ := devcontainer.ParseConfigArgs(args)
+	customizations, err := devcontainer.ReadCustomizations(devcontainerPath, workspaceFolder, configurationFile)
+	if err != nil {
+		var invalidCustomizationsError *devcontainer.InvalidCustomizationsError
+		if errors.As(err, &invalidCustomizationsError) {
+			fmt.Fprintf(os.Stderr, "Error reading customizations: %v\n", err)
+			os.Exit(1)
+		}
+		// devcontainer.json を読み込めない場合は、後続の `devcontainer up` でエラーを報告させる
+		return settings.Settings{}, nil
+	}
+	return customizations.Settings(), customizations.Vimrc
+}
+
+// 設定を 既定値 < ユーザー設定ファイル < ロックファイル < プロジェクト設定 < 環境変数 < コマンドラインオプション の優先順で解決する。
+// workspaceFolder が空文字の場合はロックファイルを、 projectSettings が nil の場合はプロジェクト設定を使用しない。
+func resolveSettings(cCtx *cli.Context, userSettings settings.Settings, workspaceFolder string, projectSettings *settings.Settings) settings.Resolved {
+	envSettings, err := settings.FromEnv(getenvIgnoringUnknownEditor(os.Getenv))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading settings: %v\n", err)
+		os.Exit(1)
+	}
+
+	layers := []settings.Layer{{Source: settings.SourceUser, Settings: userSettings}}
+	if workspaceFolder != "" {
+		lockSettings, err := settings.LoadLockFile(settings.LockFilePath(workspaceFolder))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading lock file: %v\n", err)
+			os.Exit(1)
+		}
+		layers = append(layers, settings.Layer{Source: settings.SourceLockfile, Settings: lockSettings})
+	}
+	if projectSettings != nil {
+		layers = append(layers, settings.Layer{Source: settings.SourceProject, Settings: *projectSettings})
+	}
+	layers = append(layers,
+		settings.Layer{Source: settings.SourceEnv, Settings: envSettings},
+		settings.Layer{Source: settings.SourceFlag, Settings: flagSettings(cCtx)})
+	return settings.Resolve(layers...)
+}
+
+// 警告済みの、環境変数 DEVCONTAINER_VIM_TYPE の不明な値
+var warnedUnknownEditor = false
+
+// getenv のうち、環境変数 DEVCONTAINER_VIM_TYPE の不明な値を無視するものを返却する。
+//
+// エディターを起動しないサブコマンドも失敗しないよう、エラーにせず一度だけ警告して既定値へフォールバックする。
+func getenvIgnoringUnknownEditor(getenv func(string) string) func(string) string {
+	return func(name string) string {
+		value := getenv(name)
+		if name != settings.EnvEditor || value == "" || slices.Contains(settings.Editors, value) {
+			return value
+		}
+		if !warnedUnknownEditor {
+			fmt.Fprintf(os.Stderr, "Warning: unknown editor %q in environment variable %s, ignored. Available editors: %s.\n", value, settings.EnvEditor, strings.Join(settings.Editors, ", "))
+			warnedUnknownEditor = true
+		}
+		return ""
+	}
+}
+
+// 警告済みの、 toolVersions, toolDigests, mirrors 内の不明なツール名
+var warnedUnknownTools = map[string]bool{}
+
+// 解決した設定で、ツールのインストール方法を設定する。
+//
+// インストールするツールのバージョンとダイジェストを固定し、ダウンロード元のミラーとオフラインモードを設定する。
+func configureTools(resolvedSettings settings.Resolved) {
+	pins := map[string]tools.PinnedVersion{}
+	for name, version := range resolvedSettings.ToolVersions {
+		if !isKnownTool(name, settings.KeyToolVersions+"."+name) {
+			continue
+		}
+		pins[name] = tools.PinnedVersion{Version: version, Source: resolvedSettings.Source(settings.KeyToolVersions + "." + name)}
+	}
+	tools.SetPinnedVersions(pins)
+
+	digests := map[string]tools.PinnedDigest{}
+	for key, digest := range resolvedSettings.ToolDigests {
+		if !isKnownTool(tools.ToolNameOfDigestKey(key), settings.KeyToolDigests+"."+key) {
+			continue
+		}
+		digests[key] = tools.PinnedDigest{Digest: strings.ToLower(digest), Source: resolvedSettings.Source(settings.KeyToolDigests + "." + key)}
+	}
+	tools.SetPinnedDigests(digests)
+
+	mirrors := map[string]string{}
+	for name, baseURL := range resolvedSettings.Mirrors {
+		if name != tools.MirrorNameIndex && !isKnownTool(name, settings.KeyMirrors+"."+name) {
+			continue
+		}
+		mirrors[name] = baseURL
+	}
+	tools.SetMirrors(mirrors)
+	tools.SetOffline(resolvedSettings.Offline)
+}
+
+// name が既知のツール名かを返却する。不明な場合は settingKey を示して一度だけ警告する。
+func isKnownTool(name string, settingKey string) bool {
+	if slices.Contains(tools.ToolNames, name) {
+		return true
+	}
+	if !warnedUnknownTools[settingKey] {
+		fmt.Fprintf(os.Stderr, "Warning: unknown tool %q in %s, ignored. Available tools: %s.\n", name, settingKey, strings.Join(tools.ToolNames, ", "))
+		warnedUnknownTools[settingKey] = true
+	}
+	return false
+}
+
+// コマンドラインオプションで明示的に指定された設定を返却する
+func flagSettings(cCtx *cli.Context) settings.Settings {
+	var result settings.Settings
+	result.Engine = cCtx.String(flagNameEngine)
+	if cCtx.IsSet(flagNameNeoVim) {
+		editor := "vim"
+		if cCtx.Bool(flagNameNeoVim) {
+			editor = "nvim"
+		}
+		result.Editor = &editor
+	}
+	if cCtx.IsSet(flagNameNoTmux) {
+		tmux := !cCtx.Bool(flagNameNoTmux)
+		result.Tmux = &tmux
+	}
+	if cCtx.IsSet(flagNameNoCdr) {
+		clipboard := !cCtx.Bool(flagNameNoCdr)
+		result.Clipboard = &clipboard
+	}
+	if cCtx.IsSet(flagNameNoPf) {
+		portForwarding := !cCtx.Bool(flagNameNoPf)
+		result.PortForwarding = &portForwarding
+	}
+	if cCtx.String(flagNameShell) != "" {
+		shell := cCtx.String(flagNameShell)
+		result.Shell = &shell
+	}
+	if cCtx.IsSet(flagNameVerbose) {
+		verbose := cCtx.Bool(flagNameVerbose)
+		result.Verbose = &verbose
+	}
+	if cCtx.IsSet(flagNameOffline) {
+		offline := cCtx.Bool(flagNameOffline)
+		result.Offline = &offline
+	}
+	return result
+}
+
+// テンプレートインデックスを取得する OCI リポジトリを返却する。
+// ミラー(`mirrors.index`)が設定されている場合はそれを使用する。
+func templateIndexRepository() string {
+	mirror, ok := tools.MirrorOf(tools.MirrorNameIndex)
+	if ok {
+		return mirror
+	}
+	return defaultTemplateIndexRepository
+}
+
+// `tool <name> install` サブコマンドを返却する。
+// withArch が true の場合は、コンテナのアーキテクチャごとにインストールするツールとして `--arch` を受け付ける。
+func newToolInstallCommand(toolName string, binDir string, tool tools.Tool, withArch bool) *cli.Command {
+	flags := []cli.Flag{
+		&cli.StringFlag{
+			Name:     flagNameFromFile,
+			Value:    "",
+			Usage:    "file to install, the same one as the release asset.",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  flagNameVersion,
+			Value: "",
+			Usage: "release tag of the file. if not specified, the pinned version.",
+		},
+	}
+	usageText := fmt.Sprintf("devcontainer.vim tool %s install --from-file PATH [--version TAG]", toolName)
+	if withArch {
+		flags = append(flags, &cli.StringFlag{
+			Name:  flagNameArch,
+			Value: runtime.GOARCH,
+			Usage: "install cpu archtecture.",
+		})
+		usageText = fmt.Sprintf("devcontainer.vim tool %s install --from-file PATH [--version TAG] [--arch ARCH]", toolName)
+	}
+
+	return &cli.Command{
+		Name:            "install",
+		Usage:           fmt.Sprintf("Install %s from a local file", toolName),
+		UsageText:       usageText,
+		HideHelp:        false,
+		SkipFlagParsing: false,
+		Flags:           flags,
+		Action: func(cCtx *cli.Context) error {
+			arch := ""
+			if withArch {
+				arch = cCtx.String(flagNameArch)
+			}
+
+			toolPath, err := tool.InstallFromFile(binDir, arch, cCtx.String(flagNameVersion), cCtx.String(flagNameFromFile))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error installing %s: %v\n", toolName, err)
+				os.Exit(1)
+			}
+			writeJSONResult(ToolDownloadResult{Tool: toolName, Arch: arch, Path: toolPath})
+
+			return nil
+		},
+	}
+}
+
+// JSON 形式で出力する場合、実行結果を標準出力へ出力する
+func writeJSONResult(result any) {
+	if !output.IsJSON() {
+		return
+	}
+	err := output.WriteJSON(result)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing result: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/mikoto2000/devcontainer.vim/v3/devcontainer"
+	"github.com/mikoto2000/devcontainer.vim/v3/settings"
 	"github.com/mikoto2000/devcontainer.vim/v3/tools"
 	"github.com/mikoto2000/devcontainer.vim/v3/util"
 )
@@ -25,7 +26,7 @@ func TestCreateConfigFile(t *testing.T) {
 	}
 
 	workspaceFolder := "./test/project/TestCreateConfigFile"
-	configFilePath, err := devcontainer.CreateConfigFile(devcontainerPath, workspaceFolder, configDirForDevcontainer)
+	configFilePath, err := devcontainer.CreateConfigFile(devcontainerPath, workspaceFolder, "", configDirForDevcontainer, "")
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			t.Skipf("configuration file not found: %v", err)
@@ -40,3 +41,26 @@ func TestCreateConfigFile(t *testing.T) {
 		t.Fatalf("error: want %s, but got %s", want, configFilePath)
 	}
 }
+
+func TestGetenvIgnoringUnknownEditor(t *testing.T) {
+	env := map[string]string{settings.EnvEditor: "emacs", settings.EnvShell: "zsh"}
+	getenv := getenvIgnoringUnknownEditor(func(name string) string { return env[name] })
+
+	// 不明なエディターは無視し、既定値へフォールバックする
+	resolved, err := settings.FromEnv(getenv)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if resolved.Editor != nil {
+		t.Fatalf("error: want unknown editor to be ignored, but got %q", *resolved.Editor)
+	}
+	if resolved.Shell == nil || *resolved.Shell != "zsh" {
+		t.Fatalf("error: other environment variables must be kept, got %v", resolved.Shell)
+	}
+
+	env[settings.EnvEditor] = "nvim"
+	resolved, err = settings.FromEnv(getenv)
+	if err != nil || resolved.Editor == nil || *resolved.Editor != "nvim" {
+		t.Fatalf("error: want nvim, but got %v, %v", resolved.Editor, err)
+	}
+}
@@ -2,19 +2,31 @@ package oras
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"oras.land/oras-go/v2"
 	"oras.land/oras-go/v2/content/file"
 	"oras.land/oras-go/v2/registry/remote"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
 )
 
+// id のリポジトリの tagName のアーティファクトを destDir へ取得する。
+// id が `http://` で始まる場合は、 TLS を使用せずにレジストリへ接続する(ミラー用)。
 func Pull(id string, tagName string, destDir string) error {
+	if runner.Skip(fmt.Sprintf("pull %s:%s to %s", id, tagName, destDir)) {
+		return nil
+	}
 
 	// リモートリポジトリの設定
+	plainHTTP := strings.HasPrefix(id, "http://")
+	id = strings.TrimPrefix(strings.TrimPrefix(id, "http://"), "https://")
 	src, err := remote.NewRepository(id)
 	if err != nil {
 		return err
 	}
+	src.PlainHTTP = plainHTTP
 
 	// メモリストアの作成
 	dst, err := file.New(destDir)
@@ -0,0 +1,69 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// 実行結果の出力形式を扱うためのパッケージ。
+//
+// JSON 形式の場合、進捗表示は Progress() が返却する標準エラー出力へ出力し、
+// 標準出力には実行結果の JSON のみを出力する。
+
+const FormatText = "text"
+const FormatJSON = "json"
+
+type InvalidFormatError struct {
+	msg string
+}
+
+func (e *InvalidFormatError) Error() string {
+	return e.msg
+}
+
+var format = FormatText
+
+// 実行結果の出力先
+var resultOutput io.Writer = os.Stdout
+
+// 進捗表示など、実行結果以外のメッセージの出力先
+var progressOutput io.Writer = os.Stdout
+
+// 出力形式を設定する。
+// JSON 形式の場合、進捗表示の出力先を標準エラー出力にする。
+func SetFormat(f string) error {
+	switch f {
+	case "", FormatText:
+		format = FormatText
+		progressOutput = os.Stdout
+	case FormatJSON:
+		format = FormatJSON
+		progressOutput = os.Stderr
+	default:
+		return &InvalidFormatError{msg: fmt.Sprintf("unknown output format `%s`. use `%s` or `%s`.", f, FormatText, FormatJSON)}
+	}
+	return nil
+}
+
+// 進捗表示など、実行結果以外のメッセージの出力先を返却する。
+// テキスト形式の場合は標準出力、 JSON 形式の場合は標準エラー出力。
+func Progress() io.Writer {
+	return progressOutput
+}
+
+// JSON 形式で出力するかを返却する
+func IsJSON() bool {
+	return format == FormatJSON
+}
+
+// 実行結果を JSON で出力する
+func WriteJSON(result any) error {
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(resultOutput, string(resultJSON))
+	return err
+}
@@ -0,0 +1,81 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+// テスト終了時に出力形式と出力先を元へ戻す
+func restoreFormat(t *testing.T) {
+	t.Helper()
+
+	t.Cleanup(func() {
+		format = FormatText
+		resultOutput = os.Stdout
+		progressOutput = os.Stdout
+	})
+}
+
+func TestSetFormatText(t *testing.T) {
+	restoreFormat(t)
+
+	for _, f := range []string{"", FormatText} {
+		err := SetFormat(f)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		if IsJSON() {
+			t.Fatalf("format `%s` must not be json", f)
+		}
+	}
+}
+
+func TestSetFormatJSONWritesProgressToStderr(t *testing.T) {
+	restoreFormat(t)
+	originalStdout := os.Stdout
+
+	err := SetFormat(FormatJSON)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if !IsJSON() {
+		t.Fatalf("format must be json")
+	}
+	if Progress() != os.Stderr {
+		t.Fatalf("progress must be written to os.Stderr")
+	}
+	if os.Stdout != originalStdout || resultOutput != originalStdout {
+		t.Fatalf("os.Stdout and result output must be kept")
+	}
+}
+
+func TestSetFormatUnknown(t *testing.T) {
+	restoreFormat(t)
+
+	err := SetFormat("yaml")
+	var invalidFormatError *InvalidFormatError
+	if !errors.As(err, &invalidFormatError) {
+		t.Fatalf("want InvalidFormatError, got %v", err)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	restoreFormat(t)
+	buffer := &bytes.Buffer{}
+	resultOutput = buffer
+
+	err := WriteJSON(struct {
+		ContainerID string `json:"containerId"`
+		CdrPort     int    `json:"cdrPort"`
+	}{ContainerID: "abc", CdrPort: 5678})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	want := "{\n  \"containerId\": \"abc\",\n  \"cdrPort\": 5678\n}\n"
+	if buffer.String() != want {
+		t.Fatalf("want %q, got %q", want, buffer.String())
+	}
+}
@@ -0,0 +1,359 @@
+package runargs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/anmitsu/go-shlex"
+	"github.com/tailscale/hujson"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/util"
+)
+
+// run サブコマンドへ暗黙的に設定する引数(runargs)のプロファイルを扱うパッケージ。
+
+// runargs プロファイルファイル名
+const FileName = "runargs.json"
+
+// プロファイル導入前の runargs ファイル名
+const LegacyFileName = "runargs"
+
+// すべてのプロファイルの前に適用されるプロファイルの名前
+const DefaultProfileName = "default"
+
+const profileOption = "--profile"
+
+// default プロファイルの既定の引数
+// (カレントディレクトリを `/work` へマウントし、ワーキングディレクトリも同じ場所へ設定)
+const DefaultArgs = "-v \"$(pwd):/work\" -v \"$HOME/.vim:/root/.vim\" -v \"$HOME/.gitconfig:/root/.gitconfig\" -v \"$HOME/.ssh:/root/.ssh\" --workdir /work"
+
+const fileTemplate = `{
+  // run サブコマンドへ暗黙的に設定する引数のプロファイル
+  "profiles": {
+    // default プロファイルは、他のプロファイルの前に常に適用される
+    "default": {
+      "args": %s
+    }
+    // イメージ名にマッチするプロファイルは、自動で選択される
+    // "python": {
+    //   "images": ["python:*", "*/python:*"],
+    //   "args": "-v \"$HOME/.cache/pip:/root/.cache/pip\""
+    // },
+    // "inheritDefault": false で、 default プロファイルを適用しない
+    // "db-client": {
+    //   "images": ["postgres:*"],
+    //   "args": "--network host",
+    //   "inheritDefault": false
+    // }
+  }
+}
+`
+
+type ProfileNotFoundError struct {
+	msg string
+}
+
+func (e *ProfileNotFoundError) Error() string {
+	return e.msg
+}
+
+// イメージ名に複数のプロファイルがマッチし、どれを使うか決められない場合のエラー
+type AmbiguousProfileError struct {
+	msg string
+
+	// マッチしたプロファイルの名前
+	Candidates []string
+}
+
+func (e *AmbiguousProfileError) Error() string {
+	return e.msg
+}
+
+// runargs のプロファイル
+type Profile struct {
+	// 自動選択の対象とするイメージ名の glob パターン(`*`, `?` が使用可能)
+	Images []string `json:"images"`
+	// `docker run` へ渡す引数(シェル変数を展開してから分割する)
+	Args string `json:"args"`
+	// default プロファイルの後に適用するか(未指定の場合は true)
+	InheritDefault *bool `json:"inheritDefault,omitempty"`
+}
+
+// default プロファイルの後に適用するかを返却する
+func (p Profile) InheritsDefault() bool {
+	return p.InheritDefault == nil || *p.InheritDefault
+}
+
+// runargs プロファイルファイルのスキーマ
+type File struct {
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+// defaultArgs を default プロファイルの引数とした、プロファイルファイルのひな形を返却する
+func Template(defaultArgs string) string {
+	quoted, _ := json.Marshal(defaultArgs)
+	return fmt.Sprintf(fileTemplate, quoted)
+}
+
+// name のプロファイルのひな形を返却する
+func ProfileTemplate(name string) Profile {
+	if name == DefaultProfileName {
+		return Profile{Images: []string{}, Args: DefaultArgs}
+	}
+	return Profile{Images: []string{}, Args: ""}
+}
+
+// runargsFilePath のプロファイルファイルを読み込む。
+// ファイルが存在しない場合はプロファイルの無い File を返却する。
+func Load(runargsFilePath string) (File, error) {
+	result := File{Profiles: map[string]Profile{}}
+
+	if !util.IsExists(runargsFilePath) {
+		return result, nil
+	}
+
+	runargsJSON, err := util.ParseJwcc(runargsFilePath)
+	if err != nil {
+		return result, err
+	}
+
+	err = json.Unmarshal(runargsJSON, &result)
+	if err != nil {
+		return result, err
+	}
+	if result.Profiles == nil {
+		result.Profiles = map[string]Profile{}
+	}
+	return result, nil
+}
+
+// プロファイルファイルが存在しない場合に作成する。
+// プロファイル導入前の runargs ファイルが存在する場合、その内容を default プロファイルの引数とする。
+//
+// 作成した場合は true を返却する。
+func CreateIfNotExists(runargsFilePath string, legacyFilePath string) (bool, error) {
+	if util.IsExists(runargsFilePath) {
+		return false, nil
+	}
+
+	defaultArgs := DefaultArgs
+	if util.IsExists(legacyFilePath) {
+		legacyArgs, err := os.ReadFile(legacyFilePath)
+		if err != nil {
+			return false, err
+		}
+		defaultArgs = strings.TrimSpace(string(legacyArgs))
+	}
+
+	err := util.CreateFileWithContents(runargsFilePath, Template(defaultArgs), 0666)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// プロファイルファイルの name のプロファイルを profile で置き換える(存在しない場合は追加する)。
+// ファイル内のコメントは維持する。
+func SetProfile(runargsFilePath string, name string, profile Profile) error {
+	content, err := os.ReadFile(runargsFilePath)
+	if err != nil {
+		return err
+	}
+	value, err := hujson.Parse(content)
+	if err != nil {
+		return err
+	}
+
+	profileJSON, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal([]map[string]any{
+		{"op": "add", "path": "/profiles/" + escapeJSONPointer(name), "value": json.RawMessage(profileJSON)},
+	})
+	if err != nil {
+		return err
+	}
+	err = value.Patch(patch)
+	if err != nil {
+		return err
+	}
+	value.Format()
+
+	return os.WriteFile(runargsFilePath, value.Pack(), 0666)
+}
+
+func escapeJSONPointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// プロファイル名を、 default プロファイルを先頭に名前順で返却する
+func (f File) Names() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		if name != DefaultProfileName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := f.Profiles[DefaultProfileName]; ok {
+		names = append([]string{DefaultProfileName}, names...)
+	}
+	return names
+}
+
+// 適用するプロファイルの名前を、適用順に返却する。
+//
+// name が指定された場合はそのプロファイルを、指定されない場合は image にマッチするプロファイルを選択する。
+// 選択したプロファイルが default を継承する場合、 default プロファイルを先頭に追加する。
+// どのプロファイルも選択されない場合は、 default プロファイルのみを返却する。
+func (f File) Select(name string, image string) ([]string, error) {
+	if name == "" {
+		matched := []string{}
+		for _, candidate := range f.Names() {
+			if candidate == DefaultProfileName {
+				continue
+			}
+			for _, pattern := range f.Profiles[candidate].Images {
+				if MatchImage(pattern, image) {
+					matched = append(matched, candidate)
+					break
+				}
+			}
+		}
+		if len(matched) > 1 {
+			return nil, &AmbiguousProfileError{
+				msg:        fmt.Sprintf("image %q matches multiple runargs profiles: %s. Use `%s NAME` to select one.", image, strings.Join(matched, ", "), profileOption),
+				Candidates: matched,
+			}
+		}
+		if len(matched) == 1 {
+			name = matched[0]
+		}
+	} else if _, ok := f.Profiles[name]; !ok {
+		return nil, &ProfileNotFoundError{msg: fmt.Sprintf("runargs profile %q not found. Available profiles: %s.", name, strings.Join(f.Names(), ", "))}
+	}
+
+	selected := []string{}
+	_, hasDefault := f.Profiles[DefaultProfileName]
+	if hasDefault && (name == "" || (name != DefaultProfileName && f.Profiles[name].InheritsDefault())) {
+		selected = append(selected, DefaultProfileName)
+	}
+	if name != "" {
+		selected = append(selected, name)
+	}
+	return selected, nil
+}
+
+// names のプロファイルの引数を、シェル変数を展開したうえで分割して連結する
+func (f File) Expand(names []string, allowCommandSubstitution bool) ([]string, error) {
+	result := []string{}
+	for _, name := range names {
+		expanded, err := util.ExpandShellVariables(f.Profiles[name].Args, allowCommandSubstitution)
+		if err != nil {
+			return nil, fmt.Errorf("runargs profile %q: %w", name, err)
+		}
+		splitted, err := shlex.Split(expanded, true)
+		if err != nil {
+			return nil, fmt.Errorf("runargs profile %q: %w", name, err)
+		}
+		result = append(result, splitted...)
+	}
+	return result, nil
+}
+
+// image がイメージ名の glob パターンにマッチするかを返却する。
+// `*` は `/` や `:` を含む任意の文字列に、 `?` は任意の 1 文字にマッチする。
+func MatchImage(pattern string, image string) bool {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String()).MatchString(image)
+}
+
+// run サブコマンドの引数から `--profile` を取り除き、その値と残りの引数を返却する。
+// `--profile=NAME` の形式も受け付ける。
+func ParseProfileArgs(args []string) (string, []string) {
+	name := ""
+	rest := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == profileOption && i+1 < len(args)-1:
+			// 末尾はイメージのため、値として消費しない
+			name = args[i+1]
+			i++
+		case strings.HasPrefix(arg, profileOption+"="):
+			name = strings.TrimPrefix(arg, profileOption+"=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return name, rest
+}
+
+// プロファイル一覧の 1 項目
+type Entry struct {
+	Name           string   `json:"name"`
+	Images         []string `json:"images"`
+	Args           string   `json:"args"`
+	InheritDefault bool     `json:"inheritDefault"`
+}
+
+// プロファイルを、表示用に default プロファイルを先頭に名前順で返却する
+func (f File) Entries() []Entry {
+	entries := []Entry{}
+	for _, name := range f.Names() {
+		profile := f.Profiles[name]
+		images := profile.Images
+		if images == nil {
+			images = []string{}
+		}
+		entries = append(entries, Entry{
+			Name:           name,
+			Images:         images,
+			Args:           profile.Args,
+			InheritDefault: name != DefaultProfileName && profile.InheritsDefault(),
+		})
+	}
+	return entries
+}
+
+// プロファイルの一覧を表形式で w へ出力する。
+// default プロファイルを継承するプロファイルは、 NAME に `(+default)` を付けて表示する。
+func WriteTable(w io.Writer, entries []Entry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tIMAGES\tARGS")
+	for _, entry := range entries {
+		name := entry.Name
+		if entry.InheritDefault {
+			name += " (+" + DefaultProfileName + ")"
+		}
+		images := strings.Join(entry.Images, ",")
+		if images == "" {
+			images = "-"
+		}
+		args := entry.Args
+		if args == "" {
+			args = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", name, images, args)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,182 @@
+package runargs
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func boolPointer(value bool) *bool {
+	return &value
+}
+
+func testFile() File {
+	return File{Profiles: map[string]Profile{
+		DefaultProfileName: {Args: "--workdir /work"},
+		"python":           {Images: []string{"python:*", "*/python:*"}, Args: "-e PIP_NO_CACHE_DIR=1"},
+		"node":             {Images: []string{"node:2?"}, Args: "-p 3000:3000", InheritDefault: boolPointer(false)},
+		"slim":             {Images: []string{"*-slim"}, Args: "--memory 512m"},
+	}}
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		name  string
+		image string
+		want  []string
+	}{
+		{"", "python:3.12", []string{DefaultProfileName, "python"}},
+		{"", "ghcr.io/example/python:3", []string{DefaultProfileName, "python"}},
+		{"", "node:22", []string{"node"}},
+		{"", "node:8", []string{DefaultProfileName}},
+		{"", "ubuntu", []string{DefaultProfileName}},
+		{"slim", "python:3.12-slim", []string{DefaultProfileName, "slim"}},
+		{DefaultProfileName, "python:3.12", []string{DefaultProfileName}},
+	}
+	for _, test := range tests {
+		got, err := testFile().Select(test.name, test.image)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("error: %q, %q: want %v, but got %v", test.name, test.image, test.want, got)
+		}
+	}
+}
+
+func TestSelectErrors(t *testing.T) {
+	_, err := testFile().Select("", "python:3.12-slim")
+	var ambiguousProfileError *AmbiguousProfileError
+	if !errors.As(err, &ambiguousProfileError) {
+		t.Fatalf("error: want AmbiguousProfileError, but got %v", err)
+	}
+	if want := []string{"python", "slim"}; !reflect.DeepEqual(ambiguousProfileError.Candidates, want) {
+		t.Fatalf("error: want %v, but got %v", want, ambiguousProfileError.Candidates)
+	}
+
+	_, err = testFile().Select("ruby", "ruby:3")
+	var profileNotFoundError *ProfileNotFoundError
+	if !errors.As(err, &profileNotFoundError) {
+		t.Fatalf("error: want ProfileNotFoundError, but got %v", err)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	t.Setenv("RUNARGS_TEST_DIR", "/tmp/runargs test")
+	file := File{Profiles: map[string]Profile{
+		DefaultProfileName: {Args: `-v "$RUNARGS_TEST_DIR:/work" --workdir /work`},
+		"python":           {Args: `-e 'PS1=$ '`},
+	}}
+
+	got, err := file.Expand([]string{DefaultProfileName, "python"}, false)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := []string{"-v", "/tmp/runargs test:/work", "--workdir", "/work", "-e", "PS1=$ "}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("error: want %q, but got %q", want, got)
+	}
+}
+
+func TestParseProfileArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		wantName string
+		wantRest []string
+	}{
+		{[]string{"--profile", "python", "-it", "python:3"}, "python", []string{"-it", "python:3"}},
+		{[]string{"--profile=python", "python:3"}, "python", []string{"python:3"}},
+		{[]string{"-it", "python:3"}, "", []string{"-it", "python:3"}},
+		{[]string{"--profile", "python:3"}, "", []string{"--profile", "python:3"}},
+	}
+	for _, test := range tests {
+		name, rest := ParseProfileArgs(test.args)
+		if name != test.wantName || !reflect.DeepEqual(rest, test.wantRest) {
+			t.Errorf("error: %v: want %q %v, but got %q %v", test.args, test.wantName, test.wantRest, name, rest)
+		}
+	}
+}
+
+func TestCreateIfNotExistsMigratesLegacyFile(t *testing.T) {
+	dir := t.TempDir()
+	runargsFile := filepath.Join(dir, FileName)
+	legacyFile := filepath.Join(dir, LegacyFileName)
+	err := os.WriteFile(legacyFile, []byte("-v \"$HOME/.vim:/root/.vim\"\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	created, err := CreateIfNotExists(runargsFile, legacyFile)
+	if err != nil || !created {
+		t.Fatalf("error: want created, but got %v, %v", created, err)
+	}
+	file, err := Load(runargsFile)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if got := file.Profiles[DefaultProfileName].Args; got != `-v "$HOME/.vim:/root/.vim"` {
+		t.Fatalf("error: want legacy args, but got %q", got)
+	}
+
+	created, err = CreateIfNotExists(runargsFile, legacyFile)
+	if err != nil || created {
+		t.Fatalf("error: want not created, but got %v, %v", created, err)
+	}
+}
+
+func TestSetProfile(t *testing.T) {
+	runargsFile := filepath.Join(t.TempDir(), FileName)
+	err := os.WriteFile(runargsFile, []byte(Template(DefaultArgs)), 0644)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	err = SetProfile(runargsFile, "python", Profile{Images: []string{"python:*"}, Args: "-e A=1"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = SetProfile(runargsFile, DefaultProfileName, Profile{Args: "--workdir /src"})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	file, err := Load(runargsFile)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := map[string]Profile{
+		DefaultProfileName: {Args: "--workdir /src"},
+		"python":           {Images: []string{"python:*"}, Args: "-e A=1"},
+	}
+	if !reflect.DeepEqual(file.Profiles, want) {
+		t.Fatalf("error: want %+v, but got %+v", want, file.Profiles)
+	}
+
+	// ひな形のコメントが維持されていること
+	content, err := os.ReadFile(runargsFile)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if !bytes.Contains(content, []byte("// default プロファイルは、他のプロファイルの前に常に適用される")) {
+		t.Fatalf("error: comments are lost: %s", content)
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	err := WriteTable(buffer, testFile().Entries())
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := "NAME               IMAGES               ARGS\n" +
+		"default            -                    --workdir /work\n" +
+		"node               node:2?              -p 3000:3000\n" +
+		"python (+default)  python:*,*/python:*  -e PIP_NO_CACHE_DIR=1\n" +
+		"slim (+default)    *-slim               --memory 512m\n"
+	if buffer.String() != want {
+		t.Fatalf("error: want %q, but got %q", want, buffer.String())
+	}
+}
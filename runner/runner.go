@@ -0,0 +1,199 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// 外部コマンドの実行、キャッシュディレクトリへのファイル書き込み、ツールのダウンロードを
+// 一元的に扱うためのパッケージ。
+//
+// dry-run モードの場合、副作用のある操作は実行せずに記録し、内容を出力する。
+// コンテナの検索など、副作用の無い問い合わせ(ReadOnlyCommand)は dry-run モードでも実行する。
+//
+// verbose モードの場合、実行する外部コマンドを標準エラー出力へ出力する。
+
+var mu sync.Mutex
+var dryRun bool
+var records []string
+var output io.Writer = os.Stdout
+var verbose bool
+var traceOutput io.Writer = os.Stderr
+
+// dry-run モードを設定する
+func SetDryRun(enabled bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	dryRun = enabled
+	records = nil
+}
+
+// dry-run モードかを返却する
+func IsDryRun() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return dryRun
+}
+
+// dry-run モードで実行しなかった操作の一覧を返却する
+func Records() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]string{}, records...)
+}
+
+// verbose モードを設定する
+func SetVerbose(enabled bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	verbose = enabled
+}
+
+// verbose モードの出力先を設定する
+func SetTraceOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	traceOutput = w
+}
+
+// dry-run の記録内容の出力先を設定する
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	output = w
+}
+
+// dry-run モードの場合、 description を記録して true を返却する。
+// 呼び出し元は true の場合に操作をスキップする。
+func Skip(description string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if !dryRun {
+		return false
+	}
+	records = append(records, description)
+	fmt.Fprintf(output, "[dry-run] %s\n", description)
+	return true
+}
+
+// コマンドライン引数を、そのままシェルに貼り付けられる形で連結する
+func FormatArgs(name string, args ...string) string {
+	quoted := []string{quoteArg(name)}
+	for _, arg := range args {
+		quoted = append(quoted, quoteArg(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+func quoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\"'\\$`*?[]{}()<>|&;#~!") {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// dry-run モードに対応した exec.Cmd。
+// 副作用のあるコマンドは、 dry-run モードの場合は実行せずに argv を記録する。
+type Cmd struct {
+	*exec.Cmd
+	readOnly bool
+	skipped  bool
+}
+
+// 副作用のあるコマンドを作成する
+func Command(name string, args ...string) *Cmd {
+	return &Cmd{Cmd: exec.Command(name, args...)}
+}
+
+// 副作用のあるコマンドを、コンテキスト付きで作成する
+func CommandContext(ctx context.Context, name string, args ...string) *Cmd {
+	return &Cmd{Cmd: exec.CommandContext(ctx, name, args...)}
+}
+
+// 副作用の無い問い合わせ用のコマンドを作成する。 dry-run モードでも実行する。
+func ReadOnlyCommand(name string, args ...string) *Cmd {
+	return &Cmd{Cmd: exec.Command(name, args...), readOnly: true}
+}
+
+func (c *Cmd) skip() bool {
+	if !c.readOnly {
+		c.skipped = Skip(FormatArgs(c.Args[0], c.Args[1:]...))
+	}
+	if !c.skipped {
+		c.trace()
+	}
+	return c.skipped
+}
+
+// verbose モードの場合、実行するコマンドを出力する
+func (c *Cmd) trace() {
+	mu.Lock()
+	defer mu.Unlock()
+	if verbose {
+		fmt.Fprintf(traceOutput, "[exec] %s\n", FormatArgs(c.Args[0], c.Args[1:]...))
+	}
+}
+
+func (c *Cmd) Run() error {
+	if c.skip() {
+		return nil
+	}
+	return c.Cmd.Run()
+}
+
+func (c *Cmd) Start() error {
+	if c.skip() {
+		return nil
+	}
+	return c.Cmd.Start()
+}
+
+func (c *Cmd) Wait() error {
+	if c.skipped {
+		return nil
+	}
+	return c.Cmd.Wait()
+}
+
+func (c *Cmd) Output() ([]byte, error) {
+	if c.skip() {
+		return []byte{}, nil
+	}
+	return c.Cmd.Output()
+}
+
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	if c.skip() {
+		return []byte{}, nil
+	}
+	return c.Cmd.CombinedOutput()
+}
+
+// dry-run モードに対応した os.WriteFile
+func WriteFile(name string, data []byte, perm os.FileMode) error {
+	if Skip(fmt.Sprintf("write %s (%d bytes)", name, len(data))) {
+		return nil
+	}
+	return os.WriteFile(name, data, perm)
+}
+
+// dry-run モードに対応した os.MkdirAll
+func MkdirAll(path string, perm os.FileMode) error {
+	if Skip(fmt.Sprintf("mkdir -p %s", path)) {
+		return nil
+	}
+	return os.MkdirAll(path, perm)
+}
+
+// dry-run モードに対応した os.RemoveAll
+func RemoveAll(path string) error {
+	if Skip(fmt.Sprintf("rm -rf %s", path)) {
+		return nil
+	}
+	return os.RemoveAll(path)
+}
@@ -0,0 +1,174 @@
+package runner
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dry-run モードを有効にし、記録内容の出力先を差し替える。
+// テスト終了時に元へ戻す。
+func enableDryRun(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	buffer := &bytes.Buffer{}
+	SetOutput(buffer)
+	SetDryRun(true)
+	t.Cleanup(func() {
+		SetDryRun(false)
+		SetOutput(os.Stdout)
+	})
+	return buffer
+}
+
+func TestCommandIsSkippedInDryRun(t *testing.T) {
+	buffer := enableDryRun(t)
+
+	marker := filepath.Join(t.TempDir(), "marker")
+	err := Command("touch", marker).Run()
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatalf("command must not be executed in dry-run mode")
+	}
+
+	want := []string{"touch " + marker}
+	if got := Records(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("records: want %v, got %v", want, got)
+	}
+	if got := buffer.String(); got != "[dry-run] touch "+marker+"\n" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+}
+
+func TestStartAndWaitAreSkippedInDryRun(t *testing.T) {
+	enableDryRun(t)
+
+	cmd := Command("false")
+	err := cmd.Start()
+	if err != nil {
+		t.Fatalf("start error: %v", err)
+	}
+	err = cmd.Wait()
+	if err != nil {
+		t.Fatalf("wait error: %v", err)
+	}
+	if cmd.Process != nil {
+		t.Fatalf("process must not be started in dry-run mode")
+	}
+}
+
+func TestReadOnlyCommandRunsInDryRun(t *testing.T) {
+	enableDryRun(t)
+
+	output, err := ReadOnlyCommand("echo", "hello").Output()
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if strings.TrimSpace(string(output)) != "hello" {
+		t.Fatalf("unexpected output: %q", output)
+	}
+	if len(Records()) != 0 {
+		t.Fatalf("read only command must not be recorded: %v", Records())
+	}
+}
+
+func TestCommandRunsWithoutDryRun(t *testing.T) {
+	SetDryRun(false)
+
+	marker := filepath.Join(t.TempDir(), "marker")
+	err := Command("touch", marker).Run()
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("command must be executed: %v", err)
+	}
+	if len(Records()) != 0 {
+		t.Fatalf("nothing must be recorded: %v", Records())
+	}
+}
+
+func TestFileOperationsAreSkippedInDryRun(t *testing.T) {
+	enableDryRun(t)
+
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing")
+	err := os.WriteFile(existing, []byte("keep"), 0666)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	newDir := filepath.Join(dir, "new")
+	newFile := filepath.Join(dir, "file")
+	if err := MkdirAll(newDir, 0777); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if err := WriteFile(newFile, []byte("data"), 0666); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if err := RemoveAll(existing); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := os.Stat(newDir); !os.IsNotExist(err) {
+		t.Fatalf("directory must not be created in dry-run mode")
+	}
+	if _, err := os.Stat(newFile); !os.IsNotExist(err) {
+		t.Fatalf("file must not be written in dry-run mode")
+	}
+	if _, err := os.Stat(existing); err != nil {
+		t.Fatalf("file must not be removed in dry-run mode: %v", err)
+	}
+
+	want := []string{
+		"mkdir -p " + newDir,
+		"write " + newFile + " (4 bytes)",
+		"rm -rf " + existing,
+	}
+	if got := Records(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("records: want %v, got %v", want, got)
+	}
+}
+
+func TestFormatArgs(t *testing.T) {
+	got := FormatArgs("docker", "exec", "-it", "abc", "sh", "-c", "echo 'hi' $HOME", "")
+	want := `docker exec -it abc sh -c 'echo '\''hi'\'' $HOME' ''`
+	if got != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+}
+
+func TestCommandIsTracedInVerbose(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	SetTraceOutput(buffer)
+	SetVerbose(true)
+	t.Cleanup(func() {
+		SetVerbose(false)
+		SetTraceOutput(os.Stderr)
+	})
+
+	_, err := ReadOnlyCommand("echo", "hello world").Output()
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if got := buffer.String(); got != "[exec] echo 'hello world'\n" {
+		t.Fatalf("unexpected output: %q", got)
+	}
+
+	// dry-run で実行しなかったコマンドは出力しない
+	buffer.Reset()
+	enableDryRun(t)
+	err = Command("touch", filepath.Join(t.TempDir(), "marker")).Run()
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if buffer.Len() != 0 {
+		t.Fatalf("skipped command must not be traced: %q", buffer.String())
+	}
+}
@@ -0,0 +1,459 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/util"
+)
+
+// devcontainer.vim のユーザー設定ファイル名
+const FileName = "settings.json"
+
+// 設定値の由来
+const SourceDefault = "default"
+const SourceUser = "user"
+const SourceLockfile = "lockfile"
+const SourceProject = "project"
+const SourceEnv = "env"
+const SourceFlag = "flag"
+
+// ツールのバージョンを固定するロックファイル名(ワークスペースフォルダの `.devcontainer` に配置する)
+//
+// Example:
+//
+//	{
+//	  "toolVersions": { "vim": "v9.1.1000", "devcontainer": "v0.72.0" },
+//	  "toolDigests": { "vim_amd64": "sha256:..." }
+//	}
+const LockFileName = "devcontainer.vim.lock.json"
+
+// 設定のキー
+const KeyEngine = "engine"
+const KeyEditor = "editor"
+const KeyTmux = "tmux"
+const KeyClipboard = "clipboard"
+const KeyPortForwarding = "portForwarding"
+const KeyShell = "shell"
+const KeyVerbose = "verbose"
+const KeyToolVersions = "toolVersions"
+const KeyToolDigests = "toolDigests"
+const KeyRunargsCommandSubstitution = "runargsCommandSubstitution"
+const KeyOffline = "offline"
+const KeyMirrors = "mirrors"
+
+// 設定を指定する環境変数
+const EnvEngine = "DEVCONTAINER_VIM_ENGINE"
+const EnvEditor = "DEVCONTAINER_VIM_TYPE"
+const EnvShell = "DEVCONTAINER_SHELL_TYPE"
+const EnvTmux = "DEVCONTAINER_VIM_TMUX"
+const EnvClipboard = "DEVCONTAINER_VIM_CLIPBOARD"
+const EnvPortForwarding = "DEVCONTAINER_VIM_PORT_FORWARDING"
+const EnvVerbose = "DEVCONTAINER_VIM_VERBOSE"
+const EnvToolVersions = "DEVCONTAINER_VIM_TOOL_VERSIONS"
+const EnvRunargsCommandSubstitution = "DEVCONTAINER_VIM_RUNARGS_COMMAND_SUBSTITUTION"
+const EnvOffline = "DEVCONTAINER_VIM_OFFLINE"
+const EnvMirrors = "DEVCONTAINER_VIM_MIRRORS"
+
+type InvalidSettingError struct {
+	msg string
+}
+
+func (e *InvalidSettingError) Error() string {
+	return e.msg
+}
+
+// devcontainer.vim のユーザー設定ファイルのスキーマ
+//
+// Example:
+//
+//	{
+//	  // 使用するコンテナエンジン(docker, podman, nerdctl)
+//	  "engine": "podman",
+//	  // 起動するエディター(vim, nvim)
+//	  "editor": "nvim",
+//	  // tmux, clipboard-data-receiver, port-forwarder を使用するか
+//	  "tmux": true,
+//	  "clipboard": true,
+//	  "portForwarding": false,
+//	  // Vim の代わりに起動するシェル
+//	  "shell": "bash",
+//	  // 実行する外部コマンドを表示するか
+//	  "verbose": false,
+//	  // ツールごとのバージョン(リリースのタグ名)
+//	  "toolVersions": { "vim": "v9.1.1000" },
+//	  // ダウンロードファイルごとのダイジェスト(キーは `<ツール名>` または `<ツール名>_<アーキテクチャ>`)
+//	  "toolDigests": { "vim_amd64": "sha256:..." },
+//	  // runargs 内の $(pwd) 以外のコマンド置換を実行するか
+//	  "runargsCommandSubstitution": false,
+//	  // ネットワークへ接続せず、ダウンロード済みのツールとインデックスのみを使用するか
+//	  "offline": false,
+//	  // ツールごとのダウンロード元のベース URL と、テンプレートインデックスの取得元(キー `index`)
+//	  "mirrors": { "vim": "https://mirror.example.com/vim-appimage", "index": "registry.example.com/devcontainers/index" }
+//	}
+//
+// 指定されていない項目は、ゼロ値(文字列は空文字、それ以外は nil)となる。
+type Settings struct {
+	Engine         string            `json:"engine"`
+	Editor         *string           `json:"editor"`
+	Tmux           *bool             `json:"tmux"`
+	Clipboard      *bool             `json:"clipboard"`
+	PortForwarding *bool             `json:"portForwarding"`
+	Shell          *string           `json:"shell"`
+	Verbose        *bool             `json:"verbose"`
+	ToolVersions   map[string]string `json:"toolVersions"`
+	ToolDigests    map[string]string `json:"toolDigests"`
+
+	RunargsCommandSubstitution *bool             `json:"runargsCommandSubstitution"`
+	Offline                    *bool             `json:"offline"`
+	Mirrors                    map[string]string `json:"mirrors"`
+}
+
+// settingsFilePath の設定ファイルを読み込む。
+// ファイルが存在しない場合はゼロ値の Settings を返却する。
+func Load(settingsFilePath string) (Settings, error) {
+	var result Settings
+
+	if !util.IsExists(settingsFilePath) {
+		return result, nil
+	}
+
+	settingsJSON, err := util.ParseJwcc(settingsFilePath)
+	if err != nil {
+		return result, err
+	}
+
+	err = json.Unmarshal(settingsJSON, &result)
+	if err != nil {
+		return result, err
+	}
+
+	err = result.validate(settingsFilePath)
+	if err != nil {
+		return Settings{}, err
+	}
+
+	return result, nil
+}
+
+// workspaceFolder のロックファイルのパスを返却する
+func LockFilePath(workspaceFolder string) string {
+	return filepath.Join(workspaceFolder, ".devcontainer", LockFileName)
+}
+
+// lockFilePath のロックファイルを読み込み、ツールバージョンとダイジェストのみを持つ Settings を返却する。
+// ファイルが存在しない場合はゼロ値の Settings を返却する。
+func LoadLockFile(lockFilePath string) (Settings, error) {
+	if !util.IsExists(lockFilePath) {
+		return Settings{}, nil
+	}
+
+	lockJSON, err := util.ParseJwcc(lockFilePath)
+	if err != nil {
+		return Settings{}, err
+	}
+
+	var lock struct {
+		ToolVersions map[string]string `json:"toolVersions"`
+		ToolDigests  map[string]string `json:"toolDigests"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(lockJSON))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&lock)
+	if err != nil {
+		return Settings{}, &InvalidSettingError{msg: fmt.Sprintf("%s: %v.", lockFilePath, err)}
+	}
+	result := Settings{ToolVersions: lock.ToolVersions, ToolDigests: lock.ToolDigests}
+	err = result.validate(lockFilePath)
+	if err != nil {
+		return Settings{}, err
+	}
+	return result, nil
+}
+
+// 環境変数から設定を読み込む。
+func FromEnv(getenv func(string) string) (Settings, error) {
+	var result Settings
+	var err error
+
+	result.Engine = getenv(EnvEngine)
+	if editor := getenv(EnvEditor); editor != "" {
+		result.Editor = &editor
+	}
+	if shell := getenv(EnvShell); shell != "" {
+		result.Shell = &shell
+	}
+
+	boolEnvs := []struct {
+		name  string
+		value **bool
+	}{
+		{EnvTmux, &result.Tmux},
+		{EnvClipboard, &result.Clipboard},
+		{EnvPortForwarding, &result.PortForwarding},
+		{EnvVerbose, &result.Verbose},
+		{EnvRunargsCommandSubstitution, &result.RunargsCommandSubstitution},
+		{EnvOffline, &result.Offline},
+	}
+	for _, boolEnv := range boolEnvs {
+		*boolEnv.value, err = parseBoolEnv(boolEnv.name, getenv(boolEnv.name))
+		if err != nil {
+			return Settings{}, err
+		}
+	}
+
+	result.ToolVersions, err = parseMapEnv(EnvToolVersions, "TOOL=VERSION", getenv(EnvToolVersions))
+	if err != nil {
+		return Settings{}, err
+	}
+	result.Mirrors, err = parseMapEnv(EnvMirrors, "NAME=URL", getenv(EnvMirrors))
+	if err != nil {
+		return Settings{}, err
+	}
+
+	err = result.validate("environment variable " + EnvEditor)
+	if err != nil {
+		return Settings{}, err
+	}
+	return result, nil
+}
+
+func parseBoolEnv(name string, value string) (*bool, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, &InvalidSettingError{msg: fmt.Sprintf("environment variable %s must be true or false, got %q.", name, value)}
+	}
+	return &parsed, nil
+}
+
+// `KEY=VALUE,KEY=VALUE` 形式の環境変数 name の値をパースする。
+// format はエラーメッセージに表示する 1 項目の形式(`TOOL=VERSION` など)。
+func parseMapEnv(name string, format string, value string) (map[string]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	result := map[string]string{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, entryValue, found := strings.Cut(entry, "=")
+		if !found || key == "" || entryValue == "" {
+			return nil, &InvalidSettingError{msg: fmt.Sprintf("environment variable %s must be `%s[,%s...]`, got %q.", name, format, format, value)}
+		}
+		result[key] = entryValue
+	}
+	return result, nil
+}
+
+var digestPattern = regexp.MustCompile(`^sha256:[0-9a-fA-F]{64}$`)
+
+// editor に指定できる値
+var Editors = []string{"vim", "nvim"}
+
+func (s Settings) validate(source string) error {
+	if s.Editor != nil && !slices.Contains(Editors, *s.Editor) {
+		return &InvalidSettingError{msg: fmt.Sprintf("%s: editor must be one of \"vim\", \"nvim\", got %q.", source, *s.Editor)}
+	}
+	for key, digest := range s.ToolDigests {
+		if !digestPattern.MatchString(digest) {
+			return &InvalidSettingError{msg: fmt.Sprintf("%s: %s.%s must be `sha256:<64 hex digits>`, got %q.", source, KeyToolDigests, key, digest)}
+		}
+	}
+	return nil
+}
+
+// 設定のレイヤー
+type Layer struct {
+	// 由来として表示する名前(SourceUser など)
+	Source   string
+	Settings Settings
+}
+
+// すべてのレイヤーを解決した設定
+type Resolved struct {
+	Engine         string
+	Editor         string
+	Tmux           bool
+	Clipboard      bool
+	PortForwarding bool
+	Shell          string
+	Verbose        bool
+	ToolVersions   map[string]string
+	ToolDigests    map[string]string
+
+	RunargsCommandSubstitution bool
+	Offline                    bool
+	Mirrors                    map[string]string
+
+	// キー(ツールバージョンは `toolVersions.<ツール名>` 、ダイジェストは `toolDigests.<キー>` 、ミラーは `mirrors.<名前>`)ごとの値の由来
+	sources map[string]string
+}
+
+// 解決した設定の 1 項目
+type Entry struct {
+	Key    string `json:"key"`
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+// 既定値に、優先度の低い順に並べた layers を重ねて設定を解決する。
+//
+// 優先順位は 既定値 < ユーザー設定ファイル < ロックファイル < プロジェクト設定 < 環境変数 < コマンドラインオプション とし、
+// 呼び出し元はこの順で layers を渡す。
+func Resolve(layers ...Layer) Resolved {
+	resolved := Resolved{
+		Editor:         "vim",
+		Tmux:           true,
+		Clipboard:      true,
+		PortForwarding: true,
+		ToolVersions:   map[string]string{},
+		ToolDigests:    map[string]string{},
+		Mirrors:        map[string]string{},
+		sources:        map[string]string{},
+	}
+
+	for _, layer := range layers {
+		s := layer.Settings
+		if s.Engine != "" {
+			resolved.Engine = s.Engine
+			resolved.sources[KeyEngine] = layer.Source
+		}
+		if s.Editor != nil {
+			resolved.Editor = *s.Editor
+			resolved.sources[KeyEditor] = layer.Source
+		}
+		if s.Tmux != nil {
+			resolved.Tmux = *s.Tmux
+			resolved.sources[KeyTmux] = layer.Source
+		}
+		if s.Clipboard != nil {
+			resolved.Clipboard = *s.Clipboard
+			resolved.sources[KeyClipboard] = layer.Source
+		}
+		if s.PortForwarding != nil {
+			resolved.PortForwarding = *s.PortForwarding
+			resolved.sources[KeyPortForwarding] = layer.Source
+		}
+		if s.Shell != nil {
+			resolved.Shell = *s.Shell
+			resolved.sources[KeyShell] = layer.Source
+		}
+		if s.Verbose != nil {
+			resolved.Verbose = *s.Verbose
+			resolved.sources[KeyVerbose] = layer.Source
+		}
+		if s.RunargsCommandSubstitution != nil {
+			resolved.RunargsCommandSubstitution = *s.RunargsCommandSubstitution
+			resolved.sources[KeyRunargsCommandSubstitution] = layer.Source
+		}
+		if s.Offline != nil {
+			resolved.Offline = *s.Offline
+			resolved.sources[KeyOffline] = layer.Source
+		}
+		for name, version := range s.ToolVersions {
+			resolved.ToolVersions[name] = version
+			resolved.sources[KeyToolVersions+"."+name] = layer.Source
+		}
+		for key, digest := range s.ToolDigests {
+			resolved.ToolDigests[key] = digest
+			resolved.sources[KeyToolDigests+"."+key] = layer.Source
+		}
+		for name, mirror := range s.Mirrors {
+			resolved.Mirrors[name] = mirror
+			resolved.sources[KeyMirrors+"."+name] = layer.Source
+		}
+	}
+
+	return resolved
+}
+
+// Neovim を使用するかを返却する
+func (r Resolved) Nvim() bool {
+	return r.Editor == "nvim"
+}
+
+// key の値の由来を返却する。どのレイヤーでも指定されていない場合は SourceDefault を返却する。
+func (r Resolved) Source(key string) string {
+	source, ok := r.sources[key]
+	if !ok {
+		return SourceDefault
+	}
+	return source
+}
+
+// 解決した設定を、表示用に一覧で返却する。
+// ツールバージョンはツール名順に `toolVersions.<ツール名>` 、ダイジェストはキー順に `toolDigests.<キー>` 、
+// ミラーは名前順に `mirrors.<名前>` として返却する。
+func (r Resolved) Entries() []Entry {
+	entries := []Entry{
+		{Key: KeyEngine, Value: r.Engine},
+		{Key: KeyEditor, Value: r.Editor},
+		{Key: KeyTmux, Value: r.Tmux},
+		{Key: KeyClipboard, Value: r.Clipboard},
+		{Key: KeyPortForwarding, Value: r.PortForwarding},
+		{Key: KeyShell, Value: r.Shell},
+		{Key: KeyVerbose, Value: r.Verbose},
+		{Key: KeyRunargsCommandSubstitution, Value: r.RunargsCommandSubstitution},
+		{Key: KeyOffline, Value: r.Offline},
+	}
+
+	toolNames := make([]string, 0, len(r.ToolVersions))
+	for name := range r.ToolVersions {
+		toolNames = append(toolNames, name)
+	}
+	sort.Strings(toolNames)
+	for _, name := range toolNames {
+		entries = append(entries, Entry{Key: KeyToolVersions + "." + name, Value: r.ToolVersions[name]})
+	}
+
+	digestKeys := make([]string, 0, len(r.ToolDigests))
+	for key := range r.ToolDigests {
+		digestKeys = append(digestKeys, key)
+	}
+	sort.Strings(digestKeys)
+	for _, key := range digestKeys {
+		entries = append(entries, Entry{Key: KeyToolDigests + "." + key, Value: r.ToolDigests[key]})
+	}
+
+	mirrorNames := make([]string, 0, len(r.Mirrors))
+	for name := range r.Mirrors {
+		mirrorNames = append(mirrorNames, name)
+	}
+	sort.Strings(mirrorNames)
+	for _, name := range mirrorNames {
+		entries = append(entries, Entry{Key: KeyMirrors + "." + name, Value: r.Mirrors[name]})
+	}
+
+	for i := range entries {
+		entries[i].Source = r.Source(entries[i].Key)
+	}
+	return entries
+}
+
+// 解決した設定の一覧を表形式で w へ出力する。
+func WriteTable(w io.Writer, entries []Entry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+	for _, entry := range entries {
+		value := fmt.Sprint(entry.Value)
+		if value == "" {
+			value = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", entry.Key, value, entry.Source)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,216 @@
+package settings
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func boolPointer(value bool) *bool {
+	return &value
+}
+
+func stringPointer(value string) *string {
+	return &value
+}
+
+func TestLoad(t *testing.T) {
+	settingsFile := filepath.Join(t.TempDir(), FileName)
+	err := os.WriteFile(settingsFile, []byte(`{
+  // コメントを書ける
+  "engine": "podman",
+  "editor": "nvim",
+  "tmux": false,
+  "toolVersions": { "vim": "v9.1.1000" },
+}`), 0644)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	got, err := Load(settingsFile)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := Settings{
+		Engine:       "podman",
+		Editor:       stringPointer("nvim"),
+		Tmux:         boolPointer(false),
+		ToolVersions: map[string]string{"vim": "v9.1.1000"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("error: want %+v, but got %+v", want, got)
+	}
+}
+
+func TestLoadNotExists(t *testing.T) {
+	got, err := Load(filepath.Join(t.TempDir(), FileName))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if !reflect.DeepEqual(got, Settings{}) {
+		t.Fatalf("error: want zero value, but got %+v", got)
+	}
+}
+
+func TestLoadInvalidEditor(t *testing.T) {
+	settingsFile := filepath.Join(t.TempDir(), FileName)
+	err := os.WriteFile(settingsFile, []byte(`{"editor": "emacs"}`), 0644)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = Load(settingsFile)
+	var invalidSettingError *InvalidSettingError
+	if !errors.As(err, &invalidSettingError) {
+		t.Fatalf("error: want InvalidSettingError, but got %v", err)
+	}
+}
+
+func TestLoadLockFile(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), LockFileName)
+	err := os.WriteFile(lockFile, []byte(`{
+  // プロジェクトで使用するツールのバージョン
+  "toolVersions": { "vim": "v9.1.1000", "tmux": "3.5a" },
+  "toolDigests": { "vim_amd64": "sha256:5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03" },
+}`), 0644)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	got, err := LoadLockFile(lockFile)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := Settings{
+		ToolVersions: map[string]string{"vim": "v9.1.1000", "tmux": "3.5a"},
+		ToolDigests:  map[string]string{"vim_amd64": "sha256:5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("error: want %+v, but got %+v", want, got)
+	}
+}
+
+func TestLoadLockFileInvalid(t *testing.T) {
+	for _, content := range []string{
+		`{"engine": "podman"}`,
+		`{"toolDigests": {"vim_amd64": "md5:d41d8cd98f00b204e9800998ecf8427e"}}`,
+	} {
+		lockFile := filepath.Join(t.TempDir(), LockFileName)
+		err := os.WriteFile(lockFile, []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+
+		_, err = LoadLockFile(lockFile)
+		var invalidSettingError *InvalidSettingError
+		if !errors.As(err, &invalidSettingError) {
+			t.Errorf("error: %s: want InvalidSettingError, but got %v", content, err)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	env := map[string]string{
+		EnvEditor:       "nvim",
+		EnvTmux:         "false",
+		EnvVerbose:      "1",
+		EnvToolVersions: "vim=v9.1.1000, tmux=3.5a",
+
+		EnvRunargsCommandSubstitution: "true",
+		EnvOffline:                    "true",
+		EnvMirrors:                    "vim=http://mirror.local/vim,index=mirror.local/devcontainers/index",
+	}
+	got, err := FromEnv(func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := Settings{
+		Editor:       stringPointer("nvim"),
+		Tmux:         boolPointer(false),
+		Verbose:      boolPointer(true),
+		ToolVersions: map[string]string{"vim": "v9.1.1000", "tmux": "3.5a"},
+
+		RunargsCommandSubstitution: boolPointer(true),
+		Offline:                    boolPointer(true),
+		Mirrors:                    map[string]string{"vim": "http://mirror.local/vim", "index": "mirror.local/devcontainers/index"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("error: want %+v, but got %+v", want, got)
+	}
+}
+
+func TestFromEnvInvalid(t *testing.T) {
+	tests := []map[string]string{
+		{EnvTmux: "maybe"},
+		{EnvEditor: "emacs"},
+		{EnvToolVersions: "vim"},
+		{EnvMirrors: "vim="},
+	}
+	for _, env := range tests {
+		_, err := FromEnv(func(name string) string { return env[name] })
+		var invalidSettingError *InvalidSettingError
+		if !errors.As(err, &invalidSettingError) {
+			t.Errorf("error: %v: want InvalidSettingError, but got %v", env, err)
+		}
+	}
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	resolved := Resolve(
+		Layer{Source: SourceUser, Settings: Settings{
+			Engine:       "podman",
+			Editor:       stringPointer("nvim"),
+			Tmux:         boolPointer(false),
+			ToolVersions: map[string]string{"vim": "v1", "tmux": "t1"},
+		}},
+		Layer{Source: SourceProject, Settings: Settings{
+			Editor: stringPointer("vim"),
+			Shell:  stringPointer("zsh"),
+		}},
+		Layer{Source: SourceEnv, Settings: Settings{
+			Shell:        stringPointer("bash"),
+			ToolVersions: map[string]string{"vim": "v2"},
+		}},
+		Layer{Source: SourceFlag, Settings: Settings{
+			Tmux: boolPointer(true),
+		}},
+	)
+
+	want := []Entry{
+		{Key: KeyEngine, Value: "podman", Source: SourceUser},
+		{Key: KeyEditor, Value: "vim", Source: SourceProject},
+		{Key: KeyTmux, Value: true, Source: SourceFlag},
+		{Key: KeyClipboard, Value: true, Source: SourceDefault},
+		{Key: KeyPortForwarding, Value: true, Source: SourceDefault},
+		{Key: KeyShell, Value: "bash", Source: SourceEnv},
+		{Key: KeyVerbose, Value: false, Source: SourceDefault},
+		{Key: KeyRunargsCommandSubstitution, Value: false, Source: SourceDefault},
+		{Key: KeyOffline, Value: false, Source: SourceDefault},
+		{Key: "toolVersions.tmux", Value: "t1", Source: SourceUser},
+		{Key: "toolVersions.vim", Value: "v2", Source: SourceEnv},
+	}
+	if got := resolved.Entries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("error: want %+v, but got %+v", want, got)
+	}
+	if resolved.Nvim() {
+		t.Fatalf("error: editor must be vim")
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	err := WriteTable(buffer, []Entry{
+		{Key: KeyEditor, Value: "nvim", Source: SourceFlag},
+		{Key: KeyShell, Value: "", Source: SourceDefault},
+	})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := "KEY     VALUE  SOURCE\neditor  nvim   flag\nshell   -      default\n"
+	if buffer.String() != want {
+		t.Fatalf("error: want %q, but got %q", want, buffer.String())
+	}
+}
@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// ダイジェストのアルゴリズムを表す接頭辞
+const digestPrefix = "sha256:"
+
+type ChecksumMismatchError struct {
+	msg string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return e.msg
+}
+
+// filePath の内容の SHA256 ダイジェストを `sha256:<16 進数>` 形式で返却する
+func FileDigest(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return "", err
+	}
+	return digestPrefix + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// `sha256sum` 形式のチェックサムファイルの内容から、 downloadURL のファイルのダイジェストを返却する。
+// ファイル名の無いダイジェストのみのファイルも受け付ける。
+// 見つからない場合は空文字を返却する。
+func findPublishedDigest(checksums string, downloadURL string) string {
+	assetName := path.Base(downloadURL)
+	lines := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(checksums))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 1 && len(lines) == 1 && isSHA256Hex(fields[0]) {
+			return digestPrefix + strings.ToLower(fields[0])
+		}
+		// バイナリモードの `*` 付きのファイル名も受け付ける
+		if len(fields) == 2 && isSHA256Hex(fields[0]) && strings.TrimPrefix(fields[1], "*") == assetName {
+			return digestPrefix + strings.ToLower(fields[0])
+		}
+	}
+	return ""
+}
+
+func isSHA256Hex(str string) bool {
+	if len(str) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(str)
+	return err == nil
+}
@@ -0,0 +1,45 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileDigest(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "file")
+	err := os.WriteFile(filePath, []byte("hello\n"), 0644)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	got, err := FileDigest(filePath)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := "sha256:5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+	if got != want {
+		t.Fatalf("error: want %s, but got %s", want, got)
+	}
+}
+
+func TestFindPublishedDigest(t *testing.T) {
+	hash := "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+	downloadURL := "https://example.com/releases/download/v1/tool-linux-x86_64.appimage"
+	tests := []struct {
+		checksums string
+		want      string
+	}{
+		{hash + "  tool-linux-x86_64.appimage\n", "sha256:" + hash},
+		{"0000000000000000000000000000000000000000000000000000000000000000  tool-linux-arm64.appimage\n" + hash + " *tool-linux-x86_64.appimage\n", "sha256:" + hash},
+		{hash + "\n", "sha256:" + hash},
+		{hash + "  tool-linux-arm64.appimage\n", ""},
+		{"<html>not found</html>", ""},
+	}
+	for _, test := range tests {
+		got := findPublishedDigest(test.checksums, downloadURL)
+		if got != test.want {
+			t.Errorf("error: %q: want %q, but got %q", test.checksums, test.want, got)
+		}
+	}
+}
@@ -3,14 +3,16 @@ package tools
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"syscall"
 	"text/template"
 	"time"
 
+	"github.com/mikoto2000/devcontainer.vim/v3/output"
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
 	"github.com/mikoto2000/devcontainer.vim/v3/util"
 )
 
@@ -104,9 +106,10 @@ var CDR = func(services InstallerUseServices) Tool {
 	}
 	if err != nil {
 		return Tool{
+			Name:     ToolNameCdr,
 			FileName: cdrFileName,
-			CalculateDownloadURL: func(_ string) (string, error) {
-				return "", err
+			CalculateDownloadURL: func(_ string, _ string) (string, string, error) {
+				return "", "", err
 			},
 			installFunc: func(downloadFunc func(downloadURL string, destPath string) error, downloadURL string, filePath string, containerArch string) (string, error) {
 				return "", err
@@ -117,20 +120,21 @@ var CDR = func(services InstallerUseServices) Tool {
 
 	// 実際に使用する cdr の構造体を返却
 	return Tool{
+		Name:     ToolNameCdr,
 		FileName: cdrFileName,
-		CalculateDownloadURL: func(_ string) (string, error) {
-			latestTagName, err := services.GetLatestReleaseFromGitHub("mikoto2000", "clipboard-data-receiver")
+		CalculateDownloadURL: func(_ string, version string) (string, string, error) {
+			tagName, err := tagNameOrLatest(services, version, "mikoto2000", "clipboard-data-receiver")
 			if err != nil {
-				return "", err
+				return "", "", err
 			}
 
-			tmplParams := map[string]string{"TagName": latestTagName}
+			tmplParams := map[string]string{"TagName": tagName}
 			var downloadURL strings.Builder
 			err = tmpl.Execute(&downloadURL, tmplParams)
 			if err != nil {
-				return "", err
+				return "", "", err
 			}
-			return downloadURL.String(), nil
+			return downloadURL.String(), tagName, nil
 		},
 		installFunc: func(downloadFunc func(downloadURL string, destPath string) error, downloadURL string, filePath string, containerArch string) (string, error) {
 			return simpleInstall(downloadFunc, downloadURL, filePath)
@@ -160,8 +164,8 @@ func RunCdr(cdrPath string, configFileDir string) (int, int, error) {
 
 // clipboard-data-receiver を、 WSL でない環境で実行する場合の処理
 func runCdrForNative(cdrPath string, pidFile string, portFile string) (int, int, error) {
-	fmt.Println("\""+cdrPath+"\"", "--pid-file", pidFile, "--port-file", portFile, "--random-port")
-	cdrRunCommand := exec.Command(cdrPath, "--pid-file", pidFile, "--port-file", portFile, "--random-port")
+	fmt.Fprintln(output.Progress(), "\""+cdrPath+"\"", "--pid-file", pidFile, "--port-file", portFile, "--random-port")
+	cdrRunCommand := runner.Command(cdrPath, "--pid-file", pidFile, "--port-file", portFile, "--random-port")
 	var stdout strings.Builder
 	cdrRunCommand.Stdout = &stdout
 	err := cdrRunCommand.Start()
@@ -188,8 +192,8 @@ func runCdrForNative(cdrPath string, pidFile string, portFile string) (int, int,
 func runCdrForWsl(cdrPath string, pidFile string, portFile string) (int, int, error) {
 	// clipboard-data-receiver.exe を実行
 	commandString := fmt.Sprintf("%s --random-port --pid-file $(wslpath -w %s) --port-file $(wslpath -w %s)", cdrPath, pidFile, portFile)
-	fmt.Println(commandString)
-	cdrRunCommand := exec.Command("sh", "-c", commandString)
+	fmt.Fprintln(output.Progress(), commandString)
+	cdrRunCommand := runner.Command("sh", "-c", commandString)
 	var stdout strings.Builder
 	cdrRunCommand.Stdout = &stdout
 	err := cdrRunCommand.Start()
@@ -242,13 +246,16 @@ func runCdrForWsl(cdrPath string, pidFile string, portFile string) (int, int, er
 func KillCdr(pid int) error {
 	if util.IsWsl() {
 		commandString := fmt.Sprintf("Stop-Process -Id %d -Force", pid)
-		fmt.Printf("Stop clipboard-data-receiver: %s\n", commandString)
-		cdrRunCommand := exec.Command("powershell.exe", "-Command", commandString)
+		fmt.Fprintf(output.Progress(), "Stop clipboard-data-receiver: %s\n", commandString)
+		cdrRunCommand := runner.Command("powershell.exe", "-Command", commandString)
 		err := cdrRunCommand.Start()
 		if err != nil {
 			return err
 		}
 	} else {
+		if runner.Skip(fmt.Sprintf("kill %d", pid)) {
+			return nil
+		}
 		process, err := os.FindProcess(pid)
 		if err != nil {
 			return err
@@ -261,6 +268,25 @@ func KillCdr(pid int) error {
 	return nil
 }
 
+// pid の clipboard-data-receiver が実行中かを返却する
+func IsCdrRunning(pid int) bool {
+	if util.IsWsl() {
+		// WSL の場合、 clipboard-data-receiver は Windows 側のプロセス
+		commandString := fmt.Sprintf("Get-Process -Id %d", pid)
+		return runner.ReadOnlyCommand("powershell.exe", "-Command", commandString).Run() == nil
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		// Windows の FindProcess は、プロセスが存在しない場合にエラーを返す
+		return true
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
 func CreateSendToTCP(configDir string, port int, noCdr bool, nvim bool) (string, error) {
 	// SendToTcp.vim の文字列を組み立て
 	var tmpl *template.Template
@@ -307,7 +333,7 @@ func CreateSendToTCP(configDir string, port int, noCdr bool, nvim bool) (string,
 	} else {
 		sendToTCP = filepath.Join(configDir, "SendToTcp.vim")
 	}
-	err = os.WriteFile(sendToTCP, []byte(sendToTCPString.String()), 0666)
+	err = runner.WriteFile(sendToTCP, []byte(sendToTCPString.String()), 0666)
 	if err != nil {
 		return "", err
 	}
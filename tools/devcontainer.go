@@ -10,17 +10,18 @@ import (
 var DEVCONTAINER = func(services InstallerUseServices) Tool {
 
 	return Tool{
+		Name:     ToolNameDevcontainer,
 		FileName: devcontainerFileName,
-		CalculateDownloadURL: func(_ string) (string, error) {
-			latestTagName, err := services.GetLatestReleaseFromGitHub("mikoto2000", "devcontainers-cli")
+		CalculateDownloadURL: func(_ string, version string) (string, string, error) {
+			tagName, err := tagNameOrLatest(services, version, "mikoto2000", "devcontainers-cli")
 			if err != nil {
-				return "", err
+				return "", "", err
 			}
 
 			pattern := "pattern"
 			tmpl, err := template.New(pattern).Parse(downloadURLDevcontainersCliPattern)
 			if err != nil {
-				return "", err
+				return "", "", err
 			}
 
 			// GOARCH が amd64 だった場合、 x64 に変換する
@@ -30,15 +31,15 @@ var DEVCONTAINER = func(services InstallerUseServices) Tool {
 			}
 
 			tmplParams := map[string]string{
-				"TagName": latestTagName,
+				"TagName": tagName,
 				"Arch":    arch,
 			}
 			var downloadURL strings.Builder
 			err = tmpl.Execute(&downloadURL, tmplParams)
 			if err != nil {
-				return "", err
+				return "", "", err
 			}
-			return downloadURL.String(), nil
+			return downloadURL.String(), tagName, nil
 		},
 		installFunc: func(downloadFunc func(downloadURL string, destPath string) error, downloadURL string, filePath string, containerArch string) (string, error) {
 			return simpleInstall(downloadFunc, downloadURL, filePath)
@@ -0,0 +1,330 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/output"
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
+)
+
+// コンテナのアーキテクチャごとにインストールするツールの、ファイル名の接尾辞に使うアーキテクチャ
+var containerArchs = []string{"amd64", "aarch64"}
+
+type ToolNotFoundError struct {
+	msg string
+}
+
+func (e *ToolNotFoundError) Error() string {
+	return e.msg
+}
+
+// インストールディレクトリ内のツールの 1 項目
+type InstalledTool struct {
+	Name string `json:"name"`
+	Arch string `json:"arch,omitempty"`
+	// manifest に記録されていない場合は空文字
+	Version     string    `json:"version"`
+	Size        int64     `json:"size"`
+	InstalledAt time.Time `json:"installedAt"`
+	// 使用された記録が無い場合は InstalledAt と同じ
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	Path       string    `json:"path"`
+
+	fileName string
+}
+
+// `tool update` で更新した(または更新不要だった)ツールの 1 項目
+type UpdatedTool struct {
+	Name    string `json:"name"`
+	Arch    string `json:"arch,omitempty"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Updated bool   `json:"updated"`
+	Path    string `json:"path"`
+}
+
+// 管理対象のすべてのツールを返却する
+func AllTools(services InstallerUseServices) []Tool {
+	return []Tool{
+		VIM(services),
+		NVIM(services),
+		Tmux(services),
+		DEVCONTAINER(services),
+		CDR(services),
+		PortForwarderContainer(services),
+	}
+}
+
+// name のツールを返却する
+func FindTool(services InstallerUseServices, name string) (Tool, error) {
+	for _, tool := range AllTools(services) {
+		if tool.Name == name {
+			return tool, nil
+		}
+	}
+	return Tool{}, &ToolNotFoundError{msg: fmt.Sprintf("unknown tool %q. Available tools: %s.", name, strings.Join(ToolNames, ", "))}
+}
+
+// installDir 内のツールを、ツール名、アーキテクチャ順に返却する。
+//
+// ファイル名が `<Tool.FileName>` または `<Tool.FileName>_<アーキテクチャ>` のものをツールとして扱う。
+func ListInstalled(installDir string) ([]InstalledTool, error) {
+	result := []InstalledTool{}
+
+	manifest, err := LoadManifest(installDir)
+	if err != nil {
+		return result, err
+	}
+	dirEntries, err := os.ReadDir(installDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return result, nil
+		}
+		return result, err
+	}
+
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			continue
+		}
+		name, arch, ok := toolOfFileName(dirEntry.Name())
+		if !ok {
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			return result, err
+		}
+
+		installed := InstalledTool{
+			Name:        name,
+			Arch:        arch,
+			Size:        info.Size(),
+			InstalledAt: info.ModTime(),
+			Path:        filepath.Join(installDir, dirEntry.Name()),
+			fileName:    dirEntry.Name(),
+		}
+		if entry, ok := manifest.Tools[dirEntry.Name()]; ok {
+			installed.Version = entry.Tag
+			installed.InstalledAt = entry.InstalledAt
+			installed.LastUsedAt = entry.LastUsedAt
+		}
+		if installed.LastUsedAt.IsZero() {
+			installed.LastUsedAt = installed.InstalledAt
+		}
+		result = append(result, installed)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].Arch < result[j].Arch
+	})
+	return result, nil
+}
+
+// インストールディレクトリ内のファイル名から、ツール名とアーキテクチャを返却する
+func toolOfFileName(fileName string) (string, string, bool) {
+	for _, tool := range AllTools(DefaultInstallerUseServices{}) {
+		if fileName == tool.FileName {
+			return tool.Name, "", true
+		}
+		for _, arch := range containerArchs {
+			if fileName == tool.FileName+"_"+arch {
+				return tool.Name, arch, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// installDir 内の name のツールを削除し、削除したものを返却する。
+// arch が空文字の場合は、すべてのアーキテクチャのものを削除する。
+func Remove(installDir string, name string, arch string) ([]InstalledTool, error) {
+	if _, err := FindTool(DefaultInstallerUseServices{}, name); err != nil {
+		return nil, err
+	}
+
+	installed, err := ListInstalled(installDir)
+	if err != nil {
+		return nil, err
+	}
+	targets := []InstalledTool{}
+	for _, tool := range installed {
+		if tool.Name == name && (arch == "" || tool.Arch == arch) {
+			targets = append(targets, tool)
+		}
+	}
+	if len(targets) == 0 {
+		archLabel := ""
+		if arch != "" {
+			archLabel = " (" + arch + ")"
+		}
+		return nil, &ToolNotFoundError{msg: fmt.Sprintf("%s%s is not installed in %s.", name, archLabel, installDir)}
+	}
+
+	return targets, removeInstalled(installDir, targets)
+}
+
+// installDir 内の、 unusedFor の間使用されていないアーキテクチャのツールを削除し、削除したものを返却する。
+//
+// アーキテクチャごとに、そのアーキテクチャのいずれかのツールが使用されていれば残す。
+// アーキテクチャによらないツール(devcontainer CLI など)は削除しない。
+func GC(installDir string, unusedFor time.Duration, now time.Time) ([]InstalledTool, error) {
+	installed, err := ListInstalled(installDir)
+	if err != nil {
+		return nil, err
+	}
+
+	lastUsedByArch := map[string]time.Time{}
+	for _, tool := range installed {
+		if tool.Arch != "" && tool.LastUsedAt.After(lastUsedByArch[tool.Arch]) {
+			lastUsedByArch[tool.Arch] = tool.LastUsedAt
+		}
+	}
+
+	targets := []InstalledTool{}
+	for _, tool := range installed {
+		if tool.Arch != "" && now.Sub(lastUsedByArch[tool.Arch]) > unusedFor {
+			targets = append(targets, tool)
+		}
+	}
+	return targets, removeInstalled(installDir, targets)
+}
+
+// targets のファイルを削除し、 manifest からも取り除く
+func removeInstalled(installDir string, targets []InstalledTool) error {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	manifest, err := LoadManifest(installDir)
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		err := runner.RemoveAll(target.Path)
+		if err != nil {
+			return err
+		}
+		delete(manifest.Tools, target.fileName)
+	}
+	return manifest.Save(installDir)
+}
+
+// インストール済みのツールについて、固定されたバージョン(無ければ最新リリース)を解決し、
+// インストール済みのものと異なる場合のみ更新する。
+//
+// names が空の場合は、インストール済みのすべてのツールを対象とする。
+func Update(services InstallerUseServices, installDir string, names []string) ([]UpdatedTool, error) {
+	result := []UpdatedTool{}
+	if IsOffline() {
+		return result, &OfflineError{msg: "offline mode is enabled, cannot update tools. Disable offline mode to update."}
+	}
+	for _, name := range names {
+		if _, err := FindTool(services, name); err != nil {
+			return result, err
+		}
+	}
+
+	installed, err := ListInstalled(installDir)
+	if err != nil {
+		return result, err
+	}
+	for _, target := range installed {
+		if len(names) != 0 && !slices.Contains(names, target.Name) {
+			continue
+		}
+		tool, err := FindTool(services, target.Name)
+		if err != nil {
+			return result, err
+		}
+
+		version := ""
+		if pinned, ok := PinnedVersionOf(tool.Name); ok {
+			version = pinned.Version
+		}
+		_, tagName, err := tool.CalculateDownloadURL(target.Arch, version)
+		if err != nil {
+			return result, err
+		}
+
+		updated := UpdatedTool{Name: target.Name, Arch: target.Arch, From: target.Version, To: tagName, Path: target.Path}
+		if tagName == target.Version {
+			fmt.Fprintf(output.Progress(), "%s is up to date (%s).\n", target.Path, tagName)
+			result = append(result, updated)
+			continue
+		}
+
+		_, err = tool.install(installDir, target.Arch, tagName, true)
+		if err != nil {
+			return result, err
+		}
+		updated.Updated = true
+		result = append(result, updated)
+	}
+	return result, nil
+}
+
+// インストール済みのツールの一覧を表形式で w へ出力する。
+func WriteInstalledTable(w io.Writer, installed []InstalledTool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tARCH\tVERSION\tSIZE\tINSTALLED\tLAST USED")
+	for _, tool := range installed {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			tool.Name,
+			orDash(tool.Arch),
+			orDash(tool.Version),
+			formatSize(tool.Size),
+			tool.InstalledAt.Local().Format(time.DateTime),
+			tool.LastUsedAt.Local().Format(time.DateTime))
+	}
+	return tw.Flush()
+}
+
+// 更新結果の一覧を表形式で w へ出力する。
+func WriteUpdatedTable(w io.Writer, updated []UpdatedTool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tARCH\tFROM\tTO\tSTATUS")
+	for _, tool := range updated {
+		status := "up to date"
+		if tool.Updated {
+			status = "updated"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", tool.Name, orDash(tool.Arch), orDash(tool.From), orDash(tool.To), status)
+	}
+	return tw.Flush()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// バイト数を、 KiB, MiB 単位の読みやすい文字列にする
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	value := float64(size) / unit
+	for _, suffix := range []string{"KiB", "MiB"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f%s", value, suffix)
+		}
+		value /= unit
+	}
+	return fmt.Sprintf("%.1fGiB", value)
+}
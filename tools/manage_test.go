@@ -0,0 +1,149 @@
+package tools
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// installDir へ fileName のツールを配置し、 manifest へ記録する
+func installForTest(t *testing.T, installDir string, fileName string, entry ManifestEntry) {
+	t.Helper()
+
+	err := os.WriteFile(filepath.Join(installDir, fileName), []byte("binary"), 0755)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	manifest, err := LoadManifest(installDir)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	manifest.Tools[fileName] = entry
+	err = manifest.Save(installDir)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+}
+
+func TestListInstalled(t *testing.T) {
+	installDir := t.TempDir()
+	installedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	installForTest(t, installDir, "vim_amd64", ManifestEntry{Tool: ToolNameVim, Arch: "amd64", Tag: "v9.1.1000", InstalledAt: installedAt})
+	installForTest(t, installDir, "tmux_aarch64", ManifestEntry{Tool: ToolNameTmux, Arch: "aarch64", Tag: "v3.5a", InstalledAt: installedAt, LastUsedAt: installedAt.Add(time.Hour)})
+	// manifest に記録されていないもの、ツール以外のファイル
+	os.WriteFile(filepath.Join(installDir, "nvim_amd64"), []byte("nvim"), 0755)
+	os.WriteFile(filepath.Join(installDir, "vim_amd64.part"), []byte{}, 0644)
+
+	got, err := ListInstalled(installDir)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("error: want 3 tools, but got %+v", got)
+	}
+	if got[0].Name != ToolNameNvim || got[0].Version != "" || got[0].Size != 4 {
+		t.Errorf("error: unexpected untracked tool %+v", got[0])
+	}
+	if got[1].Name != ToolNameTmux || got[1].Arch != "aarch64" || !got[1].LastUsedAt.Equal(installedAt.Add(time.Hour)) {
+		t.Errorf("error: unexpected tool %+v", got[1])
+	}
+	if got[2].Name != ToolNameVim || got[2].Version != "v9.1.1000" || !got[2].LastUsedAt.Equal(installedAt) {
+		t.Errorf("error: unexpected tool %+v", got[2])
+	}
+}
+
+func TestRemove(t *testing.T) {
+	installDir := t.TempDir()
+	installForTest(t, installDir, "vim_amd64", ManifestEntry{Tool: ToolNameVim, Arch: "amd64", InstalledAt: time.Now()})
+	installForTest(t, installDir, "vim_aarch64", ManifestEntry{Tool: ToolNameVim, Arch: "aarch64", InstalledAt: time.Now()})
+
+	removed, err := Remove(installDir, ToolNameVim, "aarch64")
+	if err != nil || len(removed) != 1 {
+		t.Fatalf("error: want 1 removed, but got %+v, %v", removed, err)
+	}
+	manifest, _ := LoadManifest(installDir)
+	if _, ok := manifest.Tools["vim_aarch64"]; ok || !fileExistsForTest(installDir, "vim_amd64") || fileExistsForTest(installDir, "vim_aarch64") {
+		t.Fatalf("error: want only vim_aarch64 removed")
+	}
+
+	_, err = Remove(installDir, ToolNameVim, "aarch64")
+	var toolNotFoundError *ToolNotFoundError
+	if !errors.As(err, &toolNotFoundError) {
+		t.Fatalf("error: want ToolNotFoundError, but got %v", err)
+	}
+}
+
+func TestGC(t *testing.T) {
+	installDir := t.TempDir()
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	old := now.Add(-40 * 24 * time.Hour)
+	recent := now.Add(-10 * 24 * time.Hour)
+	installForTest(t, installDir, "vim_amd64", ManifestEntry{Tool: ToolNameVim, Arch: "amd64", InstalledAt: old, LastUsedAt: old})
+	installForTest(t, installDir, "tmux_amd64", ManifestEntry{Tool: ToolNameTmux, Arch: "amd64", InstalledAt: old, LastUsedAt: recent})
+	installForTest(t, installDir, "vim_aarch64", ManifestEntry{Tool: ToolNameVim, Arch: "aarch64", InstalledAt: old, LastUsedAt: old})
+	installForTest(t, installDir, devcontainerFileName, ManifestEntry{Tool: ToolNameDevcontainer, InstalledAt: old, LastUsedAt: old})
+
+	removed, err := GC(installDir, 30*24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	// amd64 は最近使用されているので残し、アーキテクチャによらないツールも残す
+	if len(removed) != 1 || removed[0].Path != filepath.Join(installDir, "vim_aarch64") {
+		t.Fatalf("error: want only vim_aarch64 removed, but got %+v", removed)
+	}
+	for _, fileName := range []string{"vim_amd64", "tmux_amd64", devcontainerFileName} {
+		if !fileExistsForTest(installDir, fileName) {
+			t.Errorf("error: %s is removed", fileName)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	installDir := t.TempDir()
+	downloaded := []string{}
+	services := recordingInstallerUseServices{latest: "v2", downloaded: &downloaded}
+	installForTest(t, installDir, devcontainerFileName, ManifestEntry{Tool: ToolNameDevcontainer, Tag: "v1", InstalledAt: time.Now()})
+	installForTest(t, installDir, CdrFileName, ManifestEntry{Tool: ToolNameCdr, Tag: "v2", InstalledAt: time.Now()})
+
+	updated, err := Update(services, installDir, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(updated) != 2 {
+		t.Fatalf("error: want 2 results, but got %+v", updated)
+	}
+	for _, tool := range updated {
+		wantUpdated := tool.Name == ToolNameDevcontainer
+		if tool.Updated != wantUpdated || tool.To != "v2" {
+			t.Errorf("error: unexpected result %+v", tool)
+		}
+	}
+	if len(downloaded) != 1 {
+		t.Fatalf("error: want only devcontainer downloaded, but got %v", downloaded)
+	}
+
+	manifest, _ := LoadManifest(installDir)
+	if got := manifest.Tools[devcontainerFileName].Tag; got != "v2" {
+		t.Fatalf("error: want v2, but got %s", got)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		512:              "512B",
+		2048:             "2.0KiB",
+		15 * 1024 * 1024: "15.0MiB",
+	}
+	for size, want := range tests {
+		if got := formatSize(size); got != want {
+			t.Errorf("error: %d: want %s, but got %s", size, want, got)
+		}
+	}
+}
+
+func fileExistsForTest(dir string, fileName string) bool {
+	_, err := os.Stat(filepath.Join(dir, fileName))
+	return err == nil
+}
@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
+	"github.com/mikoto2000/devcontainer.vim/v3/util"
+)
+
+// インストールしたツールの情報を記録するファイル名
+const ManifestFileName = "manifest.json"
+
+type CorruptedToolError struct {
+	msg string
+}
+
+func (e *CorruptedToolError) Error() string {
+	return e.msg
+}
+
+// インストールしたツールの情報
+type ManifestEntry struct {
+	Tool string `json:"tool"`
+	Arch string `json:"arch,omitempty"`
+	Tag  string `json:"tag"`
+	URL  string `json:"url"`
+	// インストールしたファイル(アーカイブの場合は展開後のファイル)の `sha256:<16 進数>` 形式のダイジェスト
+	Digest      string    `json:"digest"`
+	InstalledAt time.Time `json:"installedAt"`
+	// インストール済みのものを最後に使用した日時(`tool gc` で使用する)
+	LastUsedAt time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// filePath の内容が、記録したダイジェストと一致するかを検証する。
+// ダイジェストが記録されていない場合(ダイジェスト記録前にインストールしたもの)は検証しない。
+func (e ManifestEntry) Verify(filePath string) error {
+	if e.Digest == "" {
+		return nil
+	}
+	digest, err := FileDigest(filePath)
+	if err != nil {
+		return err
+	}
+	if digest != e.Digest {
+		return &CorruptedToolError{msg: fmt.Sprintf("%s is corrupted: digest %s does not match the recorded digest %s.", filePath, digest, e.Digest)}
+	}
+	return nil
+}
+
+// インストールディレクトリ内のツールの情報
+type Manifest struct {
+	// インストールディレクトリ内のファイル名をキーとする
+	Tools map[string]ManifestEntry `json:"tools"`
+}
+
+// installDir の manifest を読み込む。
+// ファイルが存在しない場合は空の Manifest を返却する。
+func LoadManifest(installDir string) (Manifest, error) {
+	result := Manifest{Tools: map[string]ManifestEntry{}}
+
+	manifestPath := filepath.Join(installDir, ManifestFileName)
+	if !util.IsExists(manifestPath) {
+		return result, nil
+	}
+
+	manifestJSON, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(manifestJSON, &result)
+	if err != nil {
+		return result, err
+	}
+	if result.Tools == nil {
+		result.Tools = map[string]ManifestEntry{}
+	}
+	return result, nil
+}
+
+// installDir へ manifest を書き込む
+func (m Manifest) Save(installDir string) error {
+	manifestJSON, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return runner.WriteFile(filepath.Join(installDir, ManifestFileName), manifestJSON, 0666)
+}
+
+// fileName のツールを使用した日時を記録する。
+// manifest に記録されていないもの(記録前にインストールしたもの)と、 dry-run 時は記録しない。
+func (m Manifest) recordUse(installDir string, fileName string) error {
+	entry, ok := m.Tools[fileName]
+	if !ok || runner.IsDryRun() {
+		return nil
+	}
+	entry.LastUsedAt = time.Now()
+	m.Tools[fileName] = entry
+	return m.Save(installDir)
+}
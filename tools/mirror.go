@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"strings"
+)
+
+// ミラーの設定で、テンプレートインデックスの取得元を表す名前
+const MirrorNameIndex = "index"
+
+// GitHub のリリースのダウンロード URL で、タグ名の直前に現れるパス
+const releaseDownloadPath = "/releases/download/"
+
+type OfflineError struct {
+	msg string
+}
+
+func (e *OfflineError) Error() string {
+	return e.msg
+}
+
+// 現在のツールごとのダウンロード元のベース URL
+var mirrors = map[string]string{}
+
+// オフラインモード
+var offline = false
+
+// ツールごとのダウンロード元のベース URL を設定する。
+//
+// ベース URL は、 GitHub のリリースの `https://github.com/<owner>/<repo>/releases/download` を置き換えるもので、
+// `<ベース URL>/<タグ名>/<ファイル名>` からダウンロードする。
+func SetMirrors(baseURLs map[string]string) {
+	mirrors = map[string]string{}
+	for name, baseURL := range baseURLs {
+		mirrors[name] = baseURL
+	}
+}
+
+// name のダウンロード元のベース URL を返却する
+func MirrorOf(name string) (string, bool) {
+	baseURL, ok := mirrors[name]
+	return baseURL, ok
+}
+
+// オフラインモードを設定する。
+// オフラインモードでは、ダウンロードやリリースの問い合わせを行わず、インストール済みのツールのみを使用する。
+func SetOffline(enabled bool) {
+	offline = enabled
+}
+
+// オフラインモードかを返却する
+func IsOffline() bool {
+	return offline
+}
+
+// name のミラーが設定されている場合、 GitHub のリリースのダウンロード URL をミラーの URL へ置き換える
+func mirrorURL(name string, downloadURL string) string {
+	baseURL, ok := MirrorOf(name)
+	if !ok {
+		return downloadURL
+	}
+	index := strings.Index(downloadURL, releaseDownloadPath)
+	if index < 0 {
+		return downloadURL
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + downloadURL[index+len(releaseDownloadPath):]
+}
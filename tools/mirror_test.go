@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// name というファイル名で content を格納した tar.gz を返却する
+func tarGzForTest(t *testing.T, name string, content string) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	gzw := gzip.NewWriter(&buffer)
+	tw := tar.NewWriter(gzw)
+	err := tw.WriteHeader(&tar.Header{Name: "tmux-3.5a/" + name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, err = tw.Write([]byte(content))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	tw.Close()
+	gzw.Close()
+	return buffer.Bytes()
+}
+
+func TestMirrorURL(t *testing.T) {
+	defer SetMirrors(nil)
+	SetMirrors(map[string]string{ToolNameVim: "https://mirror.example.com/vim/"})
+
+	got := mirrorURL(ToolNameVim, "https://github.com/vim/vim-appimage/releases/download/v9.1.1000/Vim-v9.1.1000.glibc2.34-x86_64.AppImage")
+	want := "https://mirror.example.com/vim/v9.1.1000/Vim-v9.1.1000.glibc2.34-x86_64.AppImage"
+	if got != want {
+		t.Fatalf("error: want %s, but got %s", want, got)
+	}
+
+	// ミラーが設定されていないツールはそのまま
+	downloadURL := "https://github.com/tmux/tmux-builds/releases/download/v3.5a/tmux-3.5a-linux-x86_64.tar.gz"
+	if got := mirrorURL(ToolNameTmux, downloadURL); got != downloadURL {
+		t.Fatalf("error: want %s, but got %s", downloadURL, got)
+	}
+}
+
+func TestInstallFromMirror(t *testing.T) {
+	archive := tarGzForTest(t, "tmux", "tmux binary")
+	requested := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		if r.URL.Path != "/tmux-builds/v3.5a/tmux-3.5a-linux-x86_64.tar.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(archive)
+	}))
+	defer server.Close()
+	defer SetMirrors(nil)
+	SetMirrors(map[string]string{ToolNameTmux: server.URL + "/tmux-builds"})
+
+	installDir := t.TempDir()
+	filePath, err := Tmux(TestInstallerUseServices{}).InstallVersion(installDir, "amd64", "v3.5a")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil || string(content) != "tmux binary" {
+		t.Fatalf("error: want extracted tmux, but got %q, %v", content, err)
+	}
+	if len(requested) != 1 {
+		t.Fatalf("error: want 1 request to mirror, but got %v", requested)
+	}
+	manifest, err := LoadManifest(installDir)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if got, want := manifest.Tools["tmux_amd64"].URL, server.URL+"/tmux-builds/v3.5a/tmux-3.5a-linux-x86_64.tar.gz"; got != want {
+		t.Fatalf("error: want %s, but got %s", want, got)
+	}
+}
+
+func TestInstallOffline(t *testing.T) {
+	defer SetOffline(false)
+	SetOffline(true)
+
+	// ダウンロードが必要な場合はエラー
+	installDir := t.TempDir()
+	_, err := Tmux(TestInstallerUseServices{}).Install(installDir, "amd64", false)
+	var offlineError *OfflineError
+	if !errors.As(err, &offlineError) {
+		t.Fatalf("error: want OfflineError, but got %v", err)
+	}
+
+	// ローカルのファイルからはインストールできる
+	archivePath := filepath.Join(t.TempDir(), "tmux-3.5a-linux-x86_64.tar.gz")
+	err = os.WriteFile(archivePath, tarGzForTest(t, "tmux", "tmux binary"), 0644)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	filePath, err := Tmux(TestInstallerUseServices{}).InstallFromFile(installDir, "amd64", "v3.5a", archivePath)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	manifest, err := LoadManifest(installDir)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if got := manifest.Tools["tmux_amd64"].Tag; got != "v3.5a" {
+		t.Fatalf("error: want v3.5a, but got %s", got)
+	}
+
+	// インストール済みのものは使用できる
+	got, err := Tmux(TestInstallerUseServices{}).Install(installDir, "amd64", false)
+	if err != nil || got != filePath {
+		t.Fatalf("error: want %s, but got %s, %v", filePath, got, err)
+	}
+
+	// 更新はできない
+	_, err = Tmux(TestInstallerUseServices{}).InstallVersion(installDir, "amd64", "v3.6")
+	if !errors.As(err, &offlineError) {
+		t.Fatalf("error: want OfflineError, but got %v", err)
+	}
+}
@@ -4,60 +4,67 @@ import (
 	"errors"
 	"strings"
 	"text/template"
-
-	"github.com/mikoto2000/devcontainer.vim/v3/util"
 )
 
 // NeoVim のダウンロード URL
-const nvimAppImageDownloadURLPattern = "https://github.com/neovim/neovim/releases/download/{{ .TagName }}/nvim-linux-x86_64.appimage"
+const nvimReleaseDownloadURLPrefix = "https://github.com/neovim/neovim/releases/download/"
+const nvimAppImageDownloadURLPattern = nvimReleaseDownloadURLPrefix + "{{ .TagName }}/nvim-linux-x86_64.appimage"
 const nvimArmStaticDownloadURLPattern = "https://github.com/mikoto2000/vim-static/releases/download/{{ .TagName }}/vim-{{ .TagName }}-aarch64.tar.gz"
 
 // NeoVim のツール情報
 var NVIM = func(service InstallerUseServices) Tool {
 	return Tool{
+		Name:     ToolNameNvim,
 		FileName: "nvim",
-		CalculateDownloadURL: func(containerArch string) (string, error) {
+		CalculateDownloadURL: func(containerArch string, version string) (string, string, error) {
 			if containerArch == "amd64" || containerArch == "x86_64" {
-				latestTagName, err := util.GetLatestReleaseFromGitHub("neovim", "neovim")
+				tagName, err := tagNameOrLatest(service, version, "neovim", "neovim")
 				if err != nil {
-					return "", err
+					return "", "", err
 				}
 
 				pattern := "pattern"
 				tmpl, err := template.New(pattern).Parse(nvimAppImageDownloadURLPattern)
 				if err != nil {
-					return "", err
+					return "", "", err
 				}
 
-				tmplParams := map[string]string{"TagName": latestTagName}
+				tmplParams := map[string]string{"TagName": tagName}
 				var downloadURL strings.Builder
 				err = tmpl.Execute(&downloadURL, tmplParams)
 				if err != nil {
-					return "", err
+					return "", "", err
 				}
-				return downloadURL.String(), nil
+				return downloadURL.String(), tagName, nil
 			} else if containerArch == "arm64" || containerArch == "aarch64" {
-				latestTagName, err := util.GetLatestReleaseFromGitHub("mikoto2000", "vim-static")
+				tagName, err := tagNameOrLatest(service, version, "mikoto2000", "vim-static")
 				if err != nil {
-					return "", err
+					return "", "", err
 				}
 
 				pattern := "pattern"
 				tmpl, err := template.New(pattern).Parse(nvimArmStaticDownloadURLPattern)
 				if err != nil {
-					return "", err
+					return "", "", err
 				}
 
-				tmplParams := map[string]string{"TagName": latestTagName}
+				tmplParams := map[string]string{"TagName": tagName}
 				var downloadURL strings.Builder
 				err = tmpl.Execute(&downloadURL, tmplParams)
 				if err != nil {
-					return "", err
+					return "", "", err
 				}
-				return downloadURL.String(), nil
+				return downloadURL.String(), tagName, nil
 			} else {
-				return "", errors.New("Unknown Architecture")
+				return "", "", errors.New("Unknown Architecture")
+			}
+		},
+		CalculateChecksumURL: func(downloadURL string) string {
+			// NeoVim の AppImage は、リリースの shasum.txt にチェックサムが公開されている
+			if !strings.HasPrefix(downloadURL, nvimReleaseDownloadURLPrefix) {
+				return ""
 			}
+			return downloadURL[:strings.LastIndex(downloadURL, "/")+1] + "shasum.txt"
 		},
 		installFunc: func(downloadFunc func(downloadURL string, destPath string) error, downloadURL string, filePath string, containerArch string) (string, error) {
 			return simpleInstall(downloadFunc, downloadURL, filePath)
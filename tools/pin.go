@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"strings"
+)
+
+// ツール名(Tool.Name)
+const ToolNameVim = "vim"
+const ToolNameNvim = "nvim"
+const ToolNameTmux = "tmux"
+const ToolNameDevcontainer = "devcontainer"
+const ToolNameCdr = "clipboard-data-receiver"
+const ToolNamePortForwarder = "port-forwarder"
+
+// バージョンを固定できるツール名の一覧
+var ToolNames = []string{ToolNameVim, ToolNameNvim, ToolNameTmux, ToolNameDevcontainer, ToolNameCdr, ToolNamePortForwarder}
+
+type PinnedVersionError struct {
+	msg string
+}
+
+func (e *PinnedVersionError) Error() string {
+	return e.msg
+}
+
+// 固定されたツールのバージョン
+type PinnedVersion struct {
+	// リリースのタグ名
+	Version string
+	// 固定した設定の由来(settings.SourceUser など)
+	Source string
+}
+
+// 現在のツールごとの固定バージョン
+var pinnedVersions = map[string]PinnedVersion{}
+
+// ツールごとの固定バージョンを設定する
+func SetPinnedVersions(pins map[string]PinnedVersion) {
+	pinnedVersions = map[string]PinnedVersion{}
+	for name, pin := range pins {
+		pinnedVersions[name] = pin
+	}
+}
+
+// name のツールの固定バージョンを返却する
+func PinnedVersionOf(name string) (PinnedVersion, bool) {
+	pin, ok := pinnedVersions[name]
+	return pin, ok
+}
+
+// 固定されたダウンロードファイルのダイジェスト
+type PinnedDigest struct {
+	// `sha256:<16 進数>` 形式のダイジェスト
+	Digest string
+	// 固定した設定の由来(settings.SourceUser など)
+	Source string
+}
+
+// 現在のツールごとの固定ダイジェスト(キーは DigestKey の戻り値)
+var pinnedDigests = map[string]PinnedDigest{}
+
+// ダイジェストを固定する際のキーを返却する。
+// アーキテクチャごとにダウンロードするファイルが異なるツールは `<ツール名>_<アーキテクチャ>` 、それ以外はツール名とする。
+func DigestKey(name string, containerArch string) string {
+	if containerArch == "" {
+		return name
+	}
+	return name + "_" + containerArch
+}
+
+// ダイジェストを固定する際のキーからツール名を返却する
+func ToolNameOfDigestKey(key string) string {
+	name, _, _ := strings.Cut(key, "_")
+	return name
+}
+
+// ツールごとの固定ダイジェストを設定する
+func SetPinnedDigests(pins map[string]PinnedDigest) {
+	pinnedDigests = map[string]PinnedDigest{}
+	for key, pin := range pins {
+		pinnedDigests[key] = pin
+	}
+}
+
+// key (DigestKey の戻り値)の固定ダイジェストを返却する
+func PinnedDigestOf(key string) (PinnedDigest, bool) {
+	pin, ok := pinnedDigests[key]
+	return pin, ok
+}
@@ -13,11 +13,12 @@ const downloadURLPortForwarderContainerArm64Pattern = "https://github.com/mikoto
 var PortForwarderContainer = func(services InstallerUseServices) Tool {
 
 	return Tool{
+		Name:     ToolNamePortForwarder,
 		FileName: "port-forwarder-container",
-		CalculateDownloadURL: func(containerArch string) (string, error) {
-			latestTagName, err := services.GetLatestReleaseFromGitHub("mikoto2000", "port-forwarder")
+		CalculateDownloadURL: func(containerArch string, version string) (string, string, error) {
+			tagName, err := tagNameOrLatest(services, version, "mikoto2000", "port-forwarder")
 			if err != nil {
-				return "", err
+				return "", "", err
 			}
 
 			pattern := "pattern"
@@ -27,19 +28,19 @@ var PortForwarderContainer = func(services InstallerUseServices) Tool {
 			} else if containerArch == "aarch64" {
 				tmpl, err = template.New(pattern).Parse(downloadURLPortForwarderContainerArm64Pattern)
 			} else {
-				return "", errors.New("port-forwarder-container download error: Unknown arch")
+				return "", "", errors.New("port-forwarder-container download error: Unknown arch")
 			}
 			if err != nil {
-				return "", err
+				return "", "", err
 			}
 
-			tmplParams := map[string]string{"TagName": latestTagName}
+			tmplParams := map[string]string{"TagName": tagName}
 			var downloadURL strings.Builder
 				err = tmpl.Execute(&downloadURL, tmplParams)
 				if err != nil {
-					return "", err
+					return "", "", err
 				}
-			return downloadURL.String(), nil
+			return downloadURL.String(), tagName, nil
 		},
 		installFunc: func(downloadFunc func(downloadURL string, destPath string) error, downloadURL string, filePath string, containerArch string) (string, error) {
 			return simpleInstall(downloadFunc, downloadURL, filePath)
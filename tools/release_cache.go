@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/output"
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
+	"github.com/mikoto2000/devcontainer.vim/v3/util"
+)
+
+// 最新リリースのタグ名のキャッシュファイル名
+const ReleaseCacheFileName = "releases.json"
+
+// 最新リリースのタグ名をキャッシュする期間
+const ReleaseCacheTTL = time.Hour
+
+// 最新リリースのタグ名のキャッシュの 1 項目
+type releaseCacheEntry struct {
+	Tag       string    `json:"tag"`
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+// 最新リリースのタグ名のキャッシュファイルのスキーマ(キーは `<owner>/<repository>`)
+type releaseCache map[string]releaseCacheEntry
+
+// 現在のキャッシュファイルのパス(空文字の場合はキャッシュしない)
+var releaseCacheFile = ""
+
+// 最新リリースのタグ名のキャッシュファイルを設定する。空文字の場合はキャッシュしない。
+func SetReleaseCacheFile(cacheFile string) {
+	releaseCacheFile = cacheFile
+}
+
+// owner/repository の最新リリースのタグ名を返却する。
+//
+// キャッシュが ReleaseCacheTTL 以内のものであれば、 GitHub へ問い合わせずにキャッシュの値を返却する。
+// refresh が true の場合は、キャッシュを使わずに問い合わせる。
+// レートリミットに達した場合は、期限切れのキャッシュがあればそれを警告付きで返却する。
+func cachedLatestRelease(owner string, repository string, refresh bool, now time.Time, fetch func(owner string, repository string) (string, error)) (string, error) {
+	if releaseCacheFile == "" {
+		return fetch(owner, repository)
+	}
+
+	key := owner + "/" + repository
+	cache := loadReleaseCache(releaseCacheFile)
+	entry, cached := cache[key]
+	if cached && !refresh && now.Sub(entry.FetchedAt) < ReleaseCacheTTL {
+		return entry.Tag, nil
+	}
+
+	tag, err := fetch(owner, repository)
+	if err != nil {
+		var rateLimitError *util.GitHubRateLimitError
+		if cached && errors.As(err, &rateLimitError) {
+			fmt.Fprintf(output.Progress(), "Warning: %v Use cached latest release of %s (%s, fetched at %s).\n", err, key, entry.Tag, entry.FetchedAt.Local().Format(time.DateTime))
+			return entry.Tag, nil
+		}
+		return "", err
+	}
+
+	cache[key] = releaseCacheEntry{Tag: tag, FetchedAt: now}
+	err = saveReleaseCache(releaseCacheFile, cache)
+	if err != nil {
+		fmt.Fprintf(output.Progress(), "Warning: failed to save %s: %v\n", releaseCacheFile, err)
+	}
+	return tag, nil
+}
+
+// キャッシュファイルを読み込む。存在しない、または壊れている場合は空のキャッシュを返却する。
+func loadReleaseCache(cacheFile string) releaseCache {
+	cache := releaseCache{}
+	content, err := os.ReadFile(cacheFile)
+	if err != nil {
+		return cache
+	}
+	if json.Unmarshal(content, &cache) != nil || cache == nil {
+		return releaseCache{}
+	}
+	return cache
+}
+
+// キャッシュファイルを保存する。 dry-run 時は保存しない。
+func saveReleaseCache(cacheFile string, cache releaseCache) error {
+	if runner.IsDryRun() {
+		return nil
+	}
+	content, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cacheFile, content, 0666)
+}
@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/util"
+)
+
+// 呼ばれた回数を記録し、 tags を順に返却する fetch 関数を返却する
+func fetchForTest(calls *int, tags ...string) func(string, string) (string, error) {
+	return func(owner string, repository string) (string, error) {
+		tag := tags[*calls]
+		*calls++
+		return tag, nil
+	}
+}
+
+func TestCachedLatestRelease(t *testing.T) {
+	SetReleaseCacheFile(filepath.Join(t.TempDir(), ReleaseCacheFileName))
+	t.Cleanup(func() { SetReleaseCacheFile("") })
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	calls := 0
+	fetch := fetchForTest(&calls, "v1", "v2", "v3")
+
+	tests := []struct {
+		now       time.Time
+		refresh   bool
+		want      string
+		wantCalls int
+	}{
+		{now, false, "v1", 1},
+		// TTL 以内はキャッシュを使う
+		{now.Add(ReleaseCacheTTL - time.Minute), false, "v1", 1},
+		// TTL を過ぎたら問い合わせる
+		{now.Add(ReleaseCacheTTL), false, "v2", 2},
+		// refresh の場合は TTL 以内でも問い合わせる
+		{now.Add(ReleaseCacheTTL), true, "v3", 3},
+	}
+	for i, test := range tests {
+		got, err := cachedLatestRelease("mikoto2000", "devcontainer.vim", test.refresh, test.now, fetch)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		if got != test.want || calls != test.wantCalls {
+			t.Errorf("error: %d: want %s (%d calls), but got %s (%d calls)", i, test.want, test.wantCalls, got, calls)
+		}
+	}
+}
+
+func TestCachedLatestReleaseRateLimited(t *testing.T) {
+	SetReleaseCacheFile(filepath.Join(t.TempDir(), ReleaseCacheFileName))
+	t.Cleanup(func() { SetReleaseCacheFile("") })
+
+	rateLimited := func(string, string) (string, error) {
+		return "", &util.GitHubRateLimitError{Reset: time.Now().Add(time.Hour)}
+	}
+
+	// キャッシュが無い場合はレートリミットのエラーを返却する
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := cachedLatestRelease("tmux", "tmux", false, now, rateLimited)
+	var rateLimitError *util.GitHubRateLimitError
+	if !errors.As(err, &rateLimitError) {
+		t.Fatalf("error: want GitHubRateLimitError, but got %v", err)
+	}
+
+	// 期限切れのキャッシュがある場合はそれを使う
+	calls := 0
+	_, err = cachedLatestRelease("tmux", "tmux", false, now, fetchForTest(&calls, "3.5a"))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	got, err := cachedLatestRelease("tmux", "tmux", false, now.Add(2*ReleaseCacheTTL), rateLimited)
+	if err != nil || got != "3.5a" {
+		t.Fatalf("error: want stale 3.5a, but got %s, %v", got, err)
+	}
+}
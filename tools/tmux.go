@@ -15,8 +15,9 @@ const tmuxDownloadURLPattern = "https://github.com/tmux/tmux-builds/releases/dow
 
 var Tmux = func(service InstallerUseServices) Tool {
 	return Tool{
+		Name:     ToolNameTmux,
 		FileName: "tmux",
-		CalculateDownloadURL: func(containerArch string) (string, error) {
+		CalculateDownloadURL: func(containerArch string, version string) (string, string, error) {
 			var platform string
 			switch containerArch {
 			case "amd64", "x86_64":
@@ -24,32 +25,32 @@ var Tmux = func(service InstallerUseServices) Tool {
 			case "arm64", "aarch64":
 				platform = "linux-arm64"
 			default:
-				return "", errors.New("Unknown Architecture")
+				return "", "", errors.New("Unknown Architecture")
 			}
 
-			latestTagName, err := service.GetLatestReleaseFromGitHub("tmux", "tmux-builds")
+			tagName, err := tagNameOrLatest(service, version, "tmux", "tmux-builds")
 			if err != nil {
-				return "", err
+				return "", "", err
 			}
 
 			pattern := "pattern"
 			tmpl, err := template.New(pattern).Parse(tmuxDownloadURLPattern)
 			if err != nil {
-				return "", err
+				return "", "", err
 			}
 
 			tmplParams := map[string]string{
-				"TagName":  latestTagName,
-				"Version":  strings.TrimPrefix(latestTagName, "v"),
+				"TagName":  tagName,
+				"Version":  strings.TrimPrefix(tagName, "v"),
 				"Platform": platform,
 			}
 
 			var downloadURL strings.Builder
 			err = tmpl.Execute(&downloadURL, tmplParams)
 			if err != nil {
-				return "", err
+				return "", "", err
 			}
-			return downloadURL.String(), nil
+			return downloadURL.String(), tagName, nil
 		},
 		installFunc: func(downloadFunc func(downloadURL string, destPath string) error, downloadURL string, filePath string, containerArch string) (string, error) {
 			archivePath := filePath + ".tar.gz"
@@ -1,13 +1,18 @@
 package tools
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
+	"time"
 
+	"github.com/mikoto2000/devcontainer.vim/v3/output"
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
 	"github.com/mikoto2000/devcontainer.vim/v3/util"
 )
 
@@ -16,26 +21,63 @@ type InstallerUseServices interface {
 	Download(downloadURL string, destPath string) error
 }
 
-type DefaultInstallerUseServices struct{}
+type DefaultInstallerUseServices struct {
+	// true の場合、最新リリースのタグ名のキャッシュを使わずに GitHub へ問い合わせる(結果はキャッシュする)
+	RefreshReleases bool
+}
 
 func (s DefaultInstallerUseServices) GetLatestReleaseFromGitHub(owner string, repository string) (string, error) {
-	return util.GetLatestReleaseFromGitHub(owner, repository)
+	return cachedLatestRelease(owner, repository, s.RefreshReleases, time.Now(), util.GetLatestReleaseFromGitHub)
 }
 
 func (s DefaultInstallerUseServices) Download(downloadURL string, destPath string) error {
 	return download(downloadURL, destPath)
 }
 
+// ダウンロード時に、 HTTP ステータスが 200 以外だった場合のエラー
+type DownloadStatusError struct {
+	msg string
+
+	StatusCode int
+}
+
+func (e *DownloadStatusError) Error() string {
+	return e.msg
+}
+
 // ツール情報
 type Tool struct {
-	FileName             string
-	CalculateDownloadURL func(containerArch string) (string, error)
+	// バージョン固定に使用するツール名(設定 `toolVersions` のキー)
+	Name     string
+	FileName string
+	// tagName のリリースのダウンロード URL と、そのタグ名を返却する。
+	// tagName が空文字の場合は、最新リリースを使用する。
+	CalculateDownloadURL func(containerArch string, tagName string) (string, string, error)
+	// ダウンロード URL から、公開されている `sha256sum` 形式のチェックサムファイルの URL を返却する。
+	// チェックサムを公開していないツールは nil 、ダウンロード URL によって公開されていない場合は空文字を返却する。
+	CalculateChecksumURL func(downloadURL string) string
 	installFunc          func(downloadFunc func(downloadURL string, destPath string) error, downloadURL string, filePath string, containerArch string) (string, error)
 	DownloadFunc         func(downloadURL string, destPath string) error
 }
 
-// ツールのインストールを実行
+// ツールのインストールを実行。
+//
+// バージョンが固定されている場合、固定されたバージョンをインストールする。
+// 既にインストール済みのものが固定されたバージョンと異なる場合は、 override に関わらず置き換える。
+// インストール済みのものが manifest に記録したダイジェストと一致しない場合は、同じバージョンを再インストールする。
+//
+// ダウンロードしたファイルは、固定されたダイジェストか公開されているチェックサムで検証する。
 func (t Tool) Install(installDir string, containerArch string, override bool) (string, error) {
+	return t.install(installDir, containerArch, "", override)
+}
+
+// version のリリースをインストールする。
+// バージョンが固定されていて、 version と異なる場合はインストールせずにエラーを返却する。
+func (t Tool) InstallVersion(installDir string, containerArch string, version string) (string, error) {
+	return t.install(installDir, containerArch, version, true)
+}
+
+func (t Tool) install(installDir string, containerArch string, version string, override bool) (string, error) {
 
 	// tool download から直接呼ばれることもあるのでここでも正規化する
 	containerArch, err := util.NormalizeContainerArch(containerArch)
@@ -43,6 +85,15 @@ func (t Tool) Install(installDir string, containerArch string, override bool) (s
 		return "", nil
 	}
 
+	// 固定されたバージョンと異なるバージョンへは、黙って更新しない
+	pinned, isPinned := PinnedVersionOf(t.Name)
+	if isPinned {
+		if version != "" && version != pinned.Version {
+			return "", &PinnedVersionError{msg: fmt.Sprintf("%s is pinned to %s by %s. Refusing to install %s, change the pin instead.", t.Name, pinned.Version, pinned.Source, version)}
+		}
+		version = pinned.Version
+	}
+
 	// ツールの配置先組み立て
 	var fileName string
 	if containerArch != "" {
@@ -52,16 +103,247 @@ func (t Tool) Install(installDir string, containerArch string, override bool) (s
 	}
 	filePath := filepath.Join(installDir, fileName)
 
+	manifest, err := LoadManifest(installDir)
+	if err != nil {
+		return "", err
+	}
+
 	if util.IsExists(filePath) && !override {
-		fmt.Printf("%s aleady exist, use this.\n", filePath)
+		installed := manifest.Tools[fileName]
+		if version == "" || installed.Tag == version {
+			// 中断されたダウンロードなどで壊れたものをコンテナへコピーしないよう、記録したダイジェストと照合する
+			err := installed.Verify(filePath)
+			if err == nil {
+				fmt.Fprintf(output.Progress(), "%s aleady exist, use this.\n", filePath)
+				return filePath, manifest.recordUse(installDir, fileName)
+			}
+			var corruptedToolError *CorruptedToolError
+			if !errors.As(err, &corruptedToolError) {
+				return "", err
+			}
+			fmt.Fprintf(output.Progress(), "%v Reinstall it.\n", err)
+			if version == "" {
+				version = installed.Tag
+			}
+		} else {
+			installedVersion := installed.Tag
+			if installedVersion == "" {
+				installedVersion = "unknown version"
+			}
+			fmt.Fprintf(output.Progress(), "%s is pinned to %s by %s, but %s is installed. Replace it.\n", t.Name, version, pinned.Source, installedVersion)
+		}
+	}
+
+	if IsOffline() {
+		versionLabel := ""
+		if version != "" {
+			versionLabel = " " + version
+		}
+		archOption := ""
+		if containerArch != "" {
+			archOption = " --arch " + containerArch
+		}
+		return "", &OfflineError{msg: fmt.Sprintf("offline mode is enabled, cannot download %s%s to %s. Seed it with `devcontainer.vim tool %s install --from-file PATH%s`, or disable offline mode.", t.Name, versionLabel, filePath, t.Name, archOption)}
+	}
+
+	downloadURL, tagName, err := t.CalculateDownloadURL(containerArch, version)
+	if err != nil {
+		return "", err
+	}
+	if runner.Skip(fmt.Sprintf("download %s to %s", mirrorURL(t.Name, downloadURL), filePath)) {
 		return filePath, nil
-	} else {
-		downloadURL, err := t.CalculateDownloadURL(containerArch)
+	}
+
+	expectedDigest, digestSource, err := t.expectedDigest(downloadURL, filePath, containerArch)
+	if err != nil {
+		return "", err
+	}
+
+	return t.installArtifact(installDir, fileName, containerArch, mirrorURL(t.Name, downloadURL), tagName, t.DownloadFunc, expectedDigest, digestSource)
+}
+
+// sourcePath のファイル(リリースからダウンロードしたものと同じファイル)から、ツールをインストールする。
+// ネットワークへ接続できない環境で、 installDir へツールを配置するために使用する。
+//
+// version は manifest へ記録するタグ名で、省略した場合は固定されたバージョンを記録する。
+// 固定されたダイジェストがある場合は、 sourcePath のファイルを検証する。
+func (t Tool) InstallFromFile(installDir string, containerArch string, version string, sourcePath string) (string, error) {
+	containerArch, err := util.NormalizeContainerArch(containerArch)
+	if err != nil {
+		return "", err
+	}
+
+	pinned, isPinned := PinnedVersionOf(t.Name)
+	if isPinned {
+		if version != "" && version != pinned.Version {
+			return "", &PinnedVersionError{msg: fmt.Sprintf("%s is pinned to %s by %s. Refusing to install %s, change the pin instead.", t.Name, pinned.Version, pinned.Source, version)}
+		}
+		version = pinned.Version
+	}
+
+	fileName := t.FileName
+	if containerArch != "" {
+		fileName = t.FileName + "_" + containerArch
+	}
+	filePath := filepath.Join(installDir, fileName)
+
+	absSourcePath, err := filepath.Abs(sourcePath)
+	if err != nil {
+		return "", err
+	}
+	if !util.IsExists(absSourcePath) {
+		return "", fmt.Errorf("%s not found", sourcePath)
+	}
+	if runner.Skip(fmt.Sprintf("install %s to %s", absSourcePath, filePath)) {
+		return filePath, nil
+	}
+
+	expectedDigest, digestSource := "", ""
+	if pinnedDigest, ok := PinnedDigestOf(DigestKey(t.Name, containerArch)); ok {
+		expectedDigest, digestSource = pinnedDigest.Digest, "pinned by "+pinnedDigest.Source
+	}
+	copyFunc := func(_ string, destPath string) error {
+		return copyFile(absSourcePath, destPath)
+	}
+	return t.installArtifact(installDir, fileName, containerArch, "file://"+filepath.ToSlash(absSourcePath), version, copyFunc, expectedDigest, digestSource)
+}
+
+// downloadFunc で取得したファイルを検証してインストールし、 manifest へ記録する。
+//
+// 一時ファイルへインストールし、検証後に置き換えることで、中断されても壊れたファイルを残さない。
+func (t Tool) installArtifact(installDir string, fileName string, containerArch string, downloadURL string, tagName string, downloadFunc func(downloadURL string, destPath string) error, expectedDigest string, digestSource string) (string, error) {
+	filePath := filepath.Join(installDir, fileName)
+	tempPath := filePath + ".part"
+	defer os.Remove(tempPath)
+	verifyingDownload := func(url string, destPath string) error {
+		err := downloadFunc(url, destPath)
 		if err != nil {
-			return "", err
+			return err
 		}
-		return t.installFunc(t.DownloadFunc, downloadURL, filePath, containerArch)
+		if expectedDigest == "" {
+			return nil
+		}
+		digest, err := FileDigest(destPath)
+		if err != nil {
+			return err
+		}
+		if digest != expectedDigest {
+			os.Remove(destPath)
+			return &ChecksumMismatchError{msg: fmt.Sprintf("checksum mismatch for %s: expected %s (%s), got %s.", url, expectedDigest, digestSource, digest)}
+		}
+		return nil
+	}
+	_, err := t.installFunc(verifyingDownload, downloadURL, tempPath, containerArch)
+	if err != nil {
+		return filePath, err
+	}
+
+	installedDigest, err := FileDigest(tempPath)
+	if err != nil {
+		return filePath, err
+	}
+	err = os.Rename(tempPath, filePath)
+	if err != nil {
+		return filePath, err
+	}
+
+	// インストールしたバージョンとダイジェストを記録
+	manifest, err := LoadManifest(installDir)
+	if err != nil {
+		return filePath, err
+	}
+	now := time.Now()
+	manifest.Tools[fileName] = ManifestEntry{
+		Tool:        t.Name,
+		Arch:        containerArch,
+		Tag:         tagName,
+		URL:         downloadURL,
+		Digest:      installedDigest,
+		InstalledAt: now,
+		LastUsedAt:  now,
+	}
+	err = manifest.Save(installDir)
+	if err != nil {
+		return filePath, err
+	}
+	return filePath, nil
+}
+
+// downloadURL からダウンロードするファイルの、期待するダイジェストとその由来を返却する。
+//
+// 固定されたダイジェストがあればそれを、無ければツールが公開しているチェックサムファイルの値を使用する。
+// ミラーが設定されている場合は、チェックサムファイルもミラーから取得する。
+// どちらも無い場合は空文字を返却する。
+func (t Tool) expectedDigest(downloadURL string, filePath string, containerArch string) (string, string, error) {
+	pinned, isPinned := PinnedDigestOf(DigestKey(t.Name, containerArch))
+	if isPinned {
+		return pinned.Digest, "pinned by " + pinned.Source, nil
+	}
+	if t.CalculateChecksumURL == nil {
+		warnUnverified(downloadURL, "no checksum is published for "+t.Name)
+		return "", "", nil
+	}
+
+	checksumURL := t.CalculateChecksumURL(downloadURL)
+	if checksumURL == "" {
+		warnUnverified(downloadURL, "no checksum is published for "+t.Name)
+		return "", "", nil
 	}
+	checksumURL = mirrorURL(t.Name, checksumURL)
+	checksumPath := filePath + ".sha256"
+	defer os.Remove(checksumPath)
+	err := t.DownloadFunc(checksumURL, checksumPath)
+	if err != nil {
+		var downloadStatusError *DownloadStatusError
+		if errors.As(err, &downloadStatusError) && downloadStatusError.StatusCode == http.StatusNotFound {
+			warnUnverified(downloadURL, fmt.Sprintf("checksum file %s is not published", checksumURL))
+			return "", "", nil
+		}
+		return "", "", err
+	}
+	checksums, err := os.ReadFile(checksumPath)
+	if err != nil {
+		return "", "", err
+	}
+	digest := findPublishedDigest(string(checksums), downloadURL)
+	if digest == "" {
+		warnUnverified(downloadURL, fmt.Sprintf("%s is not listed in %s", path.Base(downloadURL), checksumURL))
+		return "", "", nil
+	}
+	return digest, "published in " + checksumURL, nil
+}
+
+// downloadURL のファイルを検証せずにインストールすることを警告する。
+//
+// 検証したい場合は `toolDigests` でダイジェストを固定する。
+func warnUnverified(downloadURL string, reason string) {
+	fmt.Fprintf(output.Progress(), "Warning: %s is installed unverified (%s). Pin its digest with `toolDigests` to verify it.\n", downloadURL, reason)
+}
+
+// version が空文字の場合は GitHub の最新リリースのタグ名を、それ以外は version を返却する
+func tagNameOrLatest(services InstallerUseServices, version string, owner string, repository string) (string, error) {
+	if version != "" {
+		return version, nil
+	}
+	return services.GetLatestReleaseFromGitHub(owner, repository)
+}
+
+// src のファイルを dest へコピーする
+func copyFile(src string, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
 }
 
 // 単純なファイル配置でインストールが完了するもののインストール処理。
@@ -95,10 +377,10 @@ func (p *ProgressWriter) Write(data []byte) (int, error) {
 	p.Current += int64(n)
 
 	percentage := float64(p.Current) / float64(p.Total) * 100.0
-	fmt.Printf("%6.2f%%", percentage)
+	fmt.Fprintf(output.Progress(), "%6.2f%%", percentage)
 
 	// カーソルを 7 文字戻す
-	fmt.Printf("\033[7D")
+	fmt.Fprintf(output.Progress(), "\033[7D")
 
 	return n, nil
 }
@@ -107,7 +389,10 @@ func (p *ProgressWriter) Write(data []byte) (int, error) {
 //
 // downloadURL からファイルをダウンロードし、 destPath へ配置する。
 func download(downloadURL string, destPath string) error {
-	fmt.Printf("Download %s from %s ...", filepath.Base(destPath), downloadURL)
+	if runner.Skip(fmt.Sprintf("download %s to %s", downloadURL, destPath)) {
+		return nil
+	}
+	fmt.Fprintf(output.Progress(), "Download %s from %s ...", filepath.Base(destPath), downloadURL)
 
 	// HTTP GETリクエストを送信
 	resp, err := http.Get(downloadURL)
@@ -116,6 +401,11 @@ func download(downloadURL string, destPath string) error {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(output.Progress(), " failed. \n")
+		return &DownloadStatusError{msg: fmt.Sprintf("download %s failed: %s.", downloadURL, resp.Status), StatusCode: resp.StatusCode}
+	}
+
 	size := resp.ContentLength
 
 	// ファイルを作成
@@ -130,12 +420,17 @@ func download(downloadURL string, destPath string) error {
 	}
 
 	// レスポンスの内容をファイルに書き込み
-	_, err = io.Copy(out, io.TeeReader(resp.Body, progress))
+	written, err := io.Copy(out, io.TeeReader(resp.Body, progress))
+	if err == nil && size >= 0 && written != size {
+		err = fmt.Errorf("download %s was truncated: got %d of %d bytes", downloadURL, written, size)
+	}
 	if err != nil {
+		out.Close()
+		os.Remove(destPath)
 		return err
 	}
 
-	fmt.Printf(" done. \n")
+	fmt.Fprintf(output.Progress(), " done. \n")
 
 	return nil
 }
@@ -211,6 +506,10 @@ func InstallDownTools(installDir string) (string, error) {
 
 // SelfUpdate downloads the latest release of devcontainer.vim from GitHub and replaces the current binary
 func SelfUpdate(services InstallerUseServices) error {
+	if IsOffline() {
+		return &OfflineError{msg: "offline mode is enabled, cannot update devcontainer.vim. Disable offline mode to update."}
+	}
+
 	// Get the latest release tag name from GitHub
 	latestTagName, err := services.GetLatestReleaseFromGitHub("mikoto2000", "devcontainer.vim")
 	if err != nil {
@@ -228,6 +527,9 @@ func SelfUpdate(services InstallerUseServices) error {
 	if err != nil {
 		return err
 	}
+	if runner.Skip(fmt.Sprintf("replace %s with %s", executablePath, downloadURL)) {
+		return nil
+	}
 
 	// Rename the current binary to avoid "text file busy" error
 	tempPath := executablePath + ".old"
@@ -246,6 +548,6 @@ func SelfUpdate(services InstallerUseServices) error {
 	// Remove the old binary
 	os.Remove(tempPath)
 
-	fmt.Println("devcontainer.vim has been updated to the latest version.")
+	fmt.Fprintln(output.Progress(), "devcontainer.vim has been updated to the latest version.")
 	return nil
 }
@@ -1,10 +1,15 @@
 package tools
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/mikoto2000/devcontainer.vim/v3/output"
 	"github.com/mikoto2000/devcontainer.vim/v3/util"
 )
 
@@ -59,3 +64,257 @@ func TestSelfUpdate(t *testing.T) {
 		t.Fatalf("error: %s", err)
 	}
 }
+
+// 最新リリースとして latest を返却し、ダウンロードした URL を記録する
+type recordingInstallerUseServices struct {
+	latest     string
+	downloaded *[]string
+}
+
+func (s recordingInstallerUseServices) GetLatestReleaseFromGitHub(owner string, repository string) (string, error) {
+	return s.latest, nil
+}
+
+func (s recordingInstallerUseServices) Download(downloadURL string, destPath string) error {
+	*s.downloaded = append(*s.downloaded, downloadURL)
+	return os.WriteFile(destPath, []byte{}, 0755)
+}
+
+func TestInstallPinnedVersion(t *testing.T) {
+	installDir := t.TempDir()
+	downloaded := []string{}
+	services := recordingInstallerUseServices{latest: "v2", downloaded: &downloaded}
+	defer SetPinnedVersions(nil)
+
+	// 固定されていなければ最新リリースをインストールし、 manifest へ記録する
+	_, err := DEVCONTAINER(services).Install(installDir, "", false)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	manifest, err := LoadManifest(installDir)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if got := manifest.Tools[devcontainerFileName]; got.Tool != ToolNameDevcontainer || got.Tag != "v2" {
+		t.Fatalf("error: unexpected manifest entry %+v", got)
+	}
+
+	// 固定されたバージョンと異なるものがインストール済みなら、固定されたバージョンで置き換える
+	SetPinnedVersions(map[string]PinnedVersion{ToolNameDevcontainer: {Version: "v1", Source: "lockfile"}})
+	_, err = DEVCONTAINER(services).Install(installDir, "", false)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	manifest, _ = LoadManifest(installDir)
+	if got := manifest.Tools[devcontainerFileName].Tag; got != "v1" {
+		t.Fatalf("error: want v1, but got %s", got)
+	}
+
+	// 固定されたバージョンがインストール済みなら、ダウンロードしない
+	_, err = DEVCONTAINER(services).Install(installDir, "", false)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(downloaded) != 2 {
+		t.Fatalf("error: want 2 downloads, but got %v", downloaded)
+	}
+
+	// 上書き指定でも、最新リリースへは更新しない
+	_, err = DEVCONTAINER(services).Install(installDir, "", true)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if last := downloaded[len(downloaded)-1]; !strings.Contains(last, "/v1/") {
+		t.Fatalf("error: want pinned version download, but got %s", last)
+	}
+
+	// 固定されたバージョンと異なるバージョンの指定はエラー
+	_, err = DEVCONTAINER(services).InstallVersion(installDir, "", "v3")
+	var pinnedVersionError *PinnedVersionError
+	if !errors.As(err, &pinnedVersionError) {
+		t.Fatalf("error: want PinnedVersionError, but got %v", err)
+	}
+}
+
+func TestInstallVersion(t *testing.T) {
+	installDir := t.TempDir()
+	downloaded := []string{}
+	services := recordingInstallerUseServices{latest: "v2", downloaded: &downloaded}
+
+	_, err := DEVCONTAINER(services).InstallVersion(installDir, "", "v1")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(downloaded) != 1 || !strings.Contains(downloaded[0], "/v1/") {
+		t.Fatalf("error: want v1 download, but got %v", downloaded)
+	}
+}
+
+// URL ごとの内容を返却するダウンロード処理を持つ、テスト用のツールを返却する
+func testTool(contents map[string]string, downloaded *[]string, checksumURL string) Tool {
+	return Tool{
+		Name:     ToolNameTmux,
+		FileName: "tool",
+		CalculateDownloadURL: func(containerArch string, version string) (string, string, error) {
+			return "https://example.com/" + version + "/tool", version, nil
+		},
+		CalculateChecksumURL: func(downloadURL string) string {
+			return checksumURL
+		},
+		installFunc: func(downloadFunc func(downloadURL string, destPath string) error, downloadURL string, filePath string, containerArch string) (string, error) {
+			return simpleInstall(downloadFunc, downloadURL, filePath)
+		},
+		DownloadFunc: func(downloadURL string, destPath string) error {
+			*downloaded = append(*downloaded, downloadURL)
+			content, ok := contents[downloadURL]
+			if !ok {
+				return &DownloadStatusError{msg: "not found", StatusCode: http.StatusNotFound}
+			}
+			return os.WriteFile(destPath, []byte(content), 0644)
+		},
+	}
+}
+
+const helloDigest = "sha256:5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+
+func TestInstallVerifiesPublishedChecksum(t *testing.T) {
+	contents := map[string]string{
+		"https://example.com/v1/tool":       "hello\n",
+		"https://example.com/v1/shasum.txt": strings.TrimPrefix(helloDigest, "sha256:") + "  tool\n",
+		"https://example.com/v2/tool":       "broken",
+		"https://example.com/v2/shasum.txt": strings.TrimPrefix(helloDigest, "sha256:") + "  tool\n",
+	}
+	downloaded := []string{}
+
+	installDir := t.TempDir()
+	filePath, err := testTool(contents, &downloaded, "https://example.com/v1/shasum.txt").InstallVersion(installDir, "", "v1")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	manifest, err := LoadManifest(installDir)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if got := manifest.Tools["tool"].Digest; got != helloDigest {
+		t.Fatalf("error: want %s, but got %s", helloDigest, got)
+	}
+
+	// チェックサムが一致しない場合は、インストール済みのものを置き換えない
+	_, err = testTool(contents, &downloaded, "https://example.com/v2/shasum.txt").InstallVersion(installDir, "", "v2")
+	var checksumMismatchError *ChecksumMismatchError
+	if !errors.As(err, &checksumMismatchError) {
+		t.Fatalf("error: want ChecksumMismatchError, but got %v", err)
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil || string(content) != "hello\n" {
+		t.Fatalf("error: installed file is replaced: %q, %v", content, err)
+	}
+	if util.IsExists(filePath + ".part") {
+		t.Fatalf("error: temporary file is left")
+	}
+
+	// チェックサムファイルが公開されていない場合は、検証せずにインストールする
+	_, err = testTool(contents, &downloaded, "https://example.com/v2/missing.txt").InstallVersion(installDir, "", "v2")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+}
+
+func TestInstallWarnsUnverifiedDownload(t *testing.T) {
+	contents := map[string]string{"https://example.com/v1/tool": "hello\n"}
+	downloaded := []string{}
+
+	// 進捗表示の出力先をファイルへ差し替える
+	progressPath := filepath.Join(t.TempDir(), "progress")
+	progress, err := os.Create(progressPath)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	defer progress.Close()
+	stdout := os.Stdout
+	os.Stdout = progress
+	output.SetFormat(output.FormatText)
+	os.Stdout = stdout
+	defer output.SetFormat(output.FormatText)
+
+	// 固定されたダイジェストも、公開されたチェックサムも無ければ警告する
+	_, err = testTool(contents, &downloaded, "").InstallVersion(t.TempDir(), "", "v1")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	message, _ := os.ReadFile(progressPath)
+	if !strings.Contains(string(message), "Warning: https://example.com/v1/tool is installed unverified") {
+		t.Fatalf("error: want unverified warning, but got %q", message)
+	}
+}
+
+func TestInstallVerifiesPinnedDigest(t *testing.T) {
+	contents := map[string]string{"https://example.com/v1/tool": "hello\n"}
+	downloaded := []string{}
+	defer SetPinnedDigests(nil)
+
+	SetPinnedDigests(map[string]PinnedDigest{ToolNameTmux + "_amd64": {Digest: "sha256:" + strings.Repeat("0", 64), Source: "lockfile"}})
+	_, err := testTool(contents, &downloaded, "").InstallVersion(t.TempDir(), "amd64", "v1")
+	var checksumMismatchError *ChecksumMismatchError
+	if !errors.As(err, &checksumMismatchError) {
+		t.Fatalf("error: want ChecksumMismatchError, but got %v", err)
+	}
+
+	SetPinnedDigests(map[string]PinnedDigest{ToolNameTmux + "_amd64": {Digest: helloDigest, Source: "lockfile"}})
+	_, err = testTool(contents, &downloaded, "").InstallVersion(t.TempDir(), "amd64", "v1")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+}
+
+func TestInstallReinstallsCorruptedTool(t *testing.T) {
+	contents := map[string]string{"https://example.com/v1/tool": "hello\n"}
+	downloaded := []string{}
+	installDir := t.TempDir()
+
+	filePath, err := testTool(contents, &downloaded, "").InstallVersion(installDir, "", "v1")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	// 壊れていなければダウンロードしない
+	_, err = testTool(contents, &downloaded, "").Install(installDir, "", false)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(downloaded) != 1 {
+		t.Fatalf("error: want 1 download, but got %v", downloaded)
+	}
+
+	// 中断されたダウンロードを模して切り詰めると、記録したバージョンを再インストールする
+	err = os.WriteFile(filePath, []byte("he"), 0755)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	_, err = testTool(contents, &downloaded, "").Install(installDir, "", false)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(downloaded) != 2 || downloaded[1] != "https://example.com/v1/tool" {
+		t.Fatalf("error: want reinstall of v1, but got %v", downloaded)
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil || string(content) != "hello\n" {
+		t.Fatalf("error: want reinstalled file, but got %q, %v", content, err)
+	}
+}
+
+func TestDownloadStatusError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	destPath := filepath.Join(t.TempDir(), "tool")
+	err := download(server.URL+"/tool", destPath)
+	var downloadStatusError *DownloadStatusError
+	if !errors.As(err, &downloadStatusError) || downloadStatusError.StatusCode != http.StatusNotFound {
+		t.Fatalf("error: want DownloadStatusError, but got %v", err)
+	}
+	if util.IsExists(destPath) {
+		t.Fatalf("error: file is created for failed download")
+	}
+}
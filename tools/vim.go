@@ -15,69 +15,70 @@ const vimArmStaticDownloadURLPattern = "https://github.com/mikoto2000/vim-static
 // Vim のツール情報
 var VIM = func(service InstallerUseServices) Tool {
 	return Tool{
+		Name:     ToolNameVim,
 		FileName: "vim",
-		CalculateDownloadURL: func(containerArch string) (string, error) {
+		CalculateDownloadURL: func(containerArch string, version string) (string, string, error) {
 			if containerArch == "amd64" || containerArch == "x86_64" {
 				if runtime.GOOS != "darwin" {
-					latestTagName, err := service.GetLatestReleaseFromGitHub("vim", "vim-appimage")
+					tagName, err := tagNameOrLatest(service, version, "vim", "vim-appimage")
 					if err != nil {
-						return "", err
+						return "", "", err
 					}
 
 					pattern := "pattern"
 					tmpl, err := template.New(pattern).Parse(vimAppImageDownloadURLPattern)
 					if err != nil {
-						return "", err
+						return "", "", err
 					}
 
-					tmplParams := map[string]string{"TagName": latestTagName}
+					tmplParams := map[string]string{"TagName": tagName}
 					var downloadURL strings.Builder
 					err = tmpl.Execute(&downloadURL, tmplParams)
 					if err != nil {
-						return "", err
+						return "", "", err
 					}
-					return downloadURL.String(), nil
+					return downloadURL.String(), tagName, nil
 				} else {
-					latestTagName, err := service.GetLatestReleaseFromGitHub("vim", "vim-appimage")
+					tagName, err := tagNameOrLatest(service, version, "vim", "vim-appimage")
 					if err != nil {
-						return "", err
+						return "", "", err
 					}
 
 					pattern := "pattern"
 					tmpl, err := template.New(pattern).Parse(vimX64StaticDownloadURLPattern)
 					if err != nil {
-						return "", err
+						return "", "", err
 					}
 
-					tmplParams := map[string]string{"TagName": latestTagName}
+					tmplParams := map[string]string{"TagName": tagName}
 					var downloadURL strings.Builder
 					err = tmpl.Execute(&downloadURL, tmplParams)
 					if err != nil {
-						return "", err
+						return "", "", err
 					}
-					return downloadURL.String(), nil
+					return downloadURL.String(), tagName, nil
 				}
 			} else if containerArch == "arm64" || containerArch == "aarch64" {
-				latestTagName, err := service.GetLatestReleaseFromGitHub("mikoto2000", "vim-static")
+				tagName, err := tagNameOrLatest(service, version, "mikoto2000", "vim-static")
 				if err != nil {
-					return "", err
+					return "", "", err
 				}
 
 				pattern := "pattern"
 				tmpl, err := template.New(pattern).Parse(vimArmStaticDownloadURLPattern)
 				if err != nil {
-					return "", err
+					return "", "", err
 				}
 
-				tmplParams := map[string]string{"TagName": latestTagName}
+				tmplParams := map[string]string{"TagName": tagName}
 				var downloadURL strings.Builder
 				err = tmpl.Execute(&downloadURL, tmplParams)
 				if err != nil {
-					return "", err
+					return "", "", err
 				}
-				return downloadURL.String(), nil
+				return downloadURL.String(), tagName, nil
 			} else {
-				return "", errors.New("Unknown Architecture")
+				return "", "", errors.New("Unknown Architecture")
 			}
 		},
 		installFunc: func(downloadFunc func(downloadURL string, destPath string) error, downloadURL string, filePath string, containerArch string) (string, error) {
@@ -0,0 +1,255 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
+)
+
+type CommandSubstitutionError struct {
+	msg string
+}
+
+func (e *CommandSubstitutionError) Error() string {
+	return e.msg
+}
+
+type UnsupportedExpansionError struct {
+	msg string
+}
+
+func (e *UnsupportedExpansionError) Error() string {
+	return e.msg
+}
+
+// str 内のシェル変数を、 os の環境変数で展開して返却する。
+// 環境変数 HOME が未定義の場合(Windows など)は、ユーザーのホームディレクトリを使用する。
+func ExpandShellVariables(str string, allowCommandSubstitution bool) (string, error) {
+	return ExpandVariables(str, lookupEnvWithHome, allowCommandSubstitution)
+}
+
+func lookupEnvWithHome(name string) (string, bool) {
+	value, ok := os.LookupEnv(name)
+	if !ok && name == "HOME" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", false
+		}
+		return home, true
+	}
+	return value, ok
+}
+
+// str 内のシェル変数を、 lookupEnv で取得した値で展開して返却する。
+// シェルは起動しないため、 OS によらず同じ結果となる。
+//
+// 対応する記法は以下の通り。
+//
+//   - `$VAR`, `${VAR}`: 環境変数の値(未定義の場合は空文字)
+//   - `${VAR:-DEFAULT}`: 環境変数が未定義または空の場合は DEFAULT (DEFAULT 内も展開する)
+//   - `$(pwd)`: カレントディレクトリ
+//
+// シングルクォート内は展開せず、 `\$` は `$` そのものとして扱う。
+// 展開した値は、後段の shlex.Split でクォートやバックスラッシュが解釈されないようエスケープする。
+// `$(pwd)` 以外のコマンド置換(`$(...)` やバッククォート)は、
+// allowCommandSubstitution が true の場合のみ `sh -c` で実行し、それ以外はエラーとする。
+func ExpandVariables(str string, lookupEnv func(string) (string, bool), allowCommandSubstitution bool) (string, error) {
+	e := expander{
+		lookupEnv:                lookupEnv,
+		allowCommandSubstitution: allowCommandSubstitution,
+	}
+	return e.expand(str, false)
+}
+
+type expander struct {
+	lookupEnv                func(string) (string, bool)
+	allowCommandSubstitution bool
+}
+
+// inDouble は、 str がダブルクォートの内側から始まるかを表す
+func (e expander) expand(str string, inDouble bool) (string, error) {
+	var result strings.Builder
+	inSingle := false
+
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+
+		if inSingle {
+			if c == '\'' {
+				inSingle = false
+			}
+			result.WriteByte(c)
+			continue
+		}
+
+		switch c {
+		case '\'':
+			if !inDouble {
+				inSingle = true
+			}
+			result.WriteByte(c)
+		case '"':
+			inDouble = !inDouble
+			result.WriteByte(c)
+		case '\\':
+			if i+1 >= len(str) {
+				result.WriteByte(c)
+				continue
+			}
+			i++
+			// `\$` とバッククォートのエスケープは展開させないためのものなので、記号そのものにする。
+			// それ以外は shlex.Split に解釈を任せる。
+			if str[i] != '$' && str[i] != '`' {
+				result.WriteByte(c)
+			}
+			result.WriteByte(str[i])
+		case '`':
+			end := strings.IndexByte(str[i+1:], '`')
+			if end < 0 {
+				return "", &UnsupportedExpansionError{msg: fmt.Sprintf("unterminated command substitution in %q.", str)}
+			}
+			value, err := e.substituteCommand(str[i+1 : i+1+end])
+			if err != nil {
+				return "", err
+			}
+			result.WriteString(escapeExpandedValue(value, inDouble))
+			i += end + 1
+		case '$':
+			value, consumed, literal, err := e.expandDollar(str[i:], inDouble)
+			if err != nil {
+				return "", err
+			}
+			if literal {
+				result.WriteString(value)
+			} else {
+				result.WriteString(escapeExpandedValue(value, inDouble))
+			}
+			i += consumed - 1
+		default:
+			result.WriteByte(c)
+		}
+	}
+
+	return result.String(), nil
+}
+
+// `$` から始まる str の先頭を展開する。
+// 展開後の文字列、消費したバイト数、展開後の文字列をエスケープせずに使用するかを返却する。
+func (e expander) expandDollar(str string, inDouble bool) (string, int, bool, error) {
+	if len(str) < 2 {
+		return "$", 1, true, nil
+	}
+
+	switch {
+	case str[1] == '(':
+		end := findClosing(str, 1, '(', ')')
+		if end < 0 {
+			return "", 0, false, &UnsupportedExpansionError{msg: fmt.Sprintf("unterminated command substitution in %q.", str)}
+		}
+		value, err := e.substituteCommand(str[2:end])
+		return value, end + 1, false, err
+	case str[1] == '{':
+		end := findClosing(str, 1, '{', '}')
+		if end < 0 {
+			return "", 0, false, &UnsupportedExpansionError{msg: fmt.Sprintf("unterminated variable expansion in %q.", str)}
+		}
+		value, literal, err := e.expandBraces(str[2:end], inDouble)
+		return value, end + 1, literal, err
+	case isVariableNameStart(str[1]):
+		end := 2
+		for end < len(str) && isVariableNameChar(str[end]) {
+			end++
+		}
+		value, _ := e.lookupEnv(str[1:end])
+		return value, end, false, nil
+	default:
+		// `$1`, `$$` などの特殊パラメーターは展開せずそのまま残す
+		return "$", 1, true, nil
+	}
+}
+
+// `${...}` の中身を展開する
+func (e expander) expandBraces(content string, inDouble bool) (string, bool, error) {
+	end := 0
+	for end < len(content) && isVariableNameChar(content[end]) {
+		end++
+	}
+	name := content[:end]
+	operator := content[end:]
+	if name == "" || !isVariableNameStart(name[0]) {
+		return "", false, &UnsupportedExpansionError{msg: fmt.Sprintf("unsupported variable expansion ${%s}.", content)}
+	}
+
+	value, ok := e.lookupEnv(name)
+	if operator == "" {
+		return value, false, nil
+	}
+	if !strings.HasPrefix(operator, ":-") {
+		return "", false, &UnsupportedExpansionError{msg: fmt.Sprintf("unsupported variable expansion ${%s}. Only ${VAR} and ${VAR:-DEFAULT} are supported.", content)}
+	}
+	if ok && value != "" {
+		return value, false, nil
+	}
+
+	// デフォルト値は記述されたクォートを活かすため、展開結果をエスケープせずに使用する
+	defaultValue, err := e.expand(operator[2:], inDouble)
+	return defaultValue, true, err
+}
+
+func (e expander) substituteCommand(command string) (string, error) {
+	if strings.TrimSpace(command) == "pwd" {
+		return os.Getwd()
+	}
+	if !e.allowCommandSubstitution {
+		return "", &CommandSubstitutionError{msg: fmt.Sprintf("command substitution `%s` is not allowed. Only $(pwd) is supported unless command substitution is enabled.", command)}
+	}
+
+	output, err := runner.Command("sh", "-c", command).Output()
+	if err != nil {
+		return "", fmt.Errorf("command substitution `%s` failed: %w", command, err)
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
+
+// str[start] の開き括弧に対応する閉じ括弧の位置を返却する。見つからない場合は -1 を返却する。
+func findClosing(str string, start int, open byte, close byte) int {
+	depth := 0
+	for i := start; i < len(str); i++ {
+		switch str[i] {
+		case '\\':
+			i++
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// 展開した値を、 shlex.Split が文字どおりに扱うようエスケープする。
+// クォート外の空白は、シェルと同じく単語の区切りとして残す。
+func escapeExpandedValue(value string, inDouble bool) string {
+	var result strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c == '\\' || c == '"' || (!inDouble && c == '\'') {
+			result.WriteByte('\\')
+		}
+		result.WriteByte(c)
+	}
+	return result.String()
+}
+
+func isVariableNameStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isVariableNameChar(c byte) bool {
+	return isVariableNameStart(c) || ('0' <= c && c <= '9')
+}
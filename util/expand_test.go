@@ -0,0 +1,100 @@
+package util
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/anmitsu/go-shlex"
+)
+
+func expandAndSplitForTest(t *testing.T, str string, env map[string]string, allowCommandSubstitution bool) []string {
+	t.Helper()
+
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	expanded, err := ExpandVariables(str, lookupEnv, allowCommandSubstitution)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	splitted, err := shlex.Split(expanded, true)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	return splitted
+}
+
+func TestExpandVariables(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	env := map[string]string{
+		"HOME":   "/home/user",
+		"SPACED": "a b",
+		"QUOTED": `it's "quoted" \ value`,
+		"EMPTY":  "",
+	}
+	tests := []struct {
+		str  string
+		want []string
+	}{
+		{`-v "$HOME/.vim:/root/.vim"`, []string{"-v", "/home/user/.vim:/root/.vim"}},
+		{`-v ${HOME}/.ssh:/root/.ssh`, []string{"-v", "/home/user/.ssh:/root/.ssh"}},
+		{`-v "$(pwd):/work" --workdir /work`, []string{"-v", wd + ":/work", "--workdir", "/work"}},
+		{`${UNDEFINED:-/tmp} ${EMPTY:-"default value"} ${HOME:-/tmp}`, []string{"/tmp", "default value", "/home/user"}},
+		{`${UNDEFINED:-$HOME/work}`, []string{"/home/user/work"}},
+		{`$UNDEFINED -e FOO=bar`, []string{"-e", "FOO=bar"}},
+		{`$SPACED "$SPACED"`, []string{"a", "b", "a b"}},
+		{`$QUOTED`, []string{"it's", `"quoted"`, `\`, "value"}},
+		{`"$QUOTED"`, []string{`it's "quoted" \ value`}},
+		{`'$HOME' "\$HOME" \$HOME`, []string{"$HOME", "$HOME", "$HOME"}},
+		{`-e 'CMD=$(rm -rf /)' $1 $`, []string{"-e", "CMD=$(rm -rf /)", "$1", "$"}},
+		{"a; echo *\nb", []string{"a;", "echo", "*", "b"}},
+	}
+	for _, test := range tests {
+		got := expandAndSplitForTest(t, test.str, env, false)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("error: %q: want %q, but got %q", test.str, test.want, got)
+		}
+	}
+}
+
+func TestExpandVariablesRejectsCommandSubstitution(t *testing.T) {
+	lookupEnv := func(string) (string, bool) { return "", false }
+	for _, str := range []string{`$(whoami)`, "`whoami`", `"$(echo a)"`} {
+		_, err := ExpandVariables(str, lookupEnv, false)
+		var commandSubstitutionError *CommandSubstitutionError
+		if !errors.As(err, &commandSubstitutionError) {
+			t.Errorf("error: %q: want CommandSubstitutionError, but got %v", str, err)
+		}
+	}
+}
+
+func TestExpandVariablesUnsupported(t *testing.T) {
+	lookupEnv := func(string) (string, bool) { return "", false }
+	for _, str := range []string{`${HOME:=x}`, `${#HOME}`, `${HOME`, `$(pwd`} {
+		_, err := ExpandVariables(str, lookupEnv, false)
+		var unsupportedExpansionError *UnsupportedExpansionError
+		if !errors.As(err, &unsupportedExpansionError) {
+			t.Errorf("error: %q: want UnsupportedExpansionError, but got %v", str, err)
+		}
+	}
+}
+
+func TestExpandVariablesAllowCommandSubstitution(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("command substitution requires sh")
+	}
+
+	got := expandAndSplitForTest(t, `-e "NAME=$(echo 'a b')" $(echo c d)`, nil, true)
+	want := []string{"-e", "NAME=a b", "c", "d"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("error: want %q, but got %q", want, got)
+	}
+}
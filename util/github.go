@@ -4,22 +4,109 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/google/go-github/v62/github"
 )
 
+// GitHub API の認証に使用するトークンを読み込む環境変数(先に見つかったものを使用する)
+var GitHubTokenEnvs = []string{"GITHUB_TOKEN", "GH_TOKEN"}
+
+// GitHub API のレートリミットに達した場合のエラー
+type GitHubRateLimitError struct {
+	msg string
+
+	// レートリミットが解除される日時
+	Reset time.Time
+}
+
+func (e *GitHubRateLimitError) Error() string {
+	return e.msg
+}
+
+/**
+ * 環境変数に GitHub のトークンが設定されている場合は、そのトークンで認証するクライアントを返却する。
+ */
+func newGitHubClient() *github.Client {
+	client := github.NewClient(nil)
+	if token := GitHubToken(); token != "" {
+		return client.WithAuthToken(token)
+	}
+	return client
+}
+
+/**
+ * GitHubTokenEnvs の環境変数から、 GitHub API の認証に使用するトークンを返却する。
+ * 設定されていない場合は空文字を返却する。
+ */
+func GitHubToken() string {
+	for _, name := range GitHubTokenEnvs {
+		if token := os.Getenv(name); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
 /**
  * ユーザー名、リポジトリ名から最新リリースタグ名を返却する。
  */
 func GetLatestReleaseFromGitHub(owner string, repository string) (string, error) {
 	ctx := context.Background()
-	client := github.NewClient(nil)
+	client := newGitHubClient()
 
 	release, _, err := client.Repositories.GetLatestRelease(ctx, owner, repository)
 	if err != nil {
+		if rateLimitError := toGitHubRateLimitError(err); rateLimitError != nil {
+			return "", rateLimitError
+		}
 		message := fmt.Sprintf("Error getting latest release: %v", err)
 		return "", errors.New(message)
 	}
 
 	return release.GetTagName(), nil
 }
+
+/**
+ * GitHub API のレートリミットの状態を返却する。
+ */
+func GetGitHubRateLimit() (*github.Rate, error) {
+	ctx := context.Background()
+	client := newGitHubClient()
+
+	rateLimits, _, err := client.RateLimit.Get(ctx)
+	if err != nil {
+		message := fmt.Sprintf("Error getting rate limit: %v", err)
+		return nil, errors.New(message)
+	}
+
+	return rateLimits.GetCore(), nil
+}
+
+/**
+ * err が GitHub API のレートリミットによるものであれば、リセット日時を含む GitHubRateLimitError を返却する。
+ * それ以外の場合は nil を返却する。
+ */
+func toGitHubRateLimitError(err error) *GitHubRateLimitError {
+	var reset time.Time
+	var rateLimitError *github.RateLimitError
+	var abuseRateLimitError *github.AbuseRateLimitError
+	switch {
+	case errors.As(err, &rateLimitError):
+		reset = rateLimitError.Rate.Reset.Time
+	case errors.As(err, &abuseRateLimitError):
+		reset = time.Now().Add(abuseRateLimitError.GetRetryAfter())
+	default:
+		return nil
+	}
+
+	hint := fmt.Sprintf("Set %s or %s to raise the limit, or retry after the reset.", GitHubTokenEnvs[0], GitHubTokenEnvs[1])
+	if GitHubToken() != "" {
+		hint = "Retry after the reset."
+	}
+	return &GitHubRateLimitError{
+		msg:   fmt.Sprintf("GitHub API rate limit exceeded, resets at %s. %s", reset.Local().Format(time.DateTime), hint),
+		Reset: reset,
+	}
+}
@@ -0,0 +1,52 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v62/github"
+)
+
+func TestGitHubToken(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "")
+	if got := GitHubToken(); got != "" {
+		t.Fatalf("error: want empty, but got %q", got)
+	}
+
+	t.Setenv("GH_TOKEN", "gh")
+	if got := GitHubToken(); got != "gh" {
+		t.Fatalf("error: want gh, but got %q", got)
+	}
+
+	t.Setenv("GITHUB_TOKEN", "github")
+	if got := GitHubToken(); got != "github" {
+		t.Fatalf("error: want github, but got %q", got)
+	}
+}
+
+func TestToGitHubRateLimitError(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "")
+
+	reset := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	err := fmt.Errorf("wrapped: %w", &github.RateLimitError{Rate: github.Rate{Reset: github.Timestamp{Time: reset}}})
+
+	rateLimitError := toGitHubRateLimitError(err)
+	if rateLimitError == nil {
+		t.Fatalf("error: want GitHubRateLimitError, but got nil")
+	}
+	if !rateLimitError.Reset.Equal(reset) {
+		t.Errorf("error: want reset %v, but got %v", reset, rateLimitError.Reset)
+	}
+	if !strings.Contains(rateLimitError.Error(), reset.Local().Format(time.DateTime)) || !strings.Contains(rateLimitError.Error(), "GITHUB_TOKEN") {
+		t.Errorf("error: unexpected message %q", rateLimitError.Error())
+	}
+
+	if got := toGitHubRateLimitError(errors.New("not found")); got != nil {
+		t.Errorf("error: want nil, but got %v", got)
+	}
+}
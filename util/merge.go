@@ -0,0 +1,386 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// 配列に対するマージ指示子
+//
+// 追加設定ファイルで配列のキーに以下のキーだけを持つオブジェクトを指定すると、
+// ベース側の配列に対して指示子に従った操作を行う。
+// 複数指定した場合、 $replace → $remove → $prepend → $append の順に適用する。
+//
+// Example:
+//
+//	"mounts": {
+//	  "$remove": [{ "target": "/home/vscode/.ssh" }],
+//	  "$append": [{ "type": "bind", "source": "/tmp", "target": "/tmp" }]
+//	}
+const MergeDirectiveAppend = "$append"
+const MergeDirectivePrepend = "$prepend"
+const MergeDirectiveReplace = "$replace"
+const MergeDirectiveRemove = "$remove"
+
+var mergeDirectives = []string{MergeDirectiveReplace, MergeDirectiveRemove, MergeDirectivePrepend, MergeDirectiveAppend}
+
+// マージによる変更の種類
+const ConfigChangeAdd = "add"
+const ConfigChangeModify = "modify"
+const ConfigChangeRemove = "remove"
+
+type InvalidMergeDirectiveError struct {
+	msg string
+}
+
+func (e *InvalidMergeDirectiveError) Error() string {
+	return e.msg
+}
+
+// マージ対象の設定ファイル
+type ConfigLayer struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// 追加設定ファイルのマージによる変更
+type ConfigChange struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Layer string `json:"layer"`
+	Old   any    `json:"old,omitempty"`
+	New   any    `json:"new,omitempty"`
+}
+
+// 設定ファイルのマージ結果
+type MergedConfig struct {
+	Value   any
+	Changes []ConfigChange
+	origin  *mergeOrigin
+}
+
+// マージ結果の各値がどのレイヤー由来かを保持する
+type mergeOrigin struct {
+	layer    string
+	keys     map[string]*mergeOrigin
+	elements []*mergeOrigin
+}
+
+func newMergeOrigin(value any, layer string) *mergeOrigin {
+	origin := &mergeOrigin{layer: layer}
+	switch v := value.(type) {
+	case map[string]any:
+		origin.keys = map[string]*mergeOrigin{}
+		for key, child := range v {
+			origin.keys[key] = newMergeOrigin(child, layer)
+		}
+	case []any:
+		for _, child := range v {
+			origin.elements = append(origin.elements, newMergeOrigin(child, layer))
+		}
+	}
+	return origin
+}
+
+// 標準 JSON の base に overlay をマージし、その結果を返却する。
+//
+// - オブジェクト同士はキーごとに再帰的にマージする
+// - 配列同士は overlay の要素を末尾に追加する
+// - 配列のマージ指示子が指定された場合、指示子に従って配列を操作する
+// - それ以外(スカラー値や型の異なる値)は overlay の値で上書きする
+func MergeJSON(base []byte, overlay []byte) ([]byte, error) {
+	baseValue, err := decodeJSON(base)
+	if err != nil {
+		return nil, err
+	}
+	overlayValue, err := decodeJSON(overlay)
+	if err != nil {
+		return nil, err
+	}
+
+	merger := &configMerger{}
+	merged, _, err := merger.mergeValue("", baseValue, newMergeOrigin(baseValue, ""), overlayValue, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(merged)
+}
+
+// layers の先頭をベースとして、残りの JWCC ファイルを順にマージする。
+// ベース以外の存在しないファイルは無視する。
+func MergeConfigLayers(layers []ConfigLayer) (*MergedConfig, error) {
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("no configuration layer")
+	}
+
+	baseJSON, err := ParseJwcc(layers[0].Path)
+	if err != nil {
+		return nil, err
+	}
+	value, err := decodeJSON(baseJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", layers[0].Path, err)
+	}
+	origin := newMergeOrigin(value, layers[0].Name)
+
+	merger := &configMerger{}
+	for _, layer := range layers[1:] {
+		if layer.Path == "" || !IsExists(layer.Path) {
+			continue
+		}
+
+		overlayJSON, err := ParseJwcc(layer.Path)
+		if err != nil {
+			return nil, err
+		}
+		overlay, err := decodeJSON(overlayJSON)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", layer.Path, err)
+		}
+
+		value, origin, err = merger.mergeValue("", value, origin, overlay, layer.Name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", layer.Path, err)
+		}
+	}
+
+	return &MergedConfig{Value: value, Changes: merger.changes, origin: origin}, nil
+}
+
+// 数値の表現を保ったまま JSON をデコードする
+func decodeJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// マージ処理中の変更を記録する
+type configMerger struct {
+	changes []ConfigChange
+}
+
+func (m *configMerger) record(op string, path string, layer string, oldValue any, newValue any) {
+	m.changes = append(m.changes, ConfigChange{Op: op, Path: path, Layer: layer, Old: oldValue, New: newValue})
+}
+
+func (m *configMerger) mergeValue(path string, base any, baseOrigin *mergeOrigin, overlay any, layer string) (any, *mergeOrigin, error) {
+	overlayObject, overlayIsObject := overlay.(map[string]any)
+	if overlayIsObject {
+		isDirective, err := isMergeDirective(path, base, overlayObject)
+		if err != nil {
+			return nil, nil, err
+		}
+		if isDirective {
+			return m.applyMergeDirectives(path, base, baseOrigin, overlayObject, layer)
+		}
+
+		baseObject, baseIsObject := base.(map[string]any)
+		if !baseIsObject {
+			// ベース側にオブジェクトが無い場合も、入れ子の指示子は解釈する
+			if base != nil {
+				m.record(ConfigChangeRemove, path, layer, base, nil)
+			}
+			if len(overlayObject) == 0 {
+				m.record(ConfigChangeAdd, path, layer, nil, overlayObject)
+			}
+			baseObject = map[string]any{}
+			baseOrigin = &mergeOrigin{layer: layer, keys: map[string]*mergeOrigin{}}
+		}
+
+		merged := map[string]any{}
+		mergedOrigin := &mergeOrigin{layer: baseOrigin.layer, keys: map[string]*mergeOrigin{}}
+		for key, value := range baseObject {
+			merged[key] = value
+			mergedOrigin.keys[key] = baseOrigin.keys[key]
+		}
+		for _, key := range sortedKeys(overlayObject) {
+			mergedValue, origin, err := m.mergeValue(joinMergePath(path, key), baseObject[key], baseOrigin.keys[key], overlayObject[key], layer)
+			if err != nil {
+				return nil, nil, err
+			}
+			merged[key] = mergedValue
+			mergedOrigin.keys[key] = origin
+		}
+		return merged, mergedOrigin, nil
+	}
+
+	overlayArray, overlayIsArray := overlay.([]any)
+	baseArray, baseIsArray := base.([]any)
+	if overlayIsArray && baseIsArray {
+		merged := append([]any{}, baseArray...)
+		mergedOrigin := &mergeOrigin{layer: baseOrigin.layer, elements: append([]*mergeOrigin{}, baseOrigin.elements...)}
+		merged, mergedOrigin.elements = m.appendElements(path, merged, mergedOrigin.elements, overlayArray, layer)
+		return merged, mergedOrigin, nil
+	}
+
+	if base == nil {
+		m.record(ConfigChangeAdd, path, layer, nil, overlay)
+	} else if !reflect.DeepEqual(base, overlay) {
+		m.record(ConfigChangeModify, path, layer, base, overlay)
+	}
+	return overlay, newMergeOrigin(overlay, layer), nil
+}
+
+// オブジェクトがマージ指示子かを判定する。
+//
+// ベース側が配列の場合は `$` で始まるキーを指示子として扱い、未知の指示子や通常のキーとの混在をエラーとする。
+// それ以外の場合は、すべてのキーが既知の指示子のときのみ指示子として扱う。
+// そのため、 `$schema` などの `$` で始まるキーは通常のキーとしてマージする。
+func isMergeDirective(path string, base any, object map[string]any) (bool, error) {
+	if _, baseIsArray := base.([]any); !baseIsArray {
+		if len(object) == 0 {
+			return false, nil
+		}
+		for key := range object {
+			if !isKnownMergeDirective(key) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+
+	directiveCount := 0
+	for key := range object {
+		if strings.HasPrefix(key, "$") {
+			if !isKnownMergeDirective(key) {
+				return false, &InvalidMergeDirectiveError{msg: fmt.Sprintf("%s: unknown merge directive `%s`. use one of %s.", path, key, strings.Join(mergeDirectives, ", "))}
+			}
+			directiveCount++
+		}
+	}
+	if directiveCount > 0 && directiveCount != len(object) {
+		return false, &InvalidMergeDirectiveError{msg: fmt.Sprintf("%s: merge directives cannot be mixed with other keys.", path)}
+	}
+	return directiveCount > 0, nil
+}
+
+func isKnownMergeDirective(key string) bool {
+	for _, directive := range mergeDirectives {
+		if key == directive {
+			return true
+		}
+	}
+	return false
+}
+
+// ベース側の配列にマージ指示子を適用する
+func (m *configMerger) applyMergeDirectives(path string, base any, baseOrigin *mergeOrigin, directives map[string]any, layer string) (any, *mergeOrigin, error) {
+	result := []any{}
+	origins := []*mergeOrigin{}
+	if base != nil {
+		baseArray, ok := base.([]any)
+		if !ok {
+			return nil, nil, &InvalidMergeDirectiveError{msg: fmt.Sprintf("%s: merge directives can only be applied to arrays.", path)}
+		}
+		result = append(result, baseArray...)
+		origins = append(origins, baseOrigin.elements...)
+	}
+
+	for _, directive := range mergeDirectives {
+		value, ok := directives[directive]
+		if !ok {
+			continue
+		}
+		elements, ok := value.([]any)
+		if !ok {
+			return nil, nil, &InvalidMergeDirectiveError{msg: fmt.Sprintf("%s: value of `%s` must be an array.", path, directive)}
+		}
+
+		switch directive {
+		case MergeDirectiveReplace:
+			for i, element := range result {
+				m.record(ConfigChangeRemove, indexMergePath(path, i), layer, element, nil)
+			}
+			result, origins = m.appendElements(path, []any{}, []*mergeOrigin{}, elements, layer)
+		case MergeDirectiveRemove:
+			keptResult := []any{}
+			keptOrigins := []*mergeOrigin{}
+			for i, element := range result {
+				if matchAnyElement(elements, element) {
+					m.record(ConfigChangeRemove, indexMergePath(path, i), layer, element, nil)
+					continue
+				}
+				keptResult = append(keptResult, element)
+				keptOrigins = append(keptOrigins, origins[i])
+			}
+			result = keptResult
+			origins = keptOrigins
+		case MergeDirectivePrepend:
+			prependedResult, prependedOrigins := m.appendElements(path, []any{}, []*mergeOrigin{}, elements, layer)
+			result = append(prependedResult, result...)
+			origins = append(prependedOrigins, origins...)
+		case MergeDirectiveAppend:
+			result, origins = m.appendElements(path, result, origins, elements, layer)
+		}
+	}
+
+	return result, &mergeOrigin{layer: layer, elements: origins}, nil
+}
+
+// 配列の末尾に要素を追加し、変更として記録する
+func (m *configMerger) appendElements(path string, result []any, origins []*mergeOrigin, elements []any, layer string) ([]any, []*mergeOrigin) {
+	for _, element := range elements {
+		m.record(ConfigChangeAdd, indexMergePath(path, len(result)), layer, nil, element)
+		result = append(result, element)
+		origins = append(origins, newMergeOrigin(element, layer))
+	}
+	return result, origins
+}
+
+// patterns のいずれかが element にマッチするかを判定する
+func matchAnyElement(patterns []any, element any) bool {
+	for _, pattern := range patterns {
+		if matchElement(pattern, element) {
+			return true
+		}
+	}
+	return false
+}
+
+// pattern が element にマッチするかを判定する。
+// オブジェクトの場合は pattern に含まれるキーの値がすべて一致すればマッチとみなし、
+// それ以外は値が完全に一致した場合にマッチとみなす。
+func matchElement(pattern any, element any) bool {
+	patternObject, patternIsObject := pattern.(map[string]any)
+	elementObject, elementIsObject := element.(map[string]any)
+	if patternIsObject && elementIsObject {
+		for key, patternValue := range patternObject {
+			elementValue, ok := elementObject[key]
+			if !ok || !matchElement(patternValue, elementValue) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(pattern, element)
+}
+
+func joinMergePath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func indexMergePath(path string, index int) string {
+	return fmt.Sprintf("%s[%d]", path, index)
+}
+
+func sortedKeys(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
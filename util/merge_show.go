@@ -0,0 +1,157 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// マージ結果のキー(配列要素は `key[index]`)ごとに、値の由来となったレイヤー名を返却する。
+// オブジェクトは子のキーごとに、配列は要素ごとに由来を返却する。
+func (m *MergedConfig) Provenance() map[string]string {
+	provenance := map[string]string{}
+	collectProvenance(provenance, "", m.Value, m.origin)
+	return provenance
+}
+
+func collectProvenance(provenance map[string]string, path string, value any, origin *mergeOrigin) {
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) == 0 && path != "" {
+			provenance[path] = origin.layer
+		}
+		for key, child := range v {
+			collectProvenance(provenance, joinMergePath(path, key), child, origin.keys[key])
+		}
+	case []any:
+		if len(v) == 0 {
+			provenance[path] = origin.layer
+		}
+		for i := range v {
+			provenance[indexMergePath(path, i)] = origin.elements[i].layer
+		}
+	default:
+		provenance[path] = origin.layer
+	}
+}
+
+// マージ結果を、値ごとに由来となったレイヤー名をコメントで付与した JWCC として書き出す。
+// キーはソートして出力し、配列の要素は 1 行に 1 要素を出力する。
+func (m *MergedConfig) WriteAnnotated(w io.Writer) error {
+	builder := &strings.Builder{}
+	err := writeAnnotatedValue(builder, "", m.Value, m.origin, "", true)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, builder.String())
+	return err
+}
+
+func writeAnnotatedValue(builder *strings.Builder, indent string, value any, origin *mergeOrigin, separator string, root bool) error {
+	childIndent := indent + "  "
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) > 0 {
+			builder.WriteString("{\n")
+			keys := sortedKeys(v)
+			for i, key := range keys {
+				keyJSON, err := marshalCompactJSON(key)
+				if err != nil {
+					return err
+				}
+				builder.WriteString(childIndent + keyJSON + ": ")
+				err = writeAnnotatedValue(builder, childIndent, v[key], origin.keys[key], trailingComma(i, len(keys)), false)
+				if err != nil {
+					return err
+				}
+			}
+			builder.WriteString(indent + "}" + separator + "\n")
+			return nil
+		}
+	case []any:
+		if len(v) > 0 {
+			builder.WriteString("[\n")
+			for i, element := range v {
+				elementJSON, err := marshalCompactJSON(element)
+				if err != nil {
+					return err
+				}
+				builder.WriteString(fmt.Sprintf("%s%s%s // %s\n", childIndent, elementJSON, trailingComma(i, len(v)), origin.elements[i].layer))
+			}
+			builder.WriteString(indent + "]" + separator + "\n")
+			return nil
+		}
+	}
+
+	valueJSON, err := marshalCompactJSON(value)
+	if err != nil {
+		return err
+	}
+	if root {
+		builder.WriteString(valueJSON + "\n")
+		return nil
+	}
+	builder.WriteString(fmt.Sprintf("%s%s // %s\n", valueJSON, separator, origin.layer))
+	return nil
+}
+
+func trailingComma(index int, length int) string {
+	if index < length-1 {
+		return ","
+	}
+	return ""
+}
+
+// マージ時に追加設定ファイルが行った変更を、 1 行 1 変更で書き出す。
+//
+// - `+ path = value  (layer)`: 追加
+// - `~ path = old -> new  (layer)`: 上書き
+// - `- path = value  (layer)`: 削除
+func (m *MergedConfig) WriteDiff(w io.Writer) error {
+	for _, change := range m.Changes {
+		var line string
+		switch change.Op {
+		case ConfigChangeAdd:
+			newJSON, err := marshalCompactJSON(change.New)
+			if err != nil {
+				return err
+			}
+			line = fmt.Sprintf("+ %s = %s", change.Path, newJSON)
+		case ConfigChangeModify:
+			oldJSON, err := marshalCompactJSON(change.Old)
+			if err != nil {
+				return err
+			}
+			newJSON, err := marshalCompactJSON(change.New)
+			if err != nil {
+				return err
+			}
+			line = fmt.Sprintf("~ %s = %s -> %s", change.Path, oldJSON, newJSON)
+		case ConfigChangeRemove:
+			oldJSON, err := marshalCompactJSON(change.Old)
+			if err != nil {
+				return err
+			}
+			line = fmt.Sprintf("- %s = %s", change.Path, oldJSON)
+		}
+		_, err := fmt.Fprintf(w, "%s  (%s)\n", line, change.Layer)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HTML エスケープをせずに、 1 行の JSON へ変換する
+func marshalCompactJSON(value any) (string, error) {
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(value)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buffer.String(), "\n"), nil
+}
@@ -0,0 +1,310 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tailscale/hujson"
+)
+
+func mergeForTest(t *testing.T, base string, overlay string) map[string]any {
+	t.Helper()
+
+	merged, err := MergeJSON([]byte(base), []byte(overlay))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	var result map[string]any
+	err = json.Unmarshal(merged, &result)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	return result
+}
+
+func assertMerged(t *testing.T, got map[string]any, want string) {
+	t.Helper()
+
+	var wantValue map[string]any
+	err := json.Unmarshal([]byte(want), &wantValue)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if !reflect.DeepEqual(got, wantValue) {
+		gotJSON, _ := json.Marshal(got)
+		t.Fatalf("want %s, got %s", want, gotJSON)
+	}
+}
+
+func TestMergeJSONOverridesScalar(t *testing.T) {
+	got := mergeForTest(t,
+		`{"name": "base", "remoteUser": "root", "port": 1}`,
+		`{"remoteUser": "vscode", "port": 2}`)
+	assertMerged(t, got, `{"name": "base", "remoteUser": "vscode", "port": 2}`)
+}
+
+func TestMergeJSONMergesObjectsDeeply(t *testing.T) {
+	got := mergeForTest(t,
+		`{"remoteEnv": {"A": "1", "B": "2"}, "features": {"x": {"version": "1"}}}`,
+		`{"remoteEnv": {"B": "3", "C": "4"}, "features": {"x": {"extra": true}}}`)
+	assertMerged(t, got, `{"remoteEnv": {"A": "1", "B": "3", "C": "4"}, "features": {"x": {"version": "1", "extra": true}}}`)
+}
+
+func TestMergeJSONAppendsArraysByDefault(t *testing.T) {
+	got := mergeForTest(t,
+		`{"runArgs": ["--a"]}`,
+		`{"runArgs": ["--b"]}`)
+	assertMerged(t, got, `{"runArgs": ["--a", "--b"]}`)
+}
+
+func TestMergeJSONArrayDirectives(t *testing.T) {
+	tests := []struct {
+		name    string
+		overlay string
+		want    string
+	}{
+		{
+			name:    "append",
+			overlay: `{"runArgs": {"$append": ["--c"]}}`,
+			want:    `{"runArgs": ["--a", "--b", "--c"]}`,
+		},
+		{
+			name:    "prepend",
+			overlay: `{"runArgs": {"$prepend": ["--c"]}}`,
+			want:    `{"runArgs": ["--c", "--a", "--b"]}`,
+		},
+		{
+			name:    "replace",
+			overlay: `{"runArgs": {"$replace": ["--c"]}}`,
+			want:    `{"runArgs": ["--c"]}`,
+		},
+		{
+			name:    "remove",
+			overlay: `{"runArgs": {"$remove": ["--a"]}}`,
+			want:    `{"runArgs": ["--b"]}`,
+		},
+		{
+			name:    "remove then append",
+			overlay: `{"runArgs": {"$append": ["--a"], "$remove": ["--a"]}}`,
+			want:    `{"runArgs": ["--b", "--a"]}`,
+		},
+		{
+			name:    "directive for missing key",
+			overlay: `{"appPort": {"$append": [8080]}}`,
+			want:    `{"runArgs": ["--a", "--b"], "appPort": [8080]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeForTest(t, `{"runArgs": ["--a", "--b"]}`, tt.overlay)
+			assertMerged(t, got, tt.want)
+		})
+	}
+}
+
+func TestMergeJSONRemovesMountByPartialMatch(t *testing.T) {
+	got := mergeForTest(t,
+		`{"mounts": [
+			{"type": "bind", "source": "/home/user/.vim", "target": "/home/vscode/.vim"},
+			{"type": "bind", "source": "/home/user/.ssh", "target": "/home/vscode/.ssh"},
+			"source=/tmp,target=/tmp,type=bind"
+		]}`,
+		`{"mounts": {"$remove": [{"target": "/home/vscode/.ssh"}, "source=/tmp,target=/tmp,type=bind"]}}`)
+	assertMerged(t, got, `{"mounts": [{"type": "bind", "source": "/home/user/.vim", "target": "/home/vscode/.vim"}]}`)
+}
+
+func TestMergeJSONKeepsNumberRepresentation(t *testing.T) {
+	merged, err := MergeJSON([]byte(`{"a": 12345678901234567890}`), []byte(`{"b": 1.50}`))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := `{"a":12345678901234567890,"b":1.50}`
+	if string(merged) != want {
+		t.Fatalf("want %s, got %s", want, merged)
+	}
+}
+
+func TestMergeJSONRejectsInvalidDirectives(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		overlay string
+	}{
+		{name: "unknown directive", base: `{"runArgs": []}`, overlay: `{"runArgs": {"$insert": ["--a"]}}`},
+		{name: "mixed with other keys", base: `{"runArgs": []}`, overlay: `{"runArgs": {"$append": ["--a"], "other": 1}}`},
+		{name: "not an array value", base: `{"runArgs": []}`, overlay: `{"runArgs": {"$append": "--a"}}`},
+		{name: "not an array base", base: `{"remoteUser": "root"}`, overlay: `{"remoteUser": {"$append": ["vscode"]}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MergeJSON([]byte(tt.base), []byte(tt.overlay))
+			var invalidMergeDirectiveError *InvalidMergeDirectiveError
+			if !errors.As(err, &invalidMergeDirectiveError) {
+				t.Fatalf("want InvalidMergeDirectiveError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMergeJSONKeepsDollarKeysOutsideArrays(t *testing.T) {
+	got := mergeForTest(t,
+		`{"$schema": "https://example.com/base.json", "name": "base", "customizations": {"vscode": {}}}`,
+		`{"$schema": "https://example.com/overlay.json", "customizations": {"$comment": "overlay"}}`)
+	assertMerged(t, got, `{"$schema": "https://example.com/overlay.json", "name": "base", "customizations": {"vscode": {}, "$comment": "overlay"}}`)
+
+	// ベース側に存在しないキーでも、既知の指示子のみであれば配列として解釈する
+	got = mergeForTest(t, `{}`, `{"runArgs": {"$append": ["--a"]}}`)
+	assertMerged(t, got, `{"runArgs": ["--a"]}`)
+}
+
+func TestCreateConfigFileForDevcontainerMergesOverlaysInOrder(t *testing.T) {
+	dir := t.TempDir()
+	baseConfigFilePath := filepath.Join(dir, "devcontainer.json")
+	userConfigFilePath := filepath.Join(dir, "user.vim.json")
+	projectConfigFilePath := filepath.Join(dir, "devcontainer.vim.json")
+	files := map[string]string{
+		baseConfigFilePath:    `{"name": "base", "remoteUser": "root", "mounts": ["base"]}`,
+		userConfigFilePath:    `{"remoteUser": "user", "mounts": ["user"]}`,
+		projectConfigFilePath: `{"remoteUser": "project", /* JWCC */ "mounts": {"$remove": ["base"], "$append": ["project"]},}`,
+	}
+	for path, content := range files {
+		err := os.WriteFile(path, []byte(content), 0666)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+
+	// 存在しない追加設定ファイルは無視される
+	mergedConfigFilePath, err := CreateConfigFileForDevcontainer(filepath.Join(dir, "config"), dir, baseConfigFilePath, userConfigFilePath, filepath.Join(dir, "missing.json"), projectConfigFilePath)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	merged, err := os.ReadFile(mergedConfigFilePath)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	var got map[string]any
+	err = json.Unmarshal(merged, &got)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	assertMerged(t, got, `{"name": "base", "remoteUser": "project", "mounts": ["user", "project"]}`)
+}
+
+func mergeConfigLayersForTest(t *testing.T, contents ...string) *MergedConfig {
+	t.Helper()
+
+	dir := t.TempDir()
+	layerNames := []string{"base", "user", "project"}
+	layers := []ConfigLayer{}
+	for i, content := range contents {
+		path := filepath.Join(dir, layerNames[i]+".json")
+		err := os.WriteFile(path, []byte(content), 0666)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		layers = append(layers, ConfigLayer{Name: layerNames[i], Path: path})
+	}
+
+	mergedConfig, err := MergeConfigLayers(layers)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	return mergedConfig
+}
+
+func TestMergeConfigLayersProvenance(t *testing.T) {
+	mergedConfig := mergeConfigLayersForTest(t,
+		`{"name": "base", "remoteUser": "root", "remoteEnv": {"A": "1"}, "runArgs": ["--a"]}`,
+		`{"remoteUser": "user", "runArgs": ["--b"]}`,
+		`{"remoteEnv": {"B": "2"}, "runArgs": {"$prepend": ["--c"]}}`)
+
+	want := map[string]string{
+		"name":        "base",
+		"remoteUser":  "user",
+		"remoteEnv.A": "base",
+		"remoteEnv.B": "project",
+		"runArgs[0]":  "project",
+		"runArgs[1]":  "base",
+		"runArgs[2]":  "user",
+	}
+	if got := mergedConfig.Provenance(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+}
+
+func TestMergeConfigLayersWriteDiff(t *testing.T) {
+	mergedConfig := mergeConfigLayersForTest(t,
+		`{"remoteUser": "root", "mounts": ["a", "b"]}`,
+		`{"remoteUser": "vscode", "remoteEnv": {"A": "<1>"}}`,
+		`{"mounts": {"$remove": ["a"]}}`)
+
+	buffer := &bytes.Buffer{}
+	err := mergedConfig.WriteDiff(buffer)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := `+ remoteEnv.A = "<1>"  (user)
+~ remoteUser = "root" -> "vscode"  (user)
+- mounts[0] = "a"  (project)
+`
+	if buffer.String() != want {
+		t.Fatalf("want %q, got %q", want, buffer.String())
+	}
+}
+
+func TestMergeConfigLayersWriteAnnotated(t *testing.T) {
+	mergedConfig := mergeConfigLayersForTest(t,
+		`{"name": "base", "runArgs": ["--a"], "remoteEnv": {}}`,
+		`{"runArgs": ["--b"]}`)
+
+	buffer := &bytes.Buffer{}
+	err := mergedConfig.WriteAnnotated(buffer)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	want := `{
+  "name": "base", // base
+  "remoteEnv": {}, // base
+  "runArgs": [
+    "--a", // base
+    "--b" // user
+  ]
+}
+`
+	if buffer.String() != want {
+		t.Fatalf("want %q, got %q", want, buffer.String())
+	}
+
+	// 出力は JWCC として読み込める
+	_, err = hujson.Parse(buffer.Bytes())
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+}
+
+func TestMergeConfigLayersSkipsMissingOverlay(t *testing.T) {
+	dir := t.TempDir()
+	basePath := filepath.Join(dir, "devcontainer.json")
+	err := os.WriteFile(basePath, []byte(`{"name": "base"}`), 0666)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	mergedConfig, err := MergeConfigLayers([]ConfigLayer{{Name: "base", Path: basePath}, {Name: "user", Path: filepath.Join(dir, "missing.json")}})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(mergedConfig.Changes) != 0 {
+		t.Fatalf("want no changes, got %v", mergedConfig.Changes)
+	}
+}
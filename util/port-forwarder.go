@@ -34,6 +34,11 @@ func StartForwarding(listenAddr, forwardAddr string) error {
 	if err != nil {
 		return err
 	}
+	return ServeForwarding(listener, forwardAddr)
+}
+
+// 生成済みの listener で待ち受け、 forwardAddr へのポートフォワーディングを行う
+func ServeForwarding(listener net.Listener, forwardAddr string) error {
 	defer listener.Close()
 
 	for {
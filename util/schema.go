@@ -0,0 +1,423 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tailscale/hujson"
+)
+
+// JSON Schema のうち、設定ファイルの検証に必要なサブセット。
+//
+// 対応しているキーワードは type, properties, additionalProperties,
+// items, minItems, enum, oneOf のみ。
+type JSONSchema struct {
+	Type                 any                    `json:"type"`
+	Description          string                 `json:"description"`
+	Properties           map[string]*JSONSchema `json:"properties"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties"`
+	Items                *JSONSchema            `json:"items"`
+	MinItems             int                    `json:"minItems"`
+	Enum                 []any                  `json:"enum"`
+	OneOf                []*JSONSchema          `json:"oneOf"`
+
+	// additionalProperties に false が指定された場合に true
+	never bool
+}
+
+// additionalProperties などで、スキーマの代わりに真偽値を受け付ける
+func (s *JSONSchema) UnmarshalJSON(data []byte) error {
+	var boolean bool
+	if json.Unmarshal(data, &boolean) == nil {
+		*s = JSONSchema{never: !boolean}
+		return nil
+	}
+
+	type rawJSONSchema JSONSchema
+	var raw rawJSONSchema
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	*s = JSONSchema(raw)
+	return nil
+}
+
+// JSON Schema を読み込む
+func ParseJSONSchema(data []byte) (*JSONSchema, error) {
+	var schema JSONSchema
+	err := json.Unmarshal(data, &schema)
+	if err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// 検証で見つかった問題の重大度
+const SeverityError = "error"
+const SeverityWarning = "warning"
+
+// 設定ファイルの検証で見つかった問題
+type ValidationProblem struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Path   string `json:"path,omitempty"`
+	// SeverityError または SeverityWarning。
+	// 未知のキーは devcontainer CLI が無視するため、 SeverityWarning とする
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (p ValidationProblem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", p.File, p.Severity, p.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", p.File, p.Line, p.Column, p.Severity, p.Message)
+}
+
+// problems のうち、重大度が SeverityError のものの数を返却する
+func CountErrors(problems []ValidationProblem) int {
+	count := 0
+	for _, problem := range problems {
+		if problem.Severity == SeverityError {
+			count++
+		}
+	}
+	return count
+}
+
+// JWCC ファイルを schema で検証し、見つかった問題を返却する。
+// allowMergeDirectives が true の場合、配列を期待する箇所でマージ指示子を受け付ける。
+// 構文エラーも問題として返却し、ファイルを読み込めない場合のみエラーを返却する。
+func ValidateJwcc(jwccPath string, schema *JSONSchema, allowMergeDirectives bool) ([]ValidationProblem, error) {
+	content, err := os.ReadFile(jwccPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// 起動時と同じく ParseJwcc で読み込めることを確認する
+	_, err = ParseJwcc(jwccPath)
+	if err != nil {
+		return []ValidationProblem{newSyntaxProblem(jwccPath, err)}, nil
+	}
+
+	// 位置情報を得るため、 AST を取得する
+	value, err := hujson.Parse(content)
+	if err != nil {
+		return []ValidationProblem{newSyntaxProblem(jwccPath, err)}, nil
+	}
+
+	validator := &schemaValidator{file: jwccPath, content: content, allowMergeDirectives: allowMergeDirectives}
+	validator.validate("", &value, schema)
+	return validator.problems, nil
+}
+
+var hujsonErrorPattern = regexp.MustCompile(`^hujson: line (\d+), column (\d+): (.*)$`)
+
+// hujson の構文エラーから、行・列を取り出して問題に変換する
+func newSyntaxProblem(jwccPath string, err error) ValidationProblem {
+	problem := ValidationProblem{File: jwccPath, Severity: SeverityError, Message: err.Error()}
+	matches := hujsonErrorPattern.FindStringSubmatch(err.Error())
+	if matches != nil {
+		problem.Line, _ = strconv.Atoi(matches[1])
+		problem.Column, _ = strconv.Atoi(matches[2])
+		problem.Message = matches[3]
+	}
+	return problem
+}
+
+type schemaValidator struct {
+	file                 string
+	content              []byte
+	allowMergeDirectives bool
+	problems             []ValidationProblem
+}
+
+func (v *schemaValidator) report(path string, value *hujson.Value, format string, args ...any) {
+	v.reportWithSeverity(SeverityError, path, value, format, args...)
+}
+
+func (v *schemaValidator) warn(path string, value *hujson.Value, format string, args ...any) {
+	v.reportWithSeverity(SeverityWarning, path, value, format, args...)
+}
+
+func (v *schemaValidator) reportWithSeverity(severity string, path string, value *hujson.Value, format string, args ...any) {
+	line, column := offsetToLineColumn(v.content, value.StartOffset)
+	v.problems = append(v.problems, ValidationProblem{
+		File:     v.file,
+		Line:     line,
+		Column:   column,
+		Path:     path,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *schemaValidator) validate(path string, value *hujson.Value, schema *JSONSchema) {
+	if schema == nil {
+		return
+	}
+	if schema.never {
+		v.report(path, value, "%s is not allowed", describePath(path))
+		return
+	}
+
+	// oneOf は、型が一致する最初の候補で検証する
+	if len(schema.OneOf) > 0 {
+		for _, candidate := range schema.OneOf {
+			if matchSchemaType(value, candidate) || v.isMergeDirectiveFor(value, candidate) {
+				v.validate(path, value, candidate)
+				return
+			}
+		}
+		v.report(path, value, "%s must be %s, got %s", describePath(path), describeSchemaTypes(schema.OneOf), valueType(value))
+		return
+	}
+
+	if v.isMergeDirectiveFor(value, schema) {
+		v.validateMergeDirective(path, value, schema)
+		return
+	}
+
+	if !matchSchemaType(value, schema) {
+		v.report(path, value, "%s must be %s, got %s", describePath(path), describeSchemaTypes([]*JSONSchema{schema}), valueType(value))
+		return
+	}
+
+	if len(schema.Enum) > 0 && !matchEnum(value, schema.Enum) {
+		v.report(path, value, "%s must be one of %s", describePath(path), describeEnum(schema.Enum))
+		return
+	}
+
+	switch trimmed := value.Value.(type) {
+	case *hujson.Object:
+		for i := range trimmed.Members {
+			member := &trimmed.Members[i]
+			key := member.Name.Value.(hujson.Literal).String()
+			memberPath := joinMergePath(path, key)
+			propertySchema, ok := schema.Properties[key]
+			if !ok {
+				if schema.AdditionalProperties != nil && schema.AdditionalProperties.never {
+					v.warn(memberPath, &member.Name, "unknown key %q%s", key, suggestKey(key, schema.Properties))
+					continue
+				}
+				propertySchema = schema.AdditionalProperties
+			}
+			v.validate(memberPath, &member.Value, propertySchema)
+		}
+	case *hujson.Array:
+		if len(trimmed.Elements) < schema.MinItems {
+			v.report(path, value, "%s must have at least %d item(s)", describePath(path), schema.MinItems)
+		}
+		for i := range trimmed.Elements {
+			v.validate(indexMergePath(path, i), &trimmed.Elements[i], schema.Items)
+		}
+	}
+}
+
+// 配列を期待する箇所に、マージ指示子のオブジェクトが指定されているかを判定する
+func (v *schemaValidator) isMergeDirectiveFor(value *hujson.Value, schema *JSONSchema) bool {
+	if !v.allowMergeDirectives || !schemaAllowsType(schema, "array") {
+		return false
+	}
+	object, ok := value.Value.(*hujson.Object)
+	if !ok || len(object.Members) == 0 {
+		return false
+	}
+	for _, member := range object.Members {
+		if !strings.HasPrefix(member.Name.Value.(hujson.Literal).String(), "$") {
+			return false
+		}
+	}
+	return true
+}
+
+// マージ指示子の各値を、配列として検証する
+func (v *schemaValidator) validateMergeDirective(path string, value *hujson.Value, schema *JSONSchema) {
+	object := value.Value.(*hujson.Object)
+	arraySchema := *schema
+	arraySchema.Type = "array"
+	arraySchema.MinItems = 0
+	for i := range object.Members {
+		member := &object.Members[i]
+		directive := member.Name.Value.(hujson.Literal).String()
+		if !isKnownMergeDirective(directive) {
+			v.report(joinMergePath(path, directive), &member.Name, "unknown merge directive %q. use one of %s", directive, strings.Join(mergeDirectives, ", "))
+			continue
+		}
+		v.validate(joinMergePath(path, directive), &member.Value, &arraySchema)
+	}
+}
+
+func schemaTypes(schema *JSONSchema) []string {
+	switch t := schema.Type.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		types := []string{}
+		for _, element := range t {
+			if s, ok := element.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func schemaAllowsType(schema *JSONSchema, typeName string) bool {
+	if len(schema.OneOf) > 0 {
+		for _, candidate := range schema.OneOf {
+			if schemaAllowsType(candidate, typeName) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, t := range schemaTypes(schema) {
+		if t == typeName {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSchemaType(value *hujson.Value, schema *JSONSchema) bool {
+	types := schemaTypes(schema)
+	if len(types) == 0 {
+		return true
+	}
+	actual := valueType(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// JSON Schema の型名で、値の型を返却する
+func valueType(value *hujson.Value) string {
+	switch value.Value.Kind() {
+	case 'n':
+		return "null"
+	case 't', 'f':
+		return "boolean"
+	case '"':
+		return "string"
+	case '0':
+		literal := value.Value.(hujson.Literal)
+		if bytes.ContainsAny(literal, ".eE") {
+			return "number"
+		}
+		return "integer"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	}
+	return "unknown"
+}
+
+func matchEnum(value *hujson.Value, enum []any) bool {
+	literal, ok := value.Value.(hujson.Literal)
+	if !ok {
+		return false
+	}
+	var actual any
+	if json.Unmarshal(literal, &actual) != nil {
+		return false
+	}
+	for _, candidate := range enum {
+		if candidate == actual {
+			return true
+		}
+	}
+	return false
+}
+
+func describePath(path string) string {
+	if path == "" {
+		return "root"
+	}
+	return fmt.Sprintf("%q", path)
+}
+
+func describeSchemaTypes(schemas []*JSONSchema) string {
+	types := []string{}
+	for _, schema := range schemas {
+		for _, t := range schemaTypes(schema) {
+			if t == "array" && schema.Items != nil && len(schemaTypes(schema.Items)) > 0 {
+				t = fmt.Sprintf("array of %s", strings.Join(schemaTypes(schema.Items), " or "))
+			}
+			types = append(types, t)
+		}
+	}
+	return strings.Join(types, " or ")
+}
+
+func describeEnum(enum []any) string {
+	values := []string{}
+	for _, value := range enum {
+		valueJSON, _ := json.Marshal(value)
+		values = append(values, string(valueJSON))
+	}
+	return strings.Join(values, ", ")
+}
+
+// 未知のキーに対し、似た名前の既知のキーを提案する
+func suggestKey(key string, properties map[string]*JSONSchema) string {
+	candidates := make([]string, 0, len(properties))
+	for candidate := range properties {
+		candidates = append(candidates, candidate)
+	}
+	sort.Strings(candidates)
+
+	best := ""
+	bestDistance := 3
+	for _, candidate := range candidates {
+		distance := levenshtein(strings.ToLower(key), strings.ToLower(candidate))
+		if distance < bestDistance {
+			best = candidate
+			bestDistance = distance
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+func levenshtein(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// バイトオフセットを 1 始まりの行・列に変換する
+func offsetToLineColumn(content []byte, offset int) (int, int) {
+	line := 1 + bytes.Count(content[:offset], []byte("\n"))
+	column := 1 + offset - (bytes.LastIndexByte(content[:offset], '\n') + 1)
+	return line, column
+}
@@ -0,0 +1,145 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testSchema = `{
+  "type": "object",
+  "properties": {
+    "name": { "type": "string" },
+    "runArgs": { "type": "array", "items": { "type": "string" } },
+    "dockerComposeFile": {
+      "oneOf": [
+        { "type": "string" },
+        { "type": "array", "items": { "type": "string" }, "minItems": 1 }
+      ]
+    },
+    "shutdownAction": { "type": "string", "enum": ["none", "stopContainer"] },
+    "remoteEnv": { "type": "object", "additionalProperties": { "type": "string" } }
+  },
+  "additionalProperties": false
+}`
+
+func validateForTest(t *testing.T, content string, allowMergeDirectives bool) []ValidationProblem {
+	t.Helper()
+
+	schema, err := ParseJSONSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "devcontainer.json")
+	err = os.WriteFile(path, []byte(content), 0666)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	problems, err := ValidateJwcc(path, schema, allowMergeDirectives)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	return problems
+}
+
+func assertProblem(t *testing.T, problems []ValidationProblem, line int, column int, message string) {
+	t.Helper()
+
+	if len(problems) != 1 {
+		t.Fatalf("want 1 problem, got %v", problems)
+	}
+	if problems[0].Line != line || problems[0].Column != column || problems[0].Message != message {
+		t.Fatalf("want %d:%d: %s, got %d:%d: %s", line, column, message, problems[0].Line, problems[0].Column, problems[0].Message)
+	}
+}
+
+func TestValidateJwccAcceptsValidConfig(t *testing.T) {
+	problems := validateForTest(t, `{
+		// comment
+		"name": "test",
+		"runArgs": ["--init"],
+		"dockerComposeFile": ["docker-compose.yaml"],
+		"remoteEnv": {"A": "1"},
+	}`, false)
+	if len(problems) != 0 {
+		t.Fatalf("want no problems, got %v", problems)
+	}
+}
+
+func TestValidateJwccReportsUnknownKey(t *testing.T) {
+	problems := validateForTest(t, "{\n  \"nmae\": \"test\"\n}", false)
+	assertProblem(t, problems, 2, 3, `unknown key "nmae" (did you mean "name"?)`)
+	// 未知のキーは devcontainer CLI が無視するため、警告とする
+	if problems[0].Severity != SeverityWarning || CountErrors(problems) != 0 {
+		t.Fatalf("want warning, got %s", problems[0].Severity)
+	}
+}
+
+func TestValidateJwccReportsWrongType(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		message string
+	}{
+		{
+			name:    "scalar",
+			content: `{"dockerComposeFile": 1}`,
+			message: `"dockerComposeFile" must be string or array of string, got integer`,
+		},
+		{
+			name:    "array element",
+			content: `{"dockerComposeFile": [1]}`,
+			message: `"dockerComposeFile[0]" must be string, got integer`,
+		},
+		{
+			name:    "empty array",
+			content: `{"dockerComposeFile": []}`,
+			message: `"dockerComposeFile" must have at least 1 item(s)`,
+		},
+		{
+			name:    "enum",
+			content: `{"shutdownAction": "stop"}`,
+			message: `"shutdownAction" must be one of "none", "stopContainer"`,
+		},
+		{
+			name:    "additional properties",
+			content: `{"remoteEnv": {"A": true}}`,
+			message: `"remoteEnv.A" must be string, got boolean`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := validateForTest(t, tt.content, false)
+			if len(problems) != 1 || problems[0].Message != tt.message {
+				t.Fatalf("want %s, got %v", tt.message, problems)
+			}
+			if problems[0].Severity != SeverityError {
+				t.Fatalf("want error, got %s", problems[0].Severity)
+			}
+		})
+	}
+}
+
+func TestValidateJwccMergeDirectives(t *testing.T) {
+	content := `{"runArgs": {"$append": ["--init"], "$remove": ["--privileged"]}}`
+
+	// 追加設定ファイルでは、マージ指示子を受け付ける
+	problems := validateForTest(t, content, true)
+	if len(problems) != 0 {
+		t.Fatalf("want no problems, got %v", problems)
+	}
+
+	// devcontainer.json では、マージ指示子を受け付けない
+	problems = validateForTest(t, content, false)
+	assertProblem(t, problems, 1, 13, `"runArgs" must be array of string, got object`)
+
+	problems = validateForTest(t, `{"runArgs": {"$insert": ["--init"]}}`, true)
+	assertProblem(t, problems, 1, 14, `unknown merge directive "$insert". use one of $replace, $remove, $prepend, $append`)
+}
+
+func TestValidateJwccReportsSyntaxError(t *testing.T) {
+	problems := validateForTest(t, "{\n  \"name\": \n}", false)
+	assertProblem(t, problems, 3, 1, "invalid character '}' at start of value")
+}
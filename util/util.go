@@ -4,6 +4,7 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"io/fs"
 	"os"
 	"os/exec"
@@ -11,13 +12,18 @@ import (
 	"runtime"
 	"strings"
 
-	"github.com/Jeffail/gabs/v2"
 	"github.com/tailscale/hujson"
+
+	"github.com/mikoto2000/devcontainer.vim/v3/runner"
 )
 
 const binDirName = "bin"
 const configDirName = "config"
 
+// ワークスペースフォルダの絶対パスを記録するファイルの名前。
+// md5 ハッシュ化したディレクトリ名からワークスペースフォルダを逆引きするために使用する。
+const WorkspaceFileName = "workspace"
+
 // command で指定したものへパスが通っているかを確認する。
 // パスが通っている場合 true を返却し、通っていない場合 false を返却する。
 func IsExistsCommand(command string) bool {
@@ -95,38 +101,31 @@ func AddExecutePermission(filePath string) error {
 	return nil
 }
 
-// baseConfigPath で指定した JSON に additionalConfigPath で指定した JSON をマージし、その結果を返却する
-func readAndMergeConfig(baseConfigPath string, additionalConfigPath string) ([]byte, error) {
+// baseConfigPath で指定した JSON に additionalConfigPaths で指定した JSON を順にマージし、その結果を返却する
+func readAndMergeConfig(baseConfigPath string, additionalConfigPaths ...string) ([]byte, error) {
 
 	// 設定ファイルを JWCC としてパースし、標準 JSON へ変換
-	parsedBaseJSON, err := ParseJwcc(baseConfigPath)
+	mergedJSON, err := ParseJwcc(baseConfigPath)
 	if err != nil {
 		return nil, err
 	}
 
-	// 標準 JSON を gabs を使って再パース
-	parsedBaseJSONGrabContainer, err := gabs.ParseJSON(parsedBaseJSON)
-	if err != nil {
-		return nil, err
-	}
-
-	// devcontainer.vim 用追加設定ファイル読み込み
-	parsedAdditionalJSON, err := ParseJwcc(additionalConfigPath)
-	if err != nil {
-		return nil, err
-	}
+	for _, additionalConfigPath := range additionalConfigPaths {
+		// devcontainer.vim 用追加設定ファイル読み込み
+		parsedAdditionalJSON, err := ParseJwcc(additionalConfigPath)
+		if err != nil {
+			return nil, err
+		}
 
-	// 標準 JSON を gabs を使って再パース
-	parsedAdditionalJSONGrabContainer, err := gabs.ParseJSON(parsedAdditionalJSON)
-	if err != nil {
-		return nil, err
+		// オブジェクトは再帰的にマージ、スカラー値は上書き、配列はマージ指示子に従ってマージ
+		mergedJSON, err = MergeJSON(mergedJSON, parsedAdditionalJSON)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", additionalConfigPath, err)
+		}
 	}
 
-	// gabs を使って JSON をマージ
-	parsedBaseJSONGrabContainer.Merge(parsedAdditionalJSONGrabContainer)
-
 	// 設定ファイルの内容を返却
-	return parsedBaseJSONGrabContainer.Bytes(), nil
+	return mergedJSON, nil
 }
 
 // JWCC を標準 JSON に変換し、 []byte として返却
@@ -148,36 +147,71 @@ func ParseJwcc(jwccPath string) ([]byte, error) {
 	return parsedJSON.Pack(), nil
 }
 
-// configFilePath と additionalConfigFilePath の JSON をマージし、
+// configFilePath の JSON に additionalConfigFilePaths の JSON を指定順にマージし、
 // devcontainer.vim のキャッシュディレクトリ内の設定ファイル格納ディレクトリへ格納する。
+// 存在しない追加設定ファイルは無視する。
 // 作成した devcontainer.json を格納しているディレクトリのパスを返却する。
-func CreateConfigFileForDevcontainer(configDirForDevcontainer string, workspaceFolder string, configFilePath string, additionalConfigFilePath string) (string, error) {
+func CreateConfigFileForDevcontainer(configDirForDevcontainer string, workspaceFolder string, configFilePath string, additionalConfigFilePaths ...string) (string, error) {
+	configFileContent, err := MergeConfigFiles(configFilePath, additionalConfigFilePaths...)
+	if err != nil {
+		return "", err
+	}
+	return WriteConfigFileForDevcontainer(configDirForDevcontainer, workspaceFolder, "", configFileContent)
+}
+
+// configFilePath の JSON に additionalConfigFilePaths の JSON を指定順にマージし、その内容を返却する。
+// 存在しない追加設定ファイルは無視する。
+// 追加設定ファイルが 1 つも存在しない場合、 configFilePath の内容をそのまま返却する。
+func MergeConfigFiles(configFilePath string, additionalConfigFilePaths ...string) ([]byte, error) {
 
 	// マージ要否判定して最終的に使う JSON のコンテンツを組み立てる
+	existingAdditionalConfigFilePaths := []string{}
+	for _, additionalConfigFilePath := range additionalConfigFilePaths {
+		if additionalConfigFilePath != "" && IsExists(additionalConfigFilePath) {
+			existingAdditionalConfigFilePaths = append(existingAdditionalConfigFilePaths, additionalConfigFilePath)
+		}
+	}
+
 	var configFileContent []byte
 	var err error
-	if IsExists(additionalConfigFilePath) {
+	if len(existingAdditionalConfigFilePaths) > 0 {
 		// JSON のマージ
-		configFileContent, err = readAndMergeConfig(configFilePath, additionalConfigFilePath)
+		configFileContent, err = readAndMergeConfig(configFilePath, existingAdditionalConfigFilePaths...)
 	} else {
 		// ベースの設定をそのまま使用
 		configFileContent, err = os.ReadFile(configFilePath)
 	}
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return configFileContent, nil
+}
 
+// configFileContent を、 devcontainer.vim のキャッシュディレクトリ内の設定ファイル格納ディレクトリへ格納する。
+// 作成した devcontainer.json のパスを返却する。
+// configurationName は GetConfigDirForConfiguration を参照。
+func WriteConfigFileForDevcontainer(configDirForDevcontainer string, workspaceFolder string, configurationName string, configFileContent []byte) (string, error) {
 	// 設定管理フォルダに JSON を配置
-	generateConfigDir, err := GetConfigDir(configDirForDevcontainer, workspaceFolder)
+	generateConfigDir, err := GetConfigDirForConfiguration(configDirForDevcontainer, workspaceFolder, configurationName)
 	if err != nil {
 		return "", err
 	}
 	generateConfigFilePath := filepath.Join(generateConfigDir, "devcontainer.json")
-	err = os.MkdirAll(generateConfigDir, 0777)
+	err = runner.MkdirAll(generateConfigDir, 0777)
 	if err != nil {
 		return "", err
 	}
-	err = os.WriteFile(generateConfigFilePath, configFileContent, 0666)
+	err = runner.WriteFile(generateConfigFilePath, configFileContent, 0666)
+	if err != nil {
+		return "", err
+	}
+
+	// ディレクトリ名からワークスペースフォルダを逆引きできるよう、絶対パスを記録
+	workspaceFolderAbs, err := filepath.Abs(workspaceFolder)
+	if err != nil {
+		return "", err
+	}
+	err = runner.WriteFile(filepath.Join(generateConfigDir, WorkspaceFileName), []byte(workspaceFolderAbs), 0666)
 	if err != nil {
 		return "", err
 	}
@@ -187,11 +221,23 @@ func CreateConfigFileForDevcontainer(configDirForDevcontainer string, workspaceF
 // devcontainer.vim 用の devcontainer.json 格納先ディレクトリを計算して返却する。
 // `<devcontainer.vim のキャッシュディレクトリ>/config/<workspaceFolder の絶対パスを md5 播種化した文字列>` のディレクトリを返却
 func GetConfigDir(configDirForDevcontainer string, workspaceFolder string) (string, error) {
+	return GetConfigDirForConfiguration(configDirForDevcontainer, workspaceFolder, "")
+}
+
+// ワークスペースフォルダと devcontainer.json の組み合わせごとの、
+// devcontainer.vim 用の devcontainer.json 格納先ディレクトリを計算して返却する。
+// configurationName が空文字(既定の場所の devcontainer.json)の場合は GetConfigDir と同じディレクトリを返却する。
+// それ以外の場合は `<workspaceFolder の絶対パス>\n<configurationName>` を md5 ハッシュ化した名前のディレクトリを返却する。
+func GetConfigDirForConfiguration(configDirForDevcontainer string, workspaceFolder string, configurationName string) (string, error) {
 	workspaceFolderAbs, err := filepath.Abs(workspaceFolder)
 	if err != nil {
 		return "", err
 	}
-	workspaceFolderHash := md5.Sum([]byte(workspaceFolderAbs))
+	key := workspaceFolderAbs
+	if configurationName != "" {
+		key += "\n" + configurationName
+	}
+	workspaceFolderHash := md5.Sum([]byte(key))
 
 	workspaceFolderHashString := hex.EncodeToString(workspaceFolderHash[:])
 	return filepath.Join(configDirForDevcontainer, workspaceFolderHashString), nil
@@ -203,6 +249,15 @@ func IsWsl() bool {
 	return exists
 }
 
+// f が端末(キャラクタデバイス)に接続されているかを判定する
+func IsTerminal(f *os.File) bool {
+	fileInfo, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fileInfo.Mode()&os.ModeCharDevice != 0
+}
+
 // 関連付けられたアプリケーションで開く
 func OpenFileWithDefaultApp(filePath string) error {
 	var cmd *exec.Cmd
@@ -227,22 +282,6 @@ func CreateFileWithContents(file string, content string, permission fs.FileMode)
 	return nil
 }
 
-// 文字列中のシェル変数を展開して返却する
-func ExtractShellVariables(str string) (string, error) {
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		return "", errors.New("ExtractShellVariables no support windows")
-	} else {
-		cmd = exec.Command("sh", "-c", "echo "+str)
-	}
-
-	extractedStrBytes, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", err
-	}
-	return string(extractedStrBytes), nil
-}
-
 func NormalizeContainerArch(containerArch string) (string, error) {
 	if containerArch == "amd64" || containerArch == "x86_64" {
 		return "amd64", nil
@@ -229,6 +229,28 @@ func TestCreateConfigFileForDevcontainer(t *testing.T) {
 
 }
 
+func TestCreateConfigFileForDevcontainerRecordsWorkspaceFolder(t *testing.T) {
+	configDirForDevcontainer := t.TempDir()
+	workspaceFolder := "test/resource/TestCreateConfigFileForDevcontainer"
+	configFilePath := "test/resource/TestCreateConfigFileForDevcontainer/.devcontainer/devcontainer.json"
+	additionalConfigFilePath := "test/resource/TestCreateConfigFileForDevcontainer/.devcontainer/devcontainer.vim.json"
+
+	mergedConfigFilePath, err := CreateConfigFileForDevcontainer(configDirForDevcontainer, workspaceFolder, configFilePath, additionalConfigFilePath)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+
+	// マージ済み設定ファイルと同じディレクトリに、ワークスペースフォルダの絶対パスが記録される
+	got, err := os.ReadFile(filepath.Join(filepath.Dir(mergedConfigFilePath), WorkspaceFileName))
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	want, _ := filepath.Abs(workspaceFolder)
+	if string(got) != want {
+		t.Fatalf("error: want %s, but got %s", want, got)
+	}
+}
+
 func TestGetConfigDir(t *testing.T) {
 	configDir := "test/resource/TestGetConfigDir/config"
 	workspaceFolder := "test/resource/TestGetConfigDir"
@@ -243,6 +265,35 @@ func TestGetConfigDir(t *testing.T) {
 	}
 }
 
+func TestGetConfigDirForConfiguration(t *testing.T) {
+	configDir := t.TempDir()
+	workspaceFolder := t.TempDir()
+
+	defaultConfigDir, err := GetConfigDir(configDir, workspaceFolder)
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	got, err := GetConfigDirForConfiguration(configDir, workspaceFolder, "")
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if got != defaultConfigDir {
+		t.Fatalf("error: want %s, but got %s", defaultConfigDir, got)
+	}
+
+	pythonConfigDir, err := GetConfigDirForConfiguration(configDir, workspaceFolder, "python")
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	goConfigDir, err := GetConfigDirForConfiguration(configDir, workspaceFolder, "go")
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	if pythonConfigDir == defaultConfigDir || pythonConfigDir == goConfigDir {
+		t.Fatalf("error: config dirs must differ per configuration: default=%s, python=%s, go=%s", defaultConfigDir, pythonConfigDir, goConfigDir)
+	}
+}
+
 func TestIsWsl(t *testing.T) {
 	os.Setenv("WSL_DISTRO_NAME", "")
 	got := IsWsl()